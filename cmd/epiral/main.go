@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -83,7 +84,7 @@ func startCmd(args []string) {
 	}()
 
 	// 创建 Daemon Manager
-	manager := daemon.NewManager(store)
+	manager := daemon.NewManager(store, version)
 
 	// 启动 Web 服务（后台）
 	ws := webserver.New(port, store, logBuf, manager, ctx)
@@ -96,7 +97,7 @@ func startCmd(args []string) {
 
 	// 如果已配置，启动 Daemon
 	if cfg.IsConfigured() {
-		log.Printf("[系统] 启动连接: computer=%s → %s", cfg.Computer.ID, cfg.Agent.Address)
+		log.Printf("[系统] 启动连接: computer=%s, browser=%s → %s", cfg.Computer.ID, cfg.Browser.ID, cfg.Agent.Address)
 		manager.Start(ctx)
 	} else {
 		log.Println("[系统] 未配置连接信息，请在 Web 面板中完成配置")
@@ -115,6 +116,11 @@ func legacyCmd() {
 	computerDesc := flag.String("computer-desc", "", "电脑描述")
 	allowedPaths := flag.String("paths", "", "允许访问的路径，逗号分隔")
 	token := flag.String("token", "", "认证 token")
+	envAllow := flag.String("env-allow", "", "传给命令的环境变量白名单，逗号分隔，支持 * 通配（默认全部）")
+	envDeny := flag.String("env-deny", "", "不传给命令的环境变量，逗号分隔，支持 * 通配")
+	browserID := flag.String("browser-id", "", "浏览器 ID，设置后启用浏览器插件端点 (如 my-chrome)")
+	browserDesc := flag.String("browser-desc", "", "浏览器描述")
+	browserListen := flag.String("browser-listen", "", "浏览器插件端点监听地址 (默认 127.0.0.1:19824)")
 	flag.Parse()
 
 	if *agentAddr == "" {
@@ -127,25 +133,26 @@ func legacyCmd() {
 		flag.Usage()
 		os.Exit(1)
 	}
-	if *computerID == "" {
-		fmt.Fprintln(os.Stderr, "错误: 必须指定 --computer-id")
+	if *computerID == "" && *browserID == "" {
+		fmt.Fprintln(os.Stderr, "错误: 必须指定 --computer-id 或 --browser-id")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	var paths []string
-	if *allowedPaths != "" {
-		for _, p := range strings.Split(*allowedPaths, ",") {
-			paths = append(paths, strings.TrimSpace(p))
-		}
-	}
-
 	cfg := daemon.Config{
 		AgentAddr:    *agentAddr,
 		ComputerID:   *computerID,
 		ComputerDesc: *computerDesc,
-		AllowedPaths: paths,
+		AllowedPaths: splitList(*allowedPaths),
 		Token:        *token,
+		EnvAllow:     splitList(*envAllow),
+		EnvDeny:      splitList(*envDeny),
+
+		BrowserID:     *browserID,
+		BrowserDesc:   *browserDesc,
+		BrowserListen: *browserListen,
+
+		Version: version,
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -160,7 +167,7 @@ func legacyCmd() {
 
 	d := daemon.New(&cfg)
 
-	log.Printf("[系统] Epiral CLI 启动 (v%s): computer=%s, agent=%s", version, cfg.ComputerID, cfg.AgentAddr)
+	log.Printf("[系统] Epiral CLI 启动 (v%s): computer=%s, browser=%s, agent=%s", version, cfg.ComputerID, cfg.BrowserID, cfg.AgentAddr)
 
 	// 自动重连循环
 	backoff := time.Second
@@ -188,6 +195,11 @@ func legacyCmd() {
 		if ctx.Err() != nil {
 			break
 		}
+		var rejected *daemon.RejectedError
+		if errors.As(err, &rejected) {
+			// 直连模式的参数不会变，重连也会再次被拒绝
+			log.Fatalf("[连接] %v，请检查 --token 等参数", err)
+		}
 
 		connDuration := time.Since(connectStart)
 		log.Printf("[连接] 断开: %v (持续 %.0fs)", err, connDuration.Seconds())
@@ -210,3 +222,14 @@ func legacyCmd() {
 	}
 	cancel()
 }
+
+// splitList 解析逗号分隔的参数
+func splitList(s string) []string {
+	var items []string
+	if s != "" {
+		for _, item := range strings.Split(s, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	return items
+}
@@ -22,6 +22,348 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type EntryType int32
+
+const (
+	EntryType_ENTRY_TYPE_UNKNOWN EntryType = 0
+	EntryType_ENTRY_TYPE_FILE    EntryType = 1
+	EntryType_ENTRY_TYPE_DIR     EntryType = 2
+	EntryType_ENTRY_TYPE_SYMLINK EntryType = 3
+	EntryType_ENTRY_TYPE_OTHER   EntryType = 4 // 设备、管道、socket 等
+)
+
+// Enum value maps for EntryType.
+var (
+	EntryType_name = map[int32]string{
+		0: "ENTRY_TYPE_UNKNOWN",
+		1: "ENTRY_TYPE_FILE",
+		2: "ENTRY_TYPE_DIR",
+		3: "ENTRY_TYPE_SYMLINK",
+		4: "ENTRY_TYPE_OTHER",
+	}
+	EntryType_value = map[string]int32{
+		"ENTRY_TYPE_UNKNOWN": 0,
+		"ENTRY_TYPE_FILE":    1,
+		"ENTRY_TYPE_DIR":     2,
+		"ENTRY_TYPE_SYMLINK": 3,
+		"ENTRY_TYPE_OTHER":   4,
+	}
+)
+
+func (x EntryType) Enum() *EntryType {
+	p := new(EntryType)
+	*p = x
+	return p
+}
+
+func (x EntryType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EntryType) Descriptor() protoreflect.EnumDescriptor {
+	return file_epiral_v1_epiral_proto_enumTypes[0].Descriptor()
+}
+
+func (EntryType) Type() protoreflect.EnumType {
+	return &file_epiral_v1_epiral_proto_enumTypes[0]
+}
+
+func (x EntryType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EntryType.Descriptor instead.
+func (EntryType) EnumDescriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{0}
+}
+
+type PatchAction int32
+
+const (
+	PatchAction_PATCH_ACTION_MODIFY PatchAction = 0
+	PatchAction_PATCH_ACTION_CREATE PatchAction = 1
+	PatchAction_PATCH_ACTION_DELETE PatchAction = 2
+	PatchAction_PATCH_ACTION_RENAME PatchAction = 3 // 重命名，可同时修改内容
+)
+
+// Enum value maps for PatchAction.
+var (
+	PatchAction_name = map[int32]string{
+		0: "PATCH_ACTION_MODIFY",
+		1: "PATCH_ACTION_CREATE",
+		2: "PATCH_ACTION_DELETE",
+		3: "PATCH_ACTION_RENAME",
+	}
+	PatchAction_value = map[string]int32{
+		"PATCH_ACTION_MODIFY": 0,
+		"PATCH_ACTION_CREATE": 1,
+		"PATCH_ACTION_DELETE": 2,
+		"PATCH_ACTION_RENAME": 3,
+	}
+)
+
+func (x PatchAction) Enum() *PatchAction {
+	p := new(PatchAction)
+	*p = x
+	return p
+}
+
+func (x PatchAction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PatchAction) Descriptor() protoreflect.EnumDescriptor {
+	return file_epiral_v1_epiral_proto_enumTypes[1].Descriptor()
+}
+
+func (PatchAction) Type() protoreflect.EnumType {
+	return &file_epiral_v1_epiral_proto_enumTypes[1]
+}
+
+func (x PatchAction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PatchAction.Descriptor instead.
+func (PatchAction) EnumDescriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{1}
+}
+
+// 失败的类别，所有带 error 的上行结果都同时带 code。
+// Agent 应按 code 分支处理；error 是给人看的说明，可能随语言变化，不要匹配其文本。
+type ErrorCode int32
+
+const (
+	ErrorCode_ERROR_CODE_UNSPECIFIED       ErrorCode = 0 // 成功，或旧版 CLI 未填写
+	ErrorCode_ERROR_CODE_CONFLICT          ErrorCode = 1 // 内容与预期不符：expected_hash 不匹配、hunk 无法应用，应重新读取
+	ErrorCode_ERROR_CODE_NOT_FOUND         ErrorCode = 2 // 文件、目录、会话或请求不存在；old_string / 锚点未找到
+	ErrorCode_ERROR_CODE_PERMISSION_DENIED ErrorCode = 3 // 路径不在允许范围、缺少对应权限，或被系统拒绝
+	ErrorCode_ERROR_CODE_INVALID_ARGUMENT  ErrorCode = 4 // 参数无效：路径为空、偏移越界、正则或补丁格式错误等
+	ErrorCode_ERROR_CODE_TOO_LARGE         ErrorCode = 5 // 超过大小上限，可分段读取或缩小范围
+	ErrorCode_ERROR_CODE_ALREADY_EXISTS    ErrorCode = 6 // 目标已存在且未要求覆盖
+	ErrorCode_ERROR_CODE_AMBIGUOUS_MATCH   ErrorCode = 7 // old_string 匹配多处且未设置 replace_all
+	ErrorCode_ERROR_CODE_TIMEOUT           ErrorCode = 8
+	ErrorCode_ERROR_CODE_CANCELLED         ErrorCode = 9  // 被 CancelRequest 取消
+	ErrorCode_ERROR_CODE_UNAVAILABLE       ErrorCode = 10 // 依赖的组件不可用，如浏览器插件未连接
+	ErrorCode_ERROR_CODE_UNSUPPORTED       ErrorCode = 11 // 不支持的内容或组合，如 pty 与 session_id 同用
+	ErrorCode_ERROR_CODE_INTERNAL          ErrorCode = 12 // 其他失败（I/O 错误等）
+)
+
+// Enum value maps for ErrorCode.
+var (
+	ErrorCode_name = map[int32]string{
+		0:  "ERROR_CODE_UNSPECIFIED",
+		1:  "ERROR_CODE_CONFLICT",
+		2:  "ERROR_CODE_NOT_FOUND",
+		3:  "ERROR_CODE_PERMISSION_DENIED",
+		4:  "ERROR_CODE_INVALID_ARGUMENT",
+		5:  "ERROR_CODE_TOO_LARGE",
+		6:  "ERROR_CODE_ALREADY_EXISTS",
+		7:  "ERROR_CODE_AMBIGUOUS_MATCH",
+		8:  "ERROR_CODE_TIMEOUT",
+		9:  "ERROR_CODE_CANCELLED",
+		10: "ERROR_CODE_UNAVAILABLE",
+		11: "ERROR_CODE_UNSUPPORTED",
+		12: "ERROR_CODE_INTERNAL",
+	}
+	ErrorCode_value = map[string]int32{
+		"ERROR_CODE_UNSPECIFIED":       0,
+		"ERROR_CODE_CONFLICT":          1,
+		"ERROR_CODE_NOT_FOUND":         2,
+		"ERROR_CODE_PERMISSION_DENIED": 3,
+		"ERROR_CODE_INVALID_ARGUMENT":  4,
+		"ERROR_CODE_TOO_LARGE":         5,
+		"ERROR_CODE_ALREADY_EXISTS":    6,
+		"ERROR_CODE_AMBIGUOUS_MATCH":   7,
+		"ERROR_CODE_TIMEOUT":           8,
+		"ERROR_CODE_CANCELLED":         9,
+		"ERROR_CODE_UNAVAILABLE":       10,
+		"ERROR_CODE_UNSUPPORTED":       11,
+		"ERROR_CODE_INTERNAL":          12,
+	}
+)
+
+func (x ErrorCode) Enum() *ErrorCode {
+	p := new(ErrorCode)
+	*p = x
+	return p
+}
+
+func (x ErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_epiral_v1_epiral_proto_enumTypes[2].Descriptor()
+}
+
+func (ErrorCode) Type() protoreflect.EnumType {
+	return &file_epiral_v1_epiral_proto_enumTypes[2]
+}
+
+func (x ErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorCode.Descriptor instead.
+func (ErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{2}
+}
+
+// 子进程从 daemon 继承环境变量的方式。
+// 无论哪种方式都先经过配置中的 env_allow / env_deny 过滤，Agent 无法绕过。
+// 会话模式下只在创建会话时生效；env 以 export 写入会话并保留。
+type InheritEnv int32
+
+const (
+	InheritEnv_INHERIT_ENV_UNSPECIFIED InheritEnv = 0 // 同 FILTERED
+	InheritEnv_INHERIT_ENV_FILTERED    InheritEnv = 1 // 继承过滤后的全部环境变量
+	InheritEnv_INHERIT_ENV_NONE        InheritEnv = 2 // 干净环境：只保留 PATH、HOME、LANG 等基础变量（同样经过过滤）
+)
+
+// Enum value maps for InheritEnv.
+var (
+	InheritEnv_name = map[int32]string{
+		0: "INHERIT_ENV_UNSPECIFIED",
+		1: "INHERIT_ENV_FILTERED",
+		2: "INHERIT_ENV_NONE",
+	}
+	InheritEnv_value = map[string]int32{
+		"INHERIT_ENV_UNSPECIFIED": 0,
+		"INHERIT_ENV_FILTERED":    1,
+		"INHERIT_ENV_NONE":        2,
+	}
+)
+
+func (x InheritEnv) Enum() *InheritEnv {
+	p := new(InheritEnv)
+	*p = x
+	return p
+}
+
+func (x InheritEnv) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (InheritEnv) Descriptor() protoreflect.EnumDescriptor {
+	return file_epiral_v1_epiral_proto_enumTypes[3].Descriptor()
+}
+
+func (InheritEnv) Type() protoreflect.EnumType {
+	return &file_epiral_v1_epiral_proto_enumTypes[3]
+}
+
+func (x InheritEnv) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use InheritEnv.Descriptor instead.
+func (InheritEnv) EnumDescriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{3}
+}
+
+// 读取模式
+type ReadMode int32
+
+const (
+	ReadMode_READ_MODE_LINES ReadMode = 0 // 文本按行读取，内容放在 FileContent.content
+	ReadMode_READ_MODE_BYTES ReadMode = 1 // 按字节范围读取原始内容，放在 FileContent.data
+)
+
+// Enum value maps for ReadMode.
+var (
+	ReadMode_name = map[int32]string{
+		0: "READ_MODE_LINES",
+		1: "READ_MODE_BYTES",
+	}
+	ReadMode_value = map[string]int32{
+		"READ_MODE_LINES": 0,
+		"READ_MODE_BYTES": 1,
+	}
+)
+
+func (x ReadMode) Enum() *ReadMode {
+	p := new(ReadMode)
+	*p = x
+	return p
+}
+
+func (x ReadMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ReadMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_epiral_v1_epiral_proto_enumTypes[4].Descriptor()
+}
+
+func (ReadMode) Type() protoreflect.EnumType {
+	return &file_epiral_v1_epiral_proto_enumTypes[4]
+}
+
+func (x ReadMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ReadMode.Descriptor instead.
+func (ReadMode) EnumDescriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{4}
+}
+
+type EditMode int32
+
+const (
+	EditMode_EDIT_MODE_REPLACE       EditMode = 0 // 精确查找替换
+	EditMode_EDIT_MODE_REGEX         EditMode = 1 // 正则替换
+	EditMode_EDIT_MODE_LINES         EditMode = 2 // 用 new_string 替换 start_line..end_line 行，new_string 为空即删除
+	EditMode_EDIT_MODE_INSERT_BEFORE EditMode = 3 // 在 start_line 行之前插入 new_string
+	EditMode_EDIT_MODE_INSERT_AFTER  EditMode = 4 // 在 start_line 行之后插入 new_string（0 = 文件开头）
+)
+
+// Enum value maps for EditMode.
+var (
+	EditMode_name = map[int32]string{
+		0: "EDIT_MODE_REPLACE",
+		1: "EDIT_MODE_REGEX",
+		2: "EDIT_MODE_LINES",
+		3: "EDIT_MODE_INSERT_BEFORE",
+		4: "EDIT_MODE_INSERT_AFTER",
+	}
+	EditMode_value = map[string]int32{
+		"EDIT_MODE_REPLACE":       0,
+		"EDIT_MODE_REGEX":         1,
+		"EDIT_MODE_LINES":         2,
+		"EDIT_MODE_INSERT_BEFORE": 3,
+		"EDIT_MODE_INSERT_AFTER":  4,
+	}
+)
+
+func (x EditMode) Enum() *EditMode {
+	p := new(EditMode)
+	*p = x
+	return p
+}
+
+func (x EditMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EditMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_epiral_v1_epiral_proto_enumTypes[5].Descriptor()
+}
+
+func (EditMode) Type() protoreflect.EnumType {
+	return &file_epiral_v1_epiral_proto_enumTypes[5]
+}
+
+func (x EditMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EditMode.Descriptor instead.
+func (EditMode) EnumDescriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{5}
+}
+
 type ConnectRequest struct {
 	state     protoimpl.MessageState `protogen:"open.v1"`
 	RequestId string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
@@ -34,6 +376,12 @@ type ConnectRequest struct {
 	//	*ConnectRequest_Ping
 	//	*ConnectRequest_BrowserRegistration
 	//	*ConnectRequest_BrowserExecOutput
+	//	*ConnectRequest_TransferStatus
+	//	*ConnectRequest_TransferChunk
+	//	*ConnectRequest_DirListing
+	//	*ConnectRequest_SearchResult
+	//	*ConnectRequest_FileStat
+	//	*ConnectRequest_PatchResult
 	Payload       isConnectRequest_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -146,6 +494,60 @@ func (x *ConnectRequest) GetBrowserExecOutput() *BrowserExecOutput {
 	return nil
 }
 
+func (x *ConnectRequest) GetTransferStatus() *TransferStatus {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectRequest_TransferStatus); ok {
+			return x.TransferStatus
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetTransferChunk() *TransferChunk {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectRequest_TransferChunk); ok {
+			return x.TransferChunk
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetDirListing() *DirListing {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectRequest_DirListing); ok {
+			return x.DirListing
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetSearchResult() *SearchResult {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectRequest_SearchResult); ok {
+			return x.SearchResult
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetFileStat() *FileStat {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectRequest_FileStat); ok {
+			return x.FileStat
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetPatchResult() *PatchResult {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectRequest_PatchResult); ok {
+			return x.PatchResult
+		}
+	}
+	return nil
+}
+
 type isConnectRequest_Payload interface {
 	isConnectRequest_Payload()
 }
@@ -180,6 +582,31 @@ type ConnectRequest_BrowserExecOutput struct {
 	BrowserExecOutput *BrowserExecOutput `protobuf:"bytes,16,opt,name=browser_exec_output,json=browserExecOutput,proto3,oneof"`
 }
 
+type ConnectRequest_TransferStatus struct {
+	// 分块传输
+	TransferStatus *TransferStatus `protobuf:"bytes,17,opt,name=transfer_status,json=transferStatus,proto3,oneof"`
+}
+
+type ConnectRequest_TransferChunk struct {
+	TransferChunk *TransferChunk `protobuf:"bytes,18,opt,name=transfer_chunk,json=transferChunk,proto3,oneof"`
+}
+
+type ConnectRequest_DirListing struct {
+	DirListing *DirListing `protobuf:"bytes,19,opt,name=dir_listing,json=dirListing,proto3,oneof"`
+}
+
+type ConnectRequest_SearchResult struct {
+	SearchResult *SearchResult `protobuf:"bytes,20,opt,name=search_result,json=searchResult,proto3,oneof"`
+}
+
+type ConnectRequest_FileStat struct {
+	FileStat *FileStat `protobuf:"bytes,21,opt,name=file_stat,json=fileStat,proto3,oneof"`
+}
+
+type ConnectRequest_PatchResult struct {
+	PatchResult *PatchResult `protobuf:"bytes,22,opt,name=patch_result,json=patchResult,proto3,oneof"`
+}
+
 func (*ConnectRequest_Registration) isConnectRequest_Payload() {}
 
 func (*ConnectRequest_ExecOutput) isConnectRequest_Payload() {}
@@ -194,20 +621,36 @@ func (*ConnectRequest_BrowserRegistration) isConnectRequest_Payload() {}
 
 func (*ConnectRequest_BrowserExecOutput) isConnectRequest_Payload() {}
 
+func (*ConnectRequest_TransferStatus) isConnectRequest_Payload() {}
+
+func (*ConnectRequest_TransferChunk) isConnectRequest_Payload() {}
+
+func (*ConnectRequest_DirListing) isConnectRequest_Payload() {}
+
+func (*ConnectRequest_SearchResult) isConnectRequest_Payload() {}
+
+func (*ConnectRequest_FileStat) isConnectRequest_Payload() {}
+
+func (*ConnectRequest_PatchResult) isConnectRequest_Payload() {}
+
 // 首次连接：我是谁（电脑）
 type Registration struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ComputerId    string                 `protobuf:"bytes,1,opt,name=computer_id,json=computerId,proto3" json:"computer_id,omitempty"`                                               // --computer-id "my-pc"
-	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`                                                               // --computer-desc "Mac Studio M2 Ultra"
-	Os            string                 `protobuf:"bytes,3,opt,name=os,proto3" json:"os,omitempty"`                                                                                 // "darwin" | "linux"
-	Arch          string                 `protobuf:"bytes,4,opt,name=arch,proto3" json:"arch,omitempty"`                                                                             // "arm64" | "amd64"
-	Shell         string                 `protobuf:"bytes,5,opt,name=shell,proto3" json:"shell,omitempty"`                                                                           // "/bin/zsh"
-	HomeDir       string                 `protobuf:"bytes,6,opt,name=home_dir,json=homeDir,proto3" json:"home_dir,omitempty"`                                                        // "/Users/xx"
-	Tools         map[string]string      `protobuf:"bytes,7,rep,name=tools,proto3" json:"tools,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // {"go": "1.22", "node": "22.13"}
-	AllowedPaths  []string               `protobuf:"bytes,8,rep,name=allowed_paths,json=allowedPaths,proto3" json:"allowed_paths,omitempty"`                                         // ["/Users/xx/workspace", "/tmp"]
-	Token         string                 `protobuf:"bytes,9,opt,name=token,proto3" json:"token,omitempty"`                                                                           // 认证 token
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ComputerId      string                 `protobuf:"bytes,1,opt,name=computer_id,json=computerId,proto3" json:"computer_id,omitempty"`                                               // --computer-id "my-pc"
+	Description     string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`                                                               // --computer-desc "Mac Studio M2 Ultra"
+	Os              string                 `protobuf:"bytes,3,opt,name=os,proto3" json:"os,omitempty"`                                                                                 // "darwin" | "linux"
+	Arch            string                 `protobuf:"bytes,4,opt,name=arch,proto3" json:"arch,omitempty"`                                                                             // "arm64" | "amd64"
+	Shell           string                 `protobuf:"bytes,5,opt,name=shell,proto3" json:"shell,omitempty"`                                                                           // "/bin/zsh"
+	HomeDir         string                 `protobuf:"bytes,6,opt,name=home_dir,json=homeDir,proto3" json:"home_dir,omitempty"`                                                        // "/Users/xx"
+	Tools           map[string]string      `protobuf:"bytes,7,rep,name=tools,proto3" json:"tools,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // {"go": "1.22", "node": "22.13"}
+	AllowedPaths    []string               `protobuf:"bytes,8,rep,name=allowed_paths,json=allowedPaths,proto3" json:"allowed_paths,omitempty"`                                         // ["/Users/xx/workspace", "/tmp"]，可访问（非 deny）的路径
+	Token           string                 `protobuf:"bytes,9,opt,name=token,proto3" json:"token,omitempty"`                                                                           // 认证 token
+	Paths           []*PathPermission      `protobuf:"bytes,10,rep,name=paths,proto3" json:"paths,omitempty"`                                                                          // 按路径的权限，最长前缀匹配；为空表示不限制
+	ProtocolVersion uint32                 `protobuf:"varint,11,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`                              // CLI 实现的协议版本（当前 1），消息语义不兼容地变化时递增
+	CliVersion      string                 `protobuf:"bytes,12,opt,name=cli_version,json=cliVersion,proto3" json:"cli_version,omitempty"`                                              // "0.4.0"
+	Capabilities    []string               `protobuf:"bytes,13,rep,name=capabilities,proto3" json:"capabilities,omitempty"`                                                            // CLI 支持的功能，见下方列表
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *Registration) Reset() {
@@ -303,30 +746,61 @@ func (x *Registration) GetToken() string {
 	return ""
 }
 
-// 浏览器上线/下线通知
-type BrowserRegistration struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	BrowserId     string                 `protobuf:"bytes,1,opt,name=browser_id,json=browserId,proto3" json:"browser_id,omitempty"` // --browser-id "my-chrome"
-	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`              // --browser-desc "My Chrome Browser"
-	Online        bool                   `protobuf:"varint,3,opt,name=online,proto3" json:"online,omitempty"`                       // true=插件已连接 SSE, false=插件断开
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *Registration) GetPaths() []*PathPermission {
+	if x != nil {
+		return x.Paths
+	}
+	return nil
 }
 
-func (x *BrowserRegistration) Reset() {
-	*x = BrowserRegistration{}
+func (x *Registration) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *Registration) GetCliVersion() string {
+	if x != nil {
+		return x.CliVersion
+	}
+	return ""
+}
+
+func (x *Registration) GetCapabilities() []string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+// 注册应答：Agent 收到 Registration 后发送。
+// 接受时 features 是 Agent 将会使用的功能（capabilities 的子集）；
+// 拒绝时 CLI 断开连接，并在修改配置前不再频繁重连。旧版 Agent 不发送应答，CLI 视为接受。
+type RegistrationAck struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Accepted        bool                   `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Reason          string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`                                           // 拒绝原因，如 "token 无效"、"协议版本不兼容"
+	ProtocolVersion uint32                 `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"` // Agent 实现的协议版本
+	Features        []string               `protobuf:"bytes,4,rep,name=features,proto3" json:"features,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RegistrationAck) Reset() {
+	*x = RegistrationAck{}
 	mi := &file_epiral_v1_epiral_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BrowserRegistration) String() string {
+func (x *RegistrationAck) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BrowserRegistration) ProtoMessage() {}
+func (*RegistrationAck) ProtoMessage() {}
 
-func (x *BrowserRegistration) ProtoReflect() protoreflect.Message {
+func (x *RegistrationAck) ProtoReflect() protoreflect.Message {
 	mi := &file_epiral_v1_epiral_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -338,58 +812,64 @@ func (x *BrowserRegistration) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BrowserRegistration.ProtoReflect.Descriptor instead.
-func (*BrowserRegistration) Descriptor() ([]byte, []int) {
+// Deprecated: Use RegistrationAck.ProtoReflect.Descriptor instead.
+func (*RegistrationAck) Descriptor() ([]byte, []int) {
 	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *BrowserRegistration) GetBrowserId() string {
+func (x *RegistrationAck) GetAccepted() bool {
 	if x != nil {
-		return x.BrowserId
+		return x.Accepted
 	}
-	return ""
+	return false
 }
 
-func (x *BrowserRegistration) GetDescription() string {
+func (x *RegistrationAck) GetReason() string {
 	if x != nil {
-		return x.Description
+		return x.Reason
 	}
 	return ""
 }
 
-func (x *BrowserRegistration) GetOnline() bool {
+func (x *RegistrationAck) GetProtocolVersion() uint32 {
 	if x != nil {
-		return x.Online
+		return x.ProtocolVersion
 	}
-	return false
+	return 0
 }
 
-// 命令执行输出（流式：多条消息，done=true 结束）
-type ExecOutput struct {
+func (x *RegistrationAck) GetFeatures() []string {
+	if x != nil {
+		return x.Features
+	}
+	return nil
+}
+
+// PathPermission 一个路径（含子路径）的权限，三者均为 false 表示禁止访问
+type PathPermission struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Stdout        string                 `protobuf:"bytes,1,opt,name=stdout,proto3" json:"stdout,omitempty"`
-	Stderr        string                 `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
-	ExitCode      int32                  `protobuf:"varint,3,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
-	Done          bool                   `protobuf:"varint,4,opt,name=done,proto3" json:"done,omitempty"`      // true = 最后一条
-	Workdir       string                 `protobuf:"bytes,5,opt,name=workdir,proto3" json:"workdir,omitempty"` // 执行后的 cwd
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Read          bool                   `protobuf:"varint,2,opt,name=read,proto3" json:"read,omitempty"`   // 读取文件
+	Write         bool                   `protobuf:"varint,3,opt,name=write,proto3" json:"write,omitempty"` // 写入、编辑文件
+	Exec          bool                   `protobuf:"varint,4,opt,name=exec,proto3" json:"exec,omitempty"`   // 作为命令工作目录
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExecOutput) Reset() {
-	*x = ExecOutput{}
+func (x *PathPermission) Reset() {
+	*x = PathPermission{}
 	mi := &file_epiral_v1_epiral_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExecOutput) String() string {
+func (x *PathPermission) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExecOutput) ProtoMessage() {}
+func (*PathPermission) ProtoMessage() {}
 
-func (x *ExecOutput) ProtoReflect() protoreflect.Message {
+func (x *PathPermission) ProtoReflect() protoreflect.Message {
 	mi := &file_epiral_v1_epiral_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -401,72 +881,2688 @@ func (x *ExecOutput) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExecOutput.ProtoReflect.Descriptor instead.
-func (*ExecOutput) Descriptor() ([]byte, []int) {
+// Deprecated: Use PathPermission.ProtoReflect.Descriptor instead.
+func (*PathPermission) Descriptor() ([]byte, []int) {
 	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *ExecOutput) GetStdout() string {
+func (x *PathPermission) GetPath() string {
 	if x != nil {
-		return x.Stdout
+		return x.Path
 	}
 	return ""
 }
 
-func (x *ExecOutput) GetStderr() string {
+func (x *PathPermission) GetRead() bool {
 	if x != nil {
-		return x.Stderr
+		return x.Read
 	}
-	return ""
+	return false
+}
+
+func (x *PathPermission) GetWrite() bool {
+	if x != nil {
+		return x.Write
+	}
+	return false
+}
+
+func (x *PathPermission) GetExec() bool {
+	if x != nil {
+		return x.Exec
+	}
+	return false
+}
+
+// 浏览器上线/下线通知
+type BrowserRegistration struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BrowserId     string                 `protobuf:"bytes,1,opt,name=browser_id,json=browserId,proto3" json:"browser_id,omitempty"` // --browser-id "my-chrome"
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`              // --browser-desc "My Chrome Browser"
+	Online        bool                   `protobuf:"varint,3,opt,name=online,proto3" json:"online,omitempty"`                       // true=插件已连接 SSE, false=插件断开
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BrowserRegistration) Reset() {
+	*x = BrowserRegistration{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrowserRegistration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrowserRegistration) ProtoMessage() {}
+
+func (x *BrowserRegistration) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrowserRegistration.ProtoReflect.Descriptor instead.
+func (*BrowserRegistration) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BrowserRegistration) GetBrowserId() string {
+	if x != nil {
+		return x.BrowserId
+	}
+	return ""
+}
+
+func (x *BrowserRegistration) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *BrowserRegistration) GetOnline() bool {
+	if x != nil {
+		return x.Online
+	}
+	return false
+}
+
+// 命令执行输出（流式：多条消息，done=true 结束）
+// stdout/stderr 按到达顺序合并缓冲，每 50ms / 32KB 分块发送（不按行缓冲，块边界不切断 UTF-8 字符），
+// 每条消息只含其中一个流，按 seq 排序即可还原两者的相对顺序。
+type ExecOutput struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Stdout          string                 `protobuf:"bytes,1,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr          string                 `protobuf:"bytes,2,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	ExitCode        int32                  `protobuf:"varint,3,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Done            bool                   `protobuf:"varint,4,opt,name=done,proto3" json:"done,omitempty"`                                              // true = 最后一条
+	Workdir         string                 `protobuf:"bytes,5,opt,name=workdir,proto3" json:"workdir,omitempty"`                                         // 执行后的 cwd（会话模式下为命令执行后 shell 的真实目录）
+	Seq             int64                  `protobuf:"varint,6,opt,name=seq,proto3" json:"seq,omitempty"`                                                // 同一请求内从 1 递增（含 done 消息）
+	StdoutTruncated bool                   `protobuf:"varint,7,opt,name=stdout_truncated,json=stdoutTruncated,proto3" json:"stdout_truncated,omitempty"` // done=true 时有效：stdout 超过上限，之后的输出被丢弃
+	StderrTruncated bool                   `protobuf:"varint,8,opt,name=stderr_truncated,json=stderrTruncated,proto3" json:"stderr_truncated,omitempty"` // done=true 时有效：stderr 超过上限，之后的输出被丢弃
+	Binary          bool                   `protobuf:"varint,9,opt,name=binary,proto3" json:"binary,omitempty"`                                          // 本块含非 UTF-8 字节（已替换为 U+FFFD）；done=true 时表示整个输出中出现过
+	Code            ErrorCode              `protobuf:"varint,10,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"`                    // done=true 时：命令未能运行完（无法启动、超时、被取消）的原因；
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ExecOutput) Reset() {
+	*x = ExecOutput{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecOutput) ProtoMessage() {}
+
+func (x *ExecOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecOutput.ProtoReflect.Descriptor instead.
+func (*ExecOutput) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ExecOutput) GetStdout() string {
+	if x != nil {
+		return x.Stdout
+	}
+	return ""
+}
+
+func (x *ExecOutput) GetStderr() string {
+	if x != nil {
+		return x.Stderr
+	}
+	return ""
 }
 
 func (x *ExecOutput) GetExitCode() int32 {
 	if x != nil {
-		return x.ExitCode
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *ExecOutput) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *ExecOutput) GetWorkdir() string {
+	if x != nil {
+		return x.Workdir
+	}
+	return ""
+}
+
+func (x *ExecOutput) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ExecOutput) GetStdoutTruncated() bool {
+	if x != nil {
+		return x.StdoutTruncated
+	}
+	return false
+}
+
+func (x *ExecOutput) GetStderrTruncated() bool {
+	if x != nil {
+		return x.StderrTruncated
+	}
+	return false
+}
+
+func (x *ExecOutput) GetBinary() bool {
+	if x != nil {
+		return x.Binary
+	}
+	return false
+}
+
+func (x *ExecOutput) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+// 文件读取结果
+type FileContent struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Content    string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`                          // 行模式的内容，保留原始换行符
+	TotalLines int64                  `protobuf:"varint,2,opt,name=total_lines,json=totalLines,proto3" json:"total_lines,omitempty"` // 行模式的总行数
+	FileSize   int64                  `protobuf:"varint,3,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"`       // 实际文件大小（字节）
+	Error      string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`                              // 非空表示失败
+	Data       []byte                 `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`                                // 字节模式的原始内容
+	Sha256     string                 `protobuf:"bytes,6,opt,name=sha256,proto3" json:"sha256,omitempty"`                            // 整个文件的 SHA-256（hex），可作为写入/编辑的 expected_hash；
+	// 字节模式只在本次读取覆盖整个文件时给出
+	MtimeMs       int64     `protobuf:"varint,7,opt,name=mtime_ms,json=mtimeMs,proto3" json:"mtime_ms,omitempty"` // 修改时间（Unix 毫秒）
+	Code          ErrorCode `protobuf:"varint,8,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"`
+	Binary        bool      `protobuf:"varint,9,opt,name=binary,proto3" json:"binary,omitempty"` // 行模式：文件含非 UTF-8 字节，content 中已替换为 U+FFFD，原始内容请用字节模式读取
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileContent) Reset() {
+	*x = FileContent{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileContent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileContent) ProtoMessage() {}
+
+func (x *FileContent) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileContent.ProtoReflect.Descriptor instead.
+func (*FileContent) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FileContent) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *FileContent) GetTotalLines() int64 {
+	if x != nil {
+		return x.TotalLines
+	}
+	return 0
+}
+
+func (x *FileContent) GetFileSize() int64 {
+	if x != nil {
+		return x.FileSize
+	}
+	return 0
+}
+
+func (x *FileContent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *FileContent) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *FileContent) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *FileContent) GetMtimeMs() int64 {
+	if x != nil {
+		return x.MtimeMs
+	}
+	return 0
+}
+
+func (x *FileContent) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+func (x *FileContent) GetBinary() bool {
+	if x != nil {
+		return x.Binary
+	}
+	return false
+}
+
+// 目录列表
+type DirListing struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`            // 规范化后的目录路径
+	Entries       []*DirEntry            `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`      // 深度优先、按名称排序
+	Truncated     bool                   `protobuf:"varint,3,opt,name=truncated,proto3" json:"truncated,omitempty"` // 达到 limit，后面还有条目
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`          // 非空表示失败
+	Code          ErrorCode              `protobuf:"varint,5,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DirListing) Reset() {
+	*x = DirListing{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DirListing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DirListing) ProtoMessage() {}
+
+func (x *DirListing) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DirListing.ProtoReflect.Descriptor instead.
+func (*DirListing) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DirListing) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DirListing) GetEntries() []*DirEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *DirListing) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *DirListing) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *DirListing) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+type DirEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // 相对所列目录的路径（/ 分隔），递归时含子目录
+	Type          EntryType              `protobuf:"varint,2,opt,name=type,proto3,enum=epiral.v1.EntryType" json:"type,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`                              // 字节数（目录为 0）
+	Mode          uint32                 `protobuf:"varint,4,opt,name=mode,proto3" json:"mode,omitempty"`                              // 权限位，如 0o644
+	MtimeMs       int64                  `protobuf:"varint,5,opt,name=mtime_ms,json=mtimeMs,proto3" json:"mtime_ms,omitempty"`         // 修改时间（Unix 毫秒）
+	LinkTarget    string                 `protobuf:"bytes,6,opt,name=link_target,json=linkTarget,proto3" json:"link_target,omitempty"` // 符号链接的目标（原样返回，不跟随）
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DirEntry) Reset() {
+	*x = DirEntry{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DirEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DirEntry) ProtoMessage() {}
+
+func (x *DirEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DirEntry.ProtoReflect.Descriptor instead.
+func (*DirEntry) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DirEntry) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DirEntry) GetType() EntryType {
+	if x != nil {
+		return x.Type
+	}
+	return EntryType_ENTRY_TYPE_UNKNOWN
+}
+
+func (x *DirEntry) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *DirEntry) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *DirEntry) GetMtimeMs() int64 {
+	if x != nil {
+		return x.MtimeMs
+	}
+	return 0
+}
+
+func (x *DirEntry) GetLinkTarget() string {
+	if x != nil {
+		return x.LinkTarget
+	}
+	return ""
+}
+
+// 搜索结果（流式：多批，done=true 结束）
+type SearchResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Matches       []*SearchMatch         `protobuf:"bytes,1,rep,name=matches,proto3" json:"matches,omitempty"`
+	Done          bool                   `protobuf:"varint,2,opt,name=done,proto3" json:"done,omitempty"`
+	Truncated     bool                   `protobuf:"varint,3,opt,name=truncated,proto3" json:"truncated,omitempty"`                              // 达到 max_results / max_bytes，提前结束
+	FilesSearched int64                  `protobuf:"varint,4,opt,name=files_searched,json=filesSearched,proto3" json:"files_searched,omitempty"` // 结束时：实际搜索的文件数
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`                                       // 非空表示失败
+	Code          ErrorCode              `protobuf:"varint,6,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResult) Reset() {
+	*x = SearchResult{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResult) ProtoMessage() {}
+
+func (x *SearchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResult.ProtoReflect.Descriptor instead.
+func (*SearchResult) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SearchResult) GetMatches() []*SearchMatch {
+	if x != nil {
+		return x.Matches
+	}
+	return nil
+}
+
+func (x *SearchResult) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *SearchResult) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *SearchResult) GetFilesSearched() int64 {
+	if x != nil {
+		return x.FilesSearched
+	}
+	return 0
+}
+
+func (x *SearchResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *SearchResult) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+type SearchMatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`      // 文件的绝对路径
+	Line          int32                  `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`     // 行号（1-based）；只按 glob 搜索时为 0
+	Column        int32                  `protobuf:"varint,3,opt,name=column,proto3" json:"column,omitempty"` // 第一处匹配的字节列（1-based）
+	Text          string                 `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`      // 匹配的行（过长时截断）
+	Before        []string               `protobuf:"bytes,5,rep,name=before,proto3" json:"before,omitempty"`  // 之前的上下文行
+	After         []string               `protobuf:"bytes,6,rep,name=after,proto3" json:"after,omitempty"`    // 之后的上下文行
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchMatch) Reset() {
+	*x = SearchMatch{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchMatch) ProtoMessage() {}
+
+func (x *SearchMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchMatch.ProtoReflect.Descriptor instead.
+func (*SearchMatch) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SearchMatch) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *SearchMatch) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *SearchMatch) GetColumn() int32 {
+	if x != nil {
+		return x.Column
+	}
+	return 0
+}
+
+func (x *SearchMatch) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SearchMatch) GetBefore() []string {
+	if x != nil {
+		return x.Before
+	}
+	return nil
+}
+
+func (x *SearchMatch) GetAfter() []string {
+	if x != nil {
+		return x.After
+	}
+	return nil
+}
+
+// 应用补丁的结果。失败时没有任何文件被修改，files 中给出每个文件、每个 hunk 的情况。
+type PatchResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"` // 非空表示失败（格式错误，或有文件/hunk 无法应用）
+	Files         []*PatchFileResult     `protobuf:"bytes,3,rep,name=files,proto3" json:"files,omitempty"`
+	Code          ErrorCode              `protobuf:"varint,4,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"` // 文件有问题时取第一个失败文件的 code
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PatchResult) Reset() {
+	*x = PatchResult{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PatchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PatchResult) ProtoMessage() {}
+
+func (x *PatchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PatchResult.ProtoReflect.Descriptor instead.
+func (*PatchResult) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PatchResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *PatchResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *PatchResult) GetFiles() []*PatchFileResult {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *PatchResult) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+type PatchFileResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OldPath       string                 `protobuf:"bytes,1,opt,name=old_path,json=oldPath,proto3" json:"old_path,omitempty"` // 规范化后的路径，新建时为空
+	NewPath       string                 `protobuf:"bytes,2,opt,name=new_path,json=newPath,proto3" json:"new_path,omitempty"` // 规范化后的路径，删除时为空
+	Action        PatchAction            `protobuf:"varint,3,opt,name=action,proto3,enum=epiral.v1.PatchAction" json:"action,omitempty"`
+	Hunks         []*HunkResult          `protobuf:"bytes,4,rep,name=hunks,proto3" json:"hunks,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`                         // 文件级错误（路径不允许、文件已存在/不存在等）
+	Change        *FileChange            `protobuf:"bytes,6,opt,name=change,proto3" json:"change,omitempty"`                       // 应用成功时：这个文件的变化（dry_run 时为预览）
+	Code          ErrorCode              `protobuf:"varint,7,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"` // 文件级错误的类别；有 hunk 无法应用时为 CONFLICT
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PatchFileResult) Reset() {
+	*x = PatchFileResult{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PatchFileResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PatchFileResult) ProtoMessage() {}
+
+func (x *PatchFileResult) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PatchFileResult.ProtoReflect.Descriptor instead.
+func (*PatchFileResult) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PatchFileResult) GetOldPath() string {
+	if x != nil {
+		return x.OldPath
+	}
+	return ""
+}
+
+func (x *PatchFileResult) GetNewPath() string {
+	if x != nil {
+		return x.NewPath
+	}
+	return ""
+}
+
+func (x *PatchFileResult) GetAction() PatchAction {
+	if x != nil {
+		return x.Action
+	}
+	return PatchAction_PATCH_ACTION_MODIFY
+}
+
+func (x *PatchFileResult) GetHunks() []*HunkResult {
+	if x != nil {
+		return x.Hunks
+	}
+	return nil
+}
+
+func (x *PatchFileResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *PatchFileResult) GetChange() *FileChange {
+	if x != nil {
+		return x.Change
+	}
+	return nil
+}
+
+func (x *PatchFileResult) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+type HunkResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"` // 文件内第几个 hunk（1-based）
+	Applied       bool                   `protobuf:"varint,2,opt,name=applied,proto3" json:"applied,omitempty"`
+	Line          int32                  `protobuf:"varint,3,opt,name=line,proto3" json:"line,omitempty"`     // 实际应用的位置（原文件行号，1-based）
+	Offset        int32                  `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"` // 与 hunk 头中行号的偏差
+	Fuzz          int32                  `protobuf:"varint,5,opt,name=fuzz,proto3" json:"fuzz,omitempty"`     // 忽略的首尾上下文行数
+	Error         string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`    // 无法应用的原因
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HunkResult) Reset() {
+	*x = HunkResult{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HunkResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HunkResult) ProtoMessage() {}
+
+func (x *HunkResult) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HunkResult.ProtoReflect.Descriptor instead.
+func (*HunkResult) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *HunkResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *HunkResult) GetApplied() bool {
+	if x != nil {
+		return x.Applied
+	}
+	return false
+}
+
+func (x *HunkResult) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *HunkResult) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *HunkResult) GetFuzz() int32 {
+	if x != nil {
+		return x.Fuzz
+	}
+	return 0
+}
+
+func (x *HunkResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// stat 结果。最后一级是符号链接时返回链接本身的信息（不跟随）。
+type FileStat struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`      // 规范化后的路径
+	Exists        bool                   `protobuf:"varint,2,opt,name=exists,proto3" json:"exists,omitempty"` // false 表示路径不存在（不算错误）
+	Info          *DirEntry              `protobuf:"bytes,3,opt,name=info,proto3" json:"info,omitempty"`      // exists 时有效，name 为文件名
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`    // 非空表示失败
+	Code          ErrorCode              `protobuf:"varint,5,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileStat) Reset() {
+	*x = FileStat{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileStat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileStat) ProtoMessage() {}
+
+func (x *FileStat) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileStat.ProtoReflect.Descriptor instead.
+func (*FileStat) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *FileStat) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileStat) GetExists() bool {
+	if x != nil {
+		return x.Exists
+	}
+	return false
+}
+
+func (x *FileStat) GetInfo() *DirEntry {
+	if x != nil {
+		return x.Info
+	}
+	return nil
+}
+
+func (x *FileStat) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *FileStat) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+// 写入/编辑等操作结果
+type OpResult struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Success          bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error            string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Code             ErrorCode              `protobuf:"varint,3,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"`                          // 失败的类别，便于 Agent 区分处理
+	Sha256           string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`                                                // 冲突时为文件当前的 SHA-256（hex）
+	FailedEdit       int32                  `protobuf:"varint,5,opt,name=failed_edit,json=failedEdit,proto3" json:"failed_edit,omitempty"`                     // MultiEdit 失败时为出错的编辑序号（1-based），0 表示不适用
+	Snippet          string                 `protobuf:"bytes,6,opt,name=snippet,proto3" json:"snippet,omitempty"`                                              // 编辑成功时：改动处前后几行的新内容
+	SnippetStartLine int32                  `protobuf:"varint,7,opt,name=snippet_start_line,json=snippetStartLine,proto3" json:"snippet_start_line,omitempty"` // snippet 第一行的行号（1-based）
+	Change           *FileChange            `protobuf:"bytes,8,opt,name=change,proto3" json:"change,omitempty"`                                                // 写入/编辑成功时：文件的变化
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *OpResult) Reset() {
+	*x = OpResult{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OpResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpResult) ProtoMessage() {}
+
+func (x *OpResult) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpResult.ProtoReflect.Descriptor instead.
+func (*OpResult) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *OpResult) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *OpResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *OpResult) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+func (x *OpResult) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *OpResult) GetFailedEdit() int32 {
+	if x != nil {
+		return x.FailedEdit
+	}
+	return 0
+}
+
+func (x *OpResult) GetSnippet() string {
+	if x != nil {
+		return x.Snippet
+	}
+	return ""
+}
+
+func (x *OpResult) GetSnippetStartLine() int32 {
+	if x != nil {
+		return x.SnippetStartLine
+	}
+	return 0
+}
+
+func (x *OpResult) GetChange() *FileChange {
+	if x != nil {
+		return x.Change
+	}
+	return nil
+}
+
+// 写入/编辑后文件的变化，Agent 不必重新读取即可核对
+type FileChange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sha256        string                 `protobuf:"bytes,1,opt,name=sha256,proto3" json:"sha256,omitempty"`                                     // 新内容的 SHA-256（hex），可作为下次写入/编辑的 expected_hash；删除时为空
+	LineCount     int64                  `protobuf:"varint,2,opt,name=line_count,json=lineCount,proto3" json:"line_count,omitempty"`             // 新内容的行数，不是文本时为 0
+	Diff          string                 `protobuf:"bytes,3,opt,name=diff,proto3" json:"diff,omitempty"`                                         // 旧内容到新内容的 unified diff（3 行上下文）
+	DiffTruncated bool                   `protobuf:"varint,4,opt,name=diff_truncated,json=diffTruncated,proto3" json:"diff_truncated,omitempty"` // diff 超过 64 KiB 被截断；或内容超过 1 MiB、不是文本，未生成 diff
+	LinesAdded    int32                  `protobuf:"varint,5,opt,name=lines_added,json=linesAdded,proto3" json:"lines_added,omitempty"`
+	LinesRemoved  int32                  `protobuf:"varint,6,opt,name=lines_removed,json=linesRemoved,proto3" json:"lines_removed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileChange) Reset() {
+	*x = FileChange{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileChange) ProtoMessage() {}
+
+func (x *FileChange) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileChange.ProtoReflect.Descriptor instead.
+func (*FileChange) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *FileChange) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *FileChange) GetLineCount() int64 {
+	if x != nil {
+		return x.LineCount
+	}
+	return 0
+}
+
+func (x *FileChange) GetDiff() string {
+	if x != nil {
+		return x.Diff
+	}
+	return ""
+}
+
+func (x *FileChange) GetDiffTruncated() bool {
+	if x != nil {
+		return x.DiffTruncated
+	}
+	return false
+}
+
+func (x *FileChange) GetLinesAdded() int32 {
+	if x != nil {
+		return x.LinesAdded
+	}
+	return 0
+}
+
+func (x *FileChange) GetLinesRemoved() int32 {
+	if x != nil {
+		return x.LinesRemoved
+	}
+	return 0
+}
+
+// 分块传输的进度/结果。上传的每条下行消息各回一条；
+// 下载开始时回一条（带 size/sha256），结束时再回一条 done=true。
+type TransferStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`                        // 上传：已接收的字节数，续传从这里开始；下载：已发送的字节数
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`                            // 文件总大小
+	Sha256        string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`                         // 整个文件的 SHA-256（hex）：上传提交后、下载开始和结束时
+	ChunkSize     uint32                 `protobuf:"varint,5,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"` // 建议的块大小
+	Done          bool                   `protobuf:"varint,6,opt,name=done,proto3" json:"done,omitempty"`                            // 上传已提交 / 下载已发送完毕
+	Error         string                 `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`                           // 非空表示失败
+	Code          ErrorCode              `protobuf:"varint,8,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferStatus) Reset() {
+	*x = TransferStatus{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferStatus) ProtoMessage() {}
+
+func (x *TransferStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferStatus.ProtoReflect.Descriptor instead.
+func (*TransferStatus) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *TransferStatus) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *TransferStatus) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *TransferStatus) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *TransferStatus) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *TransferStatus) GetChunkSize() uint32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+func (x *TransferStatus) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *TransferStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *TransferStatus) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+// 下载的数据块
+type TransferChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Sha256        string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"` // 本块的 SHA-256（hex）
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferChunk) Reset() {
+	*x = TransferChunk{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferChunk) ProtoMessage() {}
+
+func (x *TransferChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferChunk.ProtoReflect.Descriptor instead.
+func (*TransferChunk) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *TransferChunk) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
+	}
+	return ""
+}
+
+func (x *TransferChunk) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *TransferChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *TransferChunk) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+// 浏览器命令执行结果
+type BrowserExecOutput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ResultJson    string                 `protobuf:"bytes,1,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"` // 插件回传的 Response JSON（bb-browser 协议）
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`                             // daemon 级别的错误（插件未连接/超时等）
+	Done          bool                   `protobuf:"varint,3,opt,name=done,proto3" json:"done,omitempty"`
+	Code          ErrorCode              `protobuf:"varint,4,opt,name=code,proto3,enum=epiral.v1.ErrorCode" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BrowserExecOutput) Reset() {
+	*x = BrowserExecOutput{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BrowserExecOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BrowserExecOutput) ProtoMessage() {}
+
+func (x *BrowserExecOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BrowserExecOutput.ProtoReflect.Descriptor instead.
+func (*BrowserExecOutput) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *BrowserExecOutput) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+func (x *BrowserExecOutput) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *BrowserExecOutput) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *BrowserExecOutput) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+type Ping struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp     int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ping) Reset() {
+	*x = Ping{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ping) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ping) ProtoMessage() {}
+
+func (x *Ping) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ping.ProtoReflect.Descriptor instead.
+func (*Ping) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Ping) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type Pong struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp     int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Pong) Reset() {
+	*x = Pong{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Pong) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Pong) ProtoMessage() {}
+
+func (x *Pong) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Pong.ProtoReflect.Descriptor instead.
+func (*Pong) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *Pong) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type ConnectResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	RequestId string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*ConnectResponse_Exec
+	//	*ConnectResponse_ReadFile
+	//	*ConnectResponse_WriteFile
+	//	*ConnectResponse_EditFile
+	//	*ConnectResponse_Pong
+	//	*ConnectResponse_BrowserExec
+	//	*ConnectResponse_Cancel
+	//	*ConnectResponse_ExecInput
+	//	*ConnectResponse_ExecResize
+	//	*ConnectResponse_UploadBegin
+	//	*ConnectResponse_UploadChunk
+	//	*ConnectResponse_UploadCommit
+	//	*ConnectResponse_TransferAbort
+	//	*ConnectResponse_DownloadBegin
+	//	*ConnectResponse_ListDir
+	//	*ConnectResponse_Search
+	//	*ConnectResponse_Stat
+	//	*ConnectResponse_Remove
+	//	*ConnectResponse_Move
+	//	*ConnectResponse_Copy
+	//	*ConnectResponse_Mkdir
+	//	*ConnectResponse_Chmod
+	//	*ConnectResponse_MultiEdit
+	//	*ConnectResponse_ApplyPatch
+	//	*ConnectResponse_RegistrationAck
+	Payload       isConnectResponse_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectResponse) Reset() {
+	*x = ConnectResponse{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectResponse) ProtoMessage() {}
+
+func (x *ConnectResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectResponse.ProtoReflect.Descriptor instead.
+func (*ConnectResponse) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ConnectResponse) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *ConnectResponse) GetPayload() isConnectResponse_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetExec() *ExecRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Exec); ok {
+			return x.Exec
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetReadFile() *ReadFileRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_ReadFile); ok {
+			return x.ReadFile
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetWriteFile() *WriteFileRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_WriteFile); ok {
+			return x.WriteFile
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetEditFile() *EditFileRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_EditFile); ok {
+			return x.EditFile
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetPong() *Pong {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Pong); ok {
+			return x.Pong
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetBrowserExec() *BrowserExecRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_BrowserExec); ok {
+			return x.BrowserExec
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetCancel() *CancelRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Cancel); ok {
+			return x.Cancel
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetExecInput() *ExecInput {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_ExecInput); ok {
+			return x.ExecInput
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetExecResize() *ExecResize {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_ExecResize); ok {
+			return x.ExecResize
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetUploadBegin() *UploadBegin {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_UploadBegin); ok {
+			return x.UploadBegin
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetUploadChunk() *UploadChunk {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_UploadChunk); ok {
+			return x.UploadChunk
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetUploadCommit() *UploadCommit {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_UploadCommit); ok {
+			return x.UploadCommit
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetTransferAbort() *TransferAbort {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_TransferAbort); ok {
+			return x.TransferAbort
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetDownloadBegin() *DownloadBegin {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_DownloadBegin); ok {
+			return x.DownloadBegin
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetListDir() *ListDirRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_ListDir); ok {
+			return x.ListDir
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetSearch() *SearchRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Search); ok {
+			return x.Search
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetStat() *StatRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Stat); ok {
+			return x.Stat
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetRemove() *RemoveRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Remove); ok {
+			return x.Remove
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetMove() *MoveRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Move); ok {
+			return x.Move
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetCopy() *CopyRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Copy); ok {
+			return x.Copy
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetMkdir() *MkdirRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Mkdir); ok {
+			return x.Mkdir
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetChmod() *ChmodRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_Chmod); ok {
+			return x.Chmod
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetMultiEdit() *MultiEditRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_MultiEdit); ok {
+			return x.MultiEdit
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetApplyPatch() *ApplyPatchRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_ApplyPatch); ok {
+			return x.ApplyPatch
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetRegistrationAck() *RegistrationAck {
+	if x != nil {
+		if x, ok := x.Payload.(*ConnectResponse_RegistrationAck); ok {
+			return x.RegistrationAck
+		}
+	}
+	return nil
+}
+
+type isConnectResponse_Payload interface {
+	isConnectResponse_Payload()
+}
+
+type ConnectResponse_Exec struct {
+	// Computer
+	Exec *ExecRequest `protobuf:"bytes,10,opt,name=exec,proto3,oneof"`
+}
+
+type ConnectResponse_ReadFile struct {
+	ReadFile *ReadFileRequest `protobuf:"bytes,11,opt,name=read_file,json=readFile,proto3,oneof"`
+}
+
+type ConnectResponse_WriteFile struct {
+	WriteFile *WriteFileRequest `protobuf:"bytes,12,opt,name=write_file,json=writeFile,proto3,oneof"`
+}
+
+type ConnectResponse_EditFile struct {
+	EditFile *EditFileRequest `protobuf:"bytes,13,opt,name=edit_file,json=editFile,proto3,oneof"`
+}
+
+type ConnectResponse_Pong struct {
+	Pong *Pong `protobuf:"bytes,14,opt,name=pong,proto3,oneof"`
+}
+
+type ConnectResponse_BrowserExec struct {
+	// Browser
+	BrowserExec *BrowserExecRequest `protobuf:"bytes,15,opt,name=browser_exec,json=browserExec,proto3,oneof"`
+}
+
+type ConnectResponse_Cancel struct {
+	// 控制
+	Cancel *CancelRequest `protobuf:"bytes,16,opt,name=cancel,proto3,oneof"`
+}
+
+type ConnectResponse_ExecInput struct {
+	// 交互式执行（pty=true 的 Exec）
+	ExecInput *ExecInput `protobuf:"bytes,17,opt,name=exec_input,json=execInput,proto3,oneof"`
+}
+
+type ConnectResponse_ExecResize struct {
+	ExecResize *ExecResize `protobuf:"bytes,18,opt,name=exec_resize,json=execResize,proto3,oneof"`
+}
+
+type ConnectResponse_UploadBegin struct {
+	// 分块传输
+	UploadBegin *UploadBegin `protobuf:"bytes,19,opt,name=upload_begin,json=uploadBegin,proto3,oneof"`
+}
+
+type ConnectResponse_UploadChunk struct {
+	UploadChunk *UploadChunk `protobuf:"bytes,20,opt,name=upload_chunk,json=uploadChunk,proto3,oneof"`
+}
+
+type ConnectResponse_UploadCommit struct {
+	UploadCommit *UploadCommit `protobuf:"bytes,21,opt,name=upload_commit,json=uploadCommit,proto3,oneof"`
+}
+
+type ConnectResponse_TransferAbort struct {
+	TransferAbort *TransferAbort `protobuf:"bytes,22,opt,name=transfer_abort,json=transferAbort,proto3,oneof"`
+}
+
+type ConnectResponse_DownloadBegin struct {
+	DownloadBegin *DownloadBegin `protobuf:"bytes,23,opt,name=download_begin,json=downloadBegin,proto3,oneof"`
+}
+
+type ConnectResponse_ListDir struct {
+	ListDir *ListDirRequest `protobuf:"bytes,24,opt,name=list_dir,json=listDir,proto3,oneof"`
+}
+
+type ConnectResponse_Search struct {
+	Search *SearchRequest `protobuf:"bytes,25,opt,name=search,proto3,oneof"`
+}
+
+type ConnectResponse_Stat struct {
+	// 文件管理
+	Stat *StatRequest `protobuf:"bytes,26,opt,name=stat,proto3,oneof"`
+}
+
+type ConnectResponse_Remove struct {
+	Remove *RemoveRequest `protobuf:"bytes,27,opt,name=remove,proto3,oneof"`
+}
+
+type ConnectResponse_Move struct {
+	Move *MoveRequest `protobuf:"bytes,28,opt,name=move,proto3,oneof"`
+}
+
+type ConnectResponse_Copy struct {
+	Copy *CopyRequest `protobuf:"bytes,29,opt,name=copy,proto3,oneof"`
+}
+
+type ConnectResponse_Mkdir struct {
+	Mkdir *MkdirRequest `protobuf:"bytes,30,opt,name=mkdir,proto3,oneof"`
+}
+
+type ConnectResponse_Chmod struct {
+	Chmod *ChmodRequest `protobuf:"bytes,31,opt,name=chmod,proto3,oneof"`
+}
+
+type ConnectResponse_MultiEdit struct {
+	MultiEdit *MultiEditRequest `protobuf:"bytes,32,opt,name=multi_edit,json=multiEdit,proto3,oneof"`
+}
+
+type ConnectResponse_ApplyPatch struct {
+	ApplyPatch *ApplyPatchRequest `protobuf:"bytes,33,opt,name=apply_patch,json=applyPatch,proto3,oneof"`
+}
+
+type ConnectResponse_RegistrationAck struct {
+	// 注册
+	RegistrationAck *RegistrationAck `protobuf:"bytes,34,opt,name=registration_ack,json=registrationAck,proto3,oneof"`
+}
+
+func (*ConnectResponse_Exec) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_ReadFile) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_WriteFile) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_EditFile) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Pong) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_BrowserExec) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Cancel) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_ExecInput) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_ExecResize) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_UploadBegin) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_UploadChunk) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_UploadCommit) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_TransferAbort) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_DownloadBegin) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_ListDir) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Search) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Stat) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Remove) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Move) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Copy) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Mkdir) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_Chmod) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_MultiEdit) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_ApplyPatch) isConnectResponse_Payload() {}
+
+func (*ConnectResponse_RegistrationAck) isConnectResponse_Payload() {}
+
+// 执行命令
+type ExecRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Workdir       string                 `protobuf:"bytes,2,opt,name=workdir,proto3" json:"workdir,omitempty"`                                                                   // 工作目录（空 = home_dir）
+	TimeoutMs     int32                  `protobuf:"varint,3,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`                                             // 超时毫秒（0 = 默认 30000）
+	SessionId     string                 `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`                                              // 持久 Shell 会话 ID（cd/export 等状态跨命令保留），空 = one-shot
+	Pty           bool                   `protobuf:"varint,5,opt,name=pty,proto3" json:"pty,omitempty"`                                                                          // 在伪终端中运行（交互式），输出合并到 stdout；不能与 session_id 同用
+	Cols          uint32                 `protobuf:"varint,6,opt,name=cols,proto3" json:"cols,omitempty"`                                                                        // pty 模式的终端列数（0 = 80）
+	Rows          uint32                 `protobuf:"varint,7,opt,name=rows,proto3" json:"rows,omitempty"`                                                                        // pty 模式的终端行数（0 = 24）
+	Env           map[string]string      `protobuf:"bytes,8,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // 追加/覆盖的环境变量（在继承的环境之上）；会话模式下只对本条命令生效
+	InheritEnv    InheritEnv             `protobuf:"varint,9,opt,name=inherit_env,json=inheritEnv,proto3,enum=epiral.v1.InheritEnv" json:"inherit_env,omitempty"`                // 从 daemon 继承哪些环境变量；会话模式下在创建会话时确定，之后不能改变
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecRequest) Reset() {
+	*x = ExecRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecRequest) ProtoMessage() {}
+
+func (x *ExecRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecRequest.ProtoReflect.Descriptor instead.
+func (*ExecRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ExecRequest) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *ExecRequest) GetWorkdir() string {
+	if x != nil {
+		return x.Workdir
+	}
+	return ""
+}
+
+func (x *ExecRequest) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *ExecRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ExecRequest) GetPty() bool {
+	if x != nil {
+		return x.Pty
+	}
+	return false
+}
+
+func (x *ExecRequest) GetCols() uint32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
+func (x *ExecRequest) GetRows() uint32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *ExecRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *ExecRequest) GetInheritEnv() InheritEnv {
+	if x != nil {
+		return x.InheritEnv
+	}
+	return InheritEnv_INHERIT_ENV_UNSPECIFIED
+}
+
+// 交互式执行的输入。request_id 与目标 Exec 相同，按到达顺序写入终端；
+// 紧跟 Exec 发送即可，终端启动前到达的输入会先排队。命令不读输入时最多积压 256 条，
+// 超出的被丢弃，并回一条 code=UNAVAILABLE 的 ExecOutput（done=false）说明，连续丢弃只报告一次。
+type ExecInput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"` // 写入终端的原始字节（按键、粘贴内容）
+	Eof           bool                   `protobuf:"varint,2,opt,name=eof,proto3" json:"eof,omitempty"`  // 在 data 之后发送 EOF（^D）
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecInput) Reset() {
+	*x = ExecInput{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecInput) ProtoMessage() {}
+
+func (x *ExecInput) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecInput.ProtoReflect.Descriptor instead.
+func (*ExecInput) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ExecInput) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ExecInput) GetEof() bool {
+	if x != nil {
+		return x.Eof
+	}
+	return false
+}
+
+// 调整交互式执行的终端大小。request_id 与目标 Exec 相同，终端启动前到达的在启动时生效。
+type ExecResize struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cols          uint32                 `protobuf:"varint,1,opt,name=cols,proto3" json:"cols,omitempty"`
+	Rows          uint32                 `protobuf:"varint,2,opt,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecResize) Reset() {
+	*x = ExecResize{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecResize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecResize) ProtoMessage() {}
+
+func (x *ExecResize) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecResize.ProtoReflect.Descriptor instead.
+func (*ExecResize) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ExecResize) GetCols() uint32 {
+	if x != nil {
+		return x.Cols
+	}
+	return 0
+}
+
+func (x *ExecResize) GetRows() uint32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+// 读文件
+type ReadFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Offset        int32                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`                  // 行偏移（0-based），行模式
+	Limit         int32                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`                    // 行数（0 = 全部，默认 2000），行模式
+	MaxSize       int64                  `protobuf:"varint,4,opt,name=max_size,json=maxSize,proto3" json:"max_size,omitempty"` // 字节上限（0 = 默认 256KB）；字节模式下限制本次读取的长度
+	Mode          ReadMode               `protobuf:"varint,5,opt,name=mode,proto3,enum=epiral.v1.ReadMode" json:"mode,omitempty"`
+	ByteOffset    int64                  `protobuf:"varint,6,opt,name=byte_offset,json=byteOffset,proto3" json:"byte_offset,omitempty"` // 字节偏移，字节模式
+	ByteLength    int64                  `protobuf:"varint,7,opt,name=byte_length,json=byteLength,proto3" json:"byte_length,omitempty"` // 读取字节数（0 = 到文件末尾），字节模式
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReadFileRequest) Reset() {
+	*x = ReadFileRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReadFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReadFileRequest) ProtoMessage() {}
+
+func (x *ReadFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReadFileRequest.ProtoReflect.Descriptor instead.
+func (*ReadFileRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *ReadFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ReadFileRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ReadFileRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ReadFileRequest) GetMaxSize() int64 {
+	if x != nil {
+		return x.MaxSize
+	}
+	return 0
+}
+
+func (x *ReadFileRequest) GetMode() ReadMode {
+	if x != nil {
+		return x.Mode
+	}
+	return ReadMode_READ_MODE_LINES
+}
+
+func (x *ReadFileRequest) GetByteOffset() int64 {
+	if x != nil {
+		return x.ByteOffset
+	}
+	return 0
+}
+
+func (x *ReadFileRequest) GetByteLength() int64 {
+	if x != nil {
+		return x.ByteLength
+	}
+	return 0
+}
+
+// 写文件。先写临时文件再原子替换，保留已有文件的权限、属主和扩展属性；
+// 路径是符号链接时写入链接目标，链接本身不变。
+type WriteFileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`                               // 文本内容
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`                                     // 原始字节，binary=true 时使用
+	Binary        bool                   `protobuf:"varint,4,opt,name=binary,proto3" json:"binary,omitempty"`                                // true 时写入 data 而非 content
+	ExpectedHash  string                 `protobuf:"bytes,5,opt,name=expected_hash,json=expectedHash,proto3" json:"expected_hash,omitempty"` // 非空时要求文件当前的 SHA-256 与之相同（文件须已存在），否则以 CONFLICT 失败
+	Mode          uint32                 `protobuf:"varint,6,opt,name=mode,proto3" json:"mode,omitempty"`                                    // 新建文件的权限位（0 = 0o600）；已存在的文件保留原权限
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteFileRequest) Reset() {
+	*x = WriteFileRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteFileRequest) ProtoMessage() {}
+
+func (x *WriteFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteFileRequest.ProtoReflect.Descriptor instead.
+func (*WriteFileRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *WriteFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *WriteFileRequest) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *WriteFileRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *WriteFileRequest) GetBinary() bool {
+	if x != nil {
+		return x.Binary
+	}
+	return false
+}
+
+func (x *WriteFileRequest) GetExpectedHash() string {
+	if x != nil {
+		return x.ExpectedHash
+	}
+	return ""
+}
+
+func (x *WriteFileRequest) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+// 编辑文件。默认为精确的查找替换；mode 可选正则替换、按行号替换和插入。
+// 成功时 OpResult.snippet 返回改动处前后的内容，便于 Agent 核对。
+type EditFileRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Path             string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	OldString        string                 `protobuf:"bytes,2,opt,name=old_string,json=oldString,proto3" json:"old_string,omitempty"`          // REPLACE：要替换的文本；REGEX：正则（RE2）
+	NewString        string                 `protobuf:"bytes,3,opt,name=new_string,json=newString,proto3" json:"new_string,omitempty"`          // 替换/插入的内容；REGEX 中可用 $1、${name} 引用捕获组
+	ReplaceAll       bool                   `protobuf:"varint,4,opt,name=replace_all,json=replaceAll,proto3" json:"replace_all,omitempty"`      // REPLACE/REGEX：替换所有匹配，否则要求恰好匹配一处
+	ExpectedHash     string                 `protobuf:"bytes,5,opt,name=expected_hash,json=expectedHash,proto3" json:"expected_hash,omitempty"` // 同 WriteFileRequest.expected_hash
+	Mode             EditMode               `protobuf:"varint,6,opt,name=mode,proto3,enum=epiral.v1.EditMode" json:"mode,omitempty"`
+	StartLine        int32                  `protobuf:"varint,7,opt,name=start_line,json=startLine,proto3" json:"start_line,omitempty"`                      // LINES：起始行（1-based）；INSERT_*：参照行
+	EndLine          int32                  `protobuf:"varint,8,opt,name=end_line,json=endLine,proto3" json:"end_line,omitempty"`                            // LINES：结束行（含，0 = 同 start_line）
+	IgnoreWhitespace bool                   `protobuf:"varint,9,opt,name=ignore_whitespace,json=ignoreWhitespace,proto3" json:"ignore_whitespace,omitempty"` // REPLACE/REGEX：空白的多少和种类不影响匹配（缩进漂移、tab/空格）
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *EditFileRequest) Reset() {
+	*x = EditFileRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EditFileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EditFileRequest) ProtoMessage() {}
+
+func (x *EditFileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EditFileRequest.ProtoReflect.Descriptor instead.
+func (*EditFileRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *EditFileRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *EditFileRequest) GetOldString() string {
+	if x != nil {
+		return x.OldString
+	}
+	return ""
+}
+
+func (x *EditFileRequest) GetNewString() string {
+	if x != nil {
+		return x.NewString
+	}
+	return ""
+}
+
+func (x *EditFileRequest) GetReplaceAll() bool {
+	if x != nil {
+		return x.ReplaceAll
+	}
+	return false
+}
+
+func (x *EditFileRequest) GetExpectedHash() string {
+	if x != nil {
+		return x.ExpectedHash
+	}
+	return ""
+}
+
+func (x *EditFileRequest) GetMode() EditMode {
+	if x != nil {
+		return x.Mode
+	}
+	return EditMode_EDIT_MODE_REPLACE
+}
+
+func (x *EditFileRequest) GetStartLine() int32 {
+	if x != nil {
+		return x.StartLine
+	}
+	return 0
+}
+
+func (x *EditFileRequest) GetEndLine() int32 {
+	if x != nil {
+		return x.EndLine
+	}
+	return 0
+}
+
+func (x *EditFileRequest) GetIgnoreWhitespace() bool {
+	if x != nil {
+		return x.IgnoreWhitespace
+	}
+	return false
+}
+
+// 对同一文件的多处查找替换。按顺序在内存中依次应用（后面的编辑作用于前面编辑后的内容），
+// 每处的 old_string 须唯一（除非 replace_all）；全部成功才原子写回，否则文件不变，
+// OpResult.failed_edit 指出失败的是第几处。
+type MultiEditRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Edits         []*EditOperation       `protobuf:"bytes,2,rep,name=edits,proto3" json:"edits,omitempty"`
+	ExpectedHash  string                 `protobuf:"bytes,3,opt,name=expected_hash,json=expectedHash,proto3" json:"expected_hash,omitempty"` // 同 WriteFileRequest.expected_hash
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MultiEditRequest) Reset() {
+	*x = MultiEditRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MultiEditRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MultiEditRequest) ProtoMessage() {}
+
+func (x *MultiEditRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MultiEditRequest.ProtoReflect.Descriptor instead.
+func (*MultiEditRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *MultiEditRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *MultiEditRequest) GetEdits() []*EditOperation {
+	if x != nil {
+		return x.Edits
+	}
+	return nil
+}
+
+func (x *MultiEditRequest) GetExpectedHash() string {
+	if x != nil {
+		return x.ExpectedHash
+	}
+	return ""
+}
+
+type EditOperation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OldString     string                 `protobuf:"bytes,1,opt,name=old_string,json=oldString,proto3" json:"old_string,omitempty"`
+	NewString     string                 `protobuf:"bytes,2,opt,name=new_string,json=newString,proto3" json:"new_string,omitempty"`
+	ReplaceAll    bool                   `protobuf:"varint,3,opt,name=replace_all,json=replaceAll,proto3" json:"replace_all,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EditOperation) Reset() {
+	*x = EditOperation{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EditOperation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EditOperation) ProtoMessage() {}
+
+func (x *EditOperation) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EditOperation.ProtoReflect.Descriptor instead.
+func (*EditOperation) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *EditOperation) GetOldString() string {
+	if x != nil {
+		return x.OldString
 	}
-	return 0
+	return ""
 }
 
-func (x *ExecOutput) GetDone() bool {
+func (x *EditOperation) GetNewString() string {
 	if x != nil {
-		return x.Done
+		return x.NewString
+	}
+	return ""
+}
+
+func (x *EditOperation) GetReplaceAll() bool {
+	if x != nil {
+		return x.ReplaceAll
 	}
 	return false
 }
 
-func (x *ExecOutput) GetWorkdir() string {
+// 应用 unified diff（git diff / diff -u 格式），可包含多个文件，支持新建、删除、重命名和
+// 权限变更（git 的 new mode）。路径的 a/、b/ 前缀自动去掉。每个 hunk 先在原位置精确匹配，
+// 找不到时在全文件中就近寻找（offset），再逐级忽略首尾上下文（fuzz）。
+// 所有文件都检查路径权限；任何一个 hunk 无法应用则不修改任何文件（全部成功或全部不变）。
+// 以 PatchResult 应答。
+type ApplyPatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Patch         string                 `protobuf:"bytes,1,opt,name=patch,proto3" json:"patch,omitempty"`
+	BaseDir       string                 `protobuf:"bytes,2,opt,name=base_dir,json=baseDir,proto3" json:"base_dir,omitempty"`  // 补丁中相对路径的基准目录（空 = home_dir）
+	MaxFuzz       int32                  `protobuf:"varint,3,opt,name=max_fuzz,json=maxFuzz,proto3" json:"max_fuzz,omitempty"` // 最多忽略的首尾上下文行数（0 = 默认 2，负数 = 不允许）
+	DryRun        bool                   `protobuf:"varint,4,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`    // 只检查能否应用，不写入
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyPatchRequest) Reset() {
+	*x = ApplyPatchRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyPatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyPatchRequest) ProtoMessage() {}
+
+func (x *ApplyPatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[31]
 	if x != nil {
-		return x.Workdir
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyPatchRequest.ProtoReflect.Descriptor instead.
+func (*ApplyPatchRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *ApplyPatchRequest) GetPatch() string {
+	if x != nil {
+		return x.Patch
 	}
 	return ""
 }
 
-// 文件读取结果
-type FileContent struct {
+func (x *ApplyPatchRequest) GetBaseDir() string {
+	if x != nil {
+		return x.BaseDir
+	}
+	return ""
+}
+
+func (x *ApplyPatchRequest) GetMaxFuzz() int32 {
+	if x != nil {
+		return x.MaxFuzz
+	}
+	return 0
+}
+
+func (x *ApplyPatchRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+// 列出目录。递归时不跟随符号链接；无读权限（含 deny）的条目不会出现在结果中。
+type ListDirRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Content       string                 `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
-	TotalLines    int64                  `protobuf:"varint,2,opt,name=total_lines,json=totalLines,proto3" json:"total_lines,omitempty"`
-	FileSize      int64                  `protobuf:"varint,3,opt,name=file_size,json=fileSize,proto3" json:"file_size,omitempty"` // 实际文件大小（字节）
-	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`                        // 非空表示失败
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Depth         int32                  `protobuf:"varint,2,opt,name=depth,proto3" json:"depth,omitempty"`         // 递归深度（0/1 = 只列直接子项）
+	Limit         int32                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`         // 条目上限（0 = 默认 1000，最多 10000）
+	Gitignore     bool                   `protobuf:"varint,4,opt,name=gitignore,proto3" json:"gitignore,omitempty"` // 按 .gitignore 过滤，并跳过 .git 目录
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *FileContent) Reset() {
-	*x = FileContent{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[4]
+func (x *ListDirRequest) Reset() {
+	*x = ListDirRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *FileContent) String() string {
+func (x *ListDirRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*FileContent) ProtoMessage() {}
+func (*ListDirRequest) ProtoMessage() {}
 
-func (x *FileContent) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[4]
+func (x *ListDirRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -477,63 +3573,70 @@ func (x *FileContent) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use FileContent.ProtoReflect.Descriptor instead.
-func (*FileContent) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use ListDirRequest.ProtoReflect.Descriptor instead.
+func (*ListDirRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *FileContent) GetContent() string {
+func (x *ListDirRequest) GetPath() string {
 	if x != nil {
-		return x.Content
+		return x.Path
 	}
 	return ""
 }
 
-func (x *FileContent) GetTotalLines() int64 {
+func (x *ListDirRequest) GetDepth() int32 {
 	if x != nil {
-		return x.TotalLines
+		return x.Depth
 	}
 	return 0
 }
 
-func (x *FileContent) GetFileSize() int64 {
+func (x *ListDirRequest) GetLimit() int32 {
 	if x != nil {
-		return x.FileSize
+		return x.Limit
 	}
 	return 0
 }
 
-func (x *FileContent) GetError() string {
+func (x *ListDirRequest) GetGitignore() bool {
 	if x != nil {
-		return x.Error
+		return x.Gitignore
 	}
-	return ""
+	return false
 }
 
-// 写入/编辑等操作结果
-type OpResult struct {
+// 搜索文件：按 glob 匹配文件名，再按正则搜索内容（类似 find + grep -rn）。
+// 默认遵循 .gitignore，跳过二进制文件和符号链接，无读权限的路径不会被搜索。
+type SearchRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`       // 搜索的根目录或单个文件
+	Glob          string                 `protobuf:"bytes,2,opt,name=glob,proto3" json:"glob,omitempty"`       // gitignore 风格的通配，如 "*.go"、"internal/**/*.go"；空 = 所有文件
+	Pattern       string                 `protobuf:"bytes,3,opt,name=pattern,proto3" json:"pattern,omitempty"` // 内容正则（RE2 语法）；空 = 只返回匹配 glob 的文件
+	IgnoreCase    bool                   `protobuf:"varint,4,opt,name=ignore_case,json=ignoreCase,proto3" json:"ignore_case,omitempty"`
+	ContextLines  int32                  `protobuf:"varint,5,opt,name=context_lines,json=contextLines,proto3" json:"context_lines,omitempty"` // 匹配行前后各带几行（最多 10）
+	MaxResults    int32                  `protobuf:"varint,6,opt,name=max_results,json=maxResults,proto3" json:"max_results,omitempty"`       // 结果数上限（0 = 默认 1000）
+	MaxBytes      int64                  `protobuf:"varint,7,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`             // 返回文本的总字节上限（0 = 默认 1MB）
+	NoGitignore   bool                   `protobuf:"varint,8,opt,name=no_gitignore,json=noGitignore,proto3" json:"no_gitignore,omitempty"`    // true 时不按 .gitignore 过滤
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *OpResult) Reset() {
-	*x = OpResult{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[5]
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *OpResult) String() string {
+func (x *SearchRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*OpResult) ProtoMessage() {}
+func (*SearchRequest) ProtoMessage() {}
 
-func (x *OpResult) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[5]
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -544,50 +3647,90 @@ func (x *OpResult) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use OpResult.ProtoReflect.Descriptor instead.
-func (*OpResult) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{33}
 }
 
-func (x *OpResult) GetSuccess() bool {
+func (x *SearchRequest) GetPath() string {
 	if x != nil {
-		return x.Success
+		return x.Path
 	}
-	return false
+	return ""
 }
 
-func (x *OpResult) GetError() string {
+func (x *SearchRequest) GetGlob() string {
 	if x != nil {
-		return x.Error
+		return x.Glob
 	}
 	return ""
 }
 
-// 浏览器命令执行结果
-type BrowserExecOutput struct {
+func (x *SearchRequest) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetIgnoreCase() bool {
+	if x != nil {
+		return x.IgnoreCase
+	}
+	return false
+}
+
+func (x *SearchRequest) GetContextLines() int32 {
+	if x != nil {
+		return x.ContextLines
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetMaxResults() int32 {
+	if x != nil {
+		return x.MaxResults
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetMaxBytes() int64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetNoGitignore() bool {
+	if x != nil {
+		return x.NoGitignore
+	}
+	return false
+}
+
+// 查询文件信息（需要读权限）
+type StatRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	ResultJson    string                 `protobuf:"bytes,1,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"` // 插件回传的 Response JSON（bb-browser 协议）
-	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`                             // daemon 级别的错误（插件未连接/超时等）
-	Done          bool                   `protobuf:"varint,3,opt,name=done,proto3" json:"done,omitempty"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *BrowserExecOutput) Reset() {
-	*x = BrowserExecOutput{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[6]
+func (x *StatRequest) Reset() {
+	*x = StatRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *BrowserExecOutput) String() string {
+func (x *StatRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*BrowserExecOutput) ProtoMessage() {}
+func (*StatRequest) ProtoMessage() {}
 
-func (x *BrowserExecOutput) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[6]
+func (x *StatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -598,54 +3741,96 @@ func (x *BrowserExecOutput) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use BrowserExecOutput.ProtoReflect.Descriptor instead.
-func (*BrowserExecOutput) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use StatRequest.ProtoReflect.Descriptor instead.
+func (*StatRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *BrowserExecOutput) GetResultJson() string {
+func (x *StatRequest) GetPath() string {
 	if x != nil {
-		return x.ResultJson
+		return x.Path
 	}
 	return ""
 }
 
-func (x *BrowserExecOutput) GetError() string {
+// 删除文件、符号链接或目录（需要写权限）。符号链接只删除链接本身。
+type RemoveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Recursive     bool                   `protobuf:"varint,2,opt,name=recursive,proto3" json:"recursive,omitempty"` // 递归删除非空目录；false 时只能删除文件和空目录
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveRequest) Reset() {
+	*x = RemoveRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveRequest) ProtoMessage() {}
+
+func (x *RemoveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[35]
 	if x != nil {
-		return x.Error
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveRequest.ProtoReflect.Descriptor instead.
+func (*RemoveRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *RemoveRequest) GetPath() string {
+	if x != nil {
+		return x.Path
 	}
 	return ""
 }
 
-func (x *BrowserExecOutput) GetDone() bool {
+func (x *RemoveRequest) GetRecursive() bool {
 	if x != nil {
-		return x.Done
+		return x.Recursive
 	}
 	return false
 }
 
-type Ping struct {
+// 移动/重命名（源和目标都需要写权限）。跨文件系统时退化为复制后删除。
+type MoveRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Timestamp     int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Destination   string                 `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	Overwrite     bool                   `protobuf:"varint,3,opt,name=overwrite,proto3" json:"overwrite,omitempty"` // 目标已存在时覆盖（目标为非空目录时仍失败）
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Ping) Reset() {
-	*x = Ping{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[7]
+func (x *MoveRequest) Reset() {
+	*x = MoveRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Ping) String() string {
+func (x *MoveRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Ping) ProtoMessage() {}
+func (*MoveRequest) ProtoMessage() {}
 
-func (x *Ping) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[7]
+func (x *MoveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -656,40 +3841,58 @@ func (x *Ping) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Ping.ProtoReflect.Descriptor instead.
-func (*Ping) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use MoveRequest.ProtoReflect.Descriptor instead.
+func (*MoveRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{36}
 }
 
-func (x *Ping) GetTimestamp() int64 {
+func (x *MoveRequest) GetSource() string {
 	if x != nil {
-		return x.Timestamp
+		return x.Source
 	}
-	return 0
+	return ""
 }
 
-type Pong struct {
+func (x *MoveRequest) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *MoveRequest) GetOverwrite() bool {
+	if x != nil {
+		return x.Overwrite
+	}
+	return false
+}
+
+// 复制（源需要读权限，目标需要写权限）。保留权限位；目录内的符号链接按链接复制。
+type CopyRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Timestamp     int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Destination   string                 `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	Overwrite     bool                   `protobuf:"varint,3,opt,name=overwrite,proto3" json:"overwrite,omitempty"` // 目标文件已存在时覆盖
+	Recursive     bool                   `protobuf:"varint,4,opt,name=recursive,proto3" json:"recursive,omitempty"` // 复制目录时必须为 true
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Pong) Reset() {
-	*x = Pong{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[8]
+func (x *CopyRequest) Reset() {
+	*x = CopyRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Pong) String() string {
+func (x *CopyRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Pong) ProtoMessage() {}
+func (*CopyRequest) ProtoMessage() {}
 
-func (x *Pong) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[8]
+func (x *CopyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -700,49 +3903,64 @@ func (x *Pong) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Pong.ProtoReflect.Descriptor instead.
-func (*Pong) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use CopyRequest.ProtoReflect.Descriptor instead.
+func (*CopyRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{37}
 }
 
-func (x *Pong) GetTimestamp() int64 {
+func (x *CopyRequest) GetSource() string {
 	if x != nil {
-		return x.Timestamp
+		return x.Source
 	}
-	return 0
+	return ""
 }
 
-type ConnectResponse struct {
-	state     protoimpl.MessageState `protogen:"open.v1"`
-	RequestId string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
-	// Types that are valid to be assigned to Payload:
-	//
-	//	*ConnectResponse_Exec
-	//	*ConnectResponse_ReadFile
-	//	*ConnectResponse_WriteFile
-	//	*ConnectResponse_EditFile
-	//	*ConnectResponse_Pong
-	//	*ConnectResponse_BrowserExec
-	Payload       isConnectResponse_Payload `protobuf_oneof:"payload"`
+func (x *CopyRequest) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *CopyRequest) GetOverwrite() bool {
+	if x != nil {
+		return x.Overwrite
+	}
+	return false
+}
+
+func (x *CopyRequest) GetRecursive() bool {
+	if x != nil {
+		return x.Recursive
+	}
+	return false
+}
+
+// 创建目录（需要写权限）
+type MkdirRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Parents       bool                   `protobuf:"varint,2,opt,name=parents,proto3" json:"parents,omitempty"` // 同 mkdir -p：创建缺失的上级目录，目录已存在不算错误
+	Mode          uint32                 `protobuf:"varint,3,opt,name=mode,proto3" json:"mode,omitempty"`       // 权限位（0 = 0o755），受 umask 影响
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ConnectResponse) Reset() {
-	*x = ConnectResponse{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[9]
+func (x *MkdirRequest) Reset() {
+	*x = MkdirRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ConnectResponse) String() string {
+func (x *MkdirRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ConnectResponse) ProtoMessage() {}
+func (*MkdirRequest) ProtoMessage() {}
 
-func (x *ConnectResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[9]
+func (x *MkdirRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -753,147 +3971,160 @@ func (x *ConnectResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ConnectResponse.ProtoReflect.Descriptor instead.
-func (*ConnectResponse) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use MkdirRequest.ProtoReflect.Descriptor instead.
+func (*MkdirRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{38}
 }
 
-func (x *ConnectResponse) GetRequestId() string {
+func (x *MkdirRequest) GetPath() string {
 	if x != nil {
-		return x.RequestId
+		return x.Path
 	}
 	return ""
 }
 
-func (x *ConnectResponse) GetPayload() isConnectResponse_Payload {
+func (x *MkdirRequest) GetParents() bool {
 	if x != nil {
-		return x.Payload
+		return x.Parents
 	}
-	return nil
+	return false
 }
 
-func (x *ConnectResponse) GetExec() *ExecRequest {
+func (x *MkdirRequest) GetMode() uint32 {
 	if x != nil {
-		if x, ok := x.Payload.(*ConnectResponse_Exec); ok {
-			return x.Exec
-		}
+		return x.Mode
 	}
-	return nil
+	return 0
 }
 
-func (x *ConnectResponse) GetReadFile() *ReadFileRequest {
-	if x != nil {
-		if x, ok := x.Payload.(*ConnectResponse_ReadFile); ok {
-			return x.ReadFile
-		}
-	}
-	return nil
+// 修改权限位（需要写权限）
+type ChmodRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Mode          uint32                 `protobuf:"varint,2,opt,name=mode,proto3" json:"mode,omitempty"` // 如 0o644，只取低 12 位
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ConnectResponse) GetWriteFile() *WriteFileRequest {
-	if x != nil {
-		if x, ok := x.Payload.(*ConnectResponse_WriteFile); ok {
-			return x.WriteFile
-		}
-	}
-	return nil
+func (x *ChmodRequest) Reset() {
+	*x = ChmodRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (x *ConnectResponse) GetEditFile() *EditFileRequest {
+func (x *ChmodRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChmodRequest) ProtoMessage() {}
+
+func (x *ChmodRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[39]
 	if x != nil {
-		if x, ok := x.Payload.(*ConnectResponse_EditFile); ok {
-			return x.EditFile
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
 }
 
-func (x *ConnectResponse) GetPong() *Pong {
+// Deprecated: Use ChmodRequest.ProtoReflect.Descriptor instead.
+func (*ChmodRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *ChmodRequest) GetPath() string {
 	if x != nil {
-		if x, ok := x.Payload.(*ConnectResponse_Pong); ok {
-			return x.Pong
-		}
+		return x.Path
 	}
-	return nil
+	return ""
 }
 
-func (x *ConnectResponse) GetBrowserExec() *BrowserExecRequest {
+func (x *ChmodRequest) GetMode() uint32 {
 	if x != nil {
-		if x, ok := x.Payload.(*ConnectResponse_BrowserExec); ok {
-			return x.BrowserExec
-		}
+		return x.Mode
 	}
-	return nil
+	return 0
 }
 
-type isConnectResponse_Payload interface {
-	isConnectResponse_Payload()
+// 取消进行中的请求（除 ExecInput、ExecResize 等控制消息外的任何请求）。
+// 命令逐级终止（SIGINT → SIGTERM → SIGKILL），以 exit_code=130 的 ExecOutput 结束；
+// 其余操作以 CANCELLED 结果结束（下载与 TransferAbort 一样直接停止发送）：遍历和复制
+// 在中途停止（已复制的部分保留），写入、编辑、补丁、删除、上传等在落盘前被取消则不修改文件。
+// 取消请求本身以 OpResult 应答（success=false 表示未找到进行中的请求）。
+// 连接断开时所有进行中的请求都会被取消。
+type CancelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // 要取消的请求 ID
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-type ConnectResponse_Exec struct {
-	// Computer
-	Exec *ExecRequest `protobuf:"bytes,10,opt,name=exec,proto3,oneof"`
+func (x *CancelRequest) Reset() {
+	*x = CancelRequest{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-type ConnectResponse_ReadFile struct {
-	ReadFile *ReadFileRequest `protobuf:"bytes,11,opt,name=read_file,json=readFile,proto3,oneof"`
+func (x *CancelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-type ConnectResponse_WriteFile struct {
-	WriteFile *WriteFileRequest `protobuf:"bytes,12,opt,name=write_file,json=writeFile,proto3,oneof"`
-}
+func (*CancelRequest) ProtoMessage() {}
 
-type ConnectResponse_EditFile struct {
-	EditFile *EditFileRequest `protobuf:"bytes,13,opt,name=edit_file,json=editFile,proto3,oneof"`
+func (x *CancelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-type ConnectResponse_Pong struct {
-	Pong *Pong `protobuf:"bytes,14,opt,name=pong,proto3,oneof"`
+// Deprecated: Use CancelRequest.ProtoReflect.Descriptor instead.
+func (*CancelRequest) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{40}
 }
 
-type ConnectResponse_BrowserExec struct {
-	// Browser
-	BrowserExec *BrowserExecRequest `protobuf:"bytes,15,opt,name=browser_exec,json=browserExec,proto3,oneof"`
+func (x *CancelRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
 }
 
-func (*ConnectResponse_Exec) isConnectResponse_Payload() {}
-
-func (*ConnectResponse_ReadFile) isConnectResponse_Payload() {}
-
-func (*ConnectResponse_WriteFile) isConnectResponse_Payload() {}
-
-func (*ConnectResponse_EditFile) isConnectResponse_Payload() {}
-
-func (*ConnectResponse_Pong) isConnectResponse_Payload() {}
-
-func (*ConnectResponse_BrowserExec) isConnectResponse_Payload() {}
-
-// 执行命令
-type ExecRequest struct {
+type UploadBegin struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
-	Workdir       string                 `protobuf:"bytes,2,opt,name=workdir,proto3" json:"workdir,omitempty"`                       // 工作目录（空 = home_dir）
-	TimeoutMs     int32                  `protobuf:"varint,3,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"` // 超时毫秒（0 = 默认 30000）
-	SessionId     string                 `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`  // Shell Pool 用，空 = one-shot (P1)
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"` // Agent 生成，跨重连保持不变（字母、数字、. _ -）
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`    // 文件总大小
+	Sha256        string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"` // 整个文件的 SHA-256（hex），可选，Commit 时校验
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExecRequest) Reset() {
-	*x = ExecRequest{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[10]
+func (x *UploadBegin) Reset() {
+	*x = UploadBegin{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[41]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExecRequest) String() string {
+func (x *UploadBegin) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExecRequest) ProtoMessage() {}
+func (*UploadBegin) ProtoMessage() {}
 
-func (x *ExecRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[10]
+func (x *UploadBegin) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[41]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -904,65 +4135,64 @@ func (x *ExecRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExecRequest.ProtoReflect.Descriptor instead.
-func (*ExecRequest) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{10}
+// Deprecated: Use UploadBegin.ProtoReflect.Descriptor instead.
+func (*UploadBegin) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{41}
 }
 
-func (x *ExecRequest) GetCommand() string {
+func (x *UploadBegin) GetTransferId() string {
 	if x != nil {
-		return x.Command
+		return x.TransferId
 	}
 	return ""
 }
 
-func (x *ExecRequest) GetWorkdir() string {
+func (x *UploadBegin) GetPath() string {
 	if x != nil {
-		return x.Workdir
+		return x.Path
 	}
 	return ""
 }
 
-func (x *ExecRequest) GetTimeoutMs() int32 {
+func (x *UploadBegin) GetSize() int64 {
 	if x != nil {
-		return x.TimeoutMs
+		return x.Size
 	}
 	return 0
 }
 
-func (x *ExecRequest) GetSessionId() string {
+func (x *UploadBegin) GetSha256() string {
 	if x != nil {
-		return x.SessionId
+		return x.Sha256
 	}
 	return ""
 }
 
-// 读文件
-type ReadFileRequest struct {
+type UploadChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
-	Offset        int32                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`                  // 行偏移（0-based）
-	Limit         int32                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`                    // 行数（0 = 全部，默认 2000）
-	MaxSize       int64                  `protobuf:"varint,4,opt,name=max_size,json=maxSize,proto3" json:"max_size,omitempty"` // 字节上限（0 = 默认 256KB）
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"` // 本块在文件中的偏移，必须等于已接收的字节数
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`      // 不超过 4MB
+	Sha256        string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`  // 本块的 SHA-256（hex），可选
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ReadFileRequest) Reset() {
-	*x = ReadFileRequest{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[11]
+func (x *UploadChunk) Reset() {
+	*x = UploadChunk{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[42]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ReadFileRequest) String() string {
+func (x *UploadChunk) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ReadFileRequest) ProtoMessage() {}
+func (*UploadChunk) ProtoMessage() {}
 
-func (x *ReadFileRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[11]
+func (x *UploadChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[42]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -973,63 +4203,61 @@ func (x *ReadFileRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ReadFileRequest.ProtoReflect.Descriptor instead.
-func (*ReadFileRequest) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{11}
+// Deprecated: Use UploadChunk.ProtoReflect.Descriptor instead.
+func (*UploadChunk) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{42}
 }
 
-func (x *ReadFileRequest) GetPath() string {
+func (x *UploadChunk) GetTransferId() string {
 	if x != nil {
-		return x.Path
+		return x.TransferId
 	}
 	return ""
 }
 
-func (x *ReadFileRequest) GetOffset() int32 {
+func (x *UploadChunk) GetOffset() int64 {
 	if x != nil {
 		return x.Offset
 	}
 	return 0
 }
 
-func (x *ReadFileRequest) GetLimit() int32 {
+func (x *UploadChunk) GetData() []byte {
 	if x != nil {
-		return x.Limit
+		return x.Data
 	}
-	return 0
+	return nil
 }
 
-func (x *ReadFileRequest) GetMaxSize() int64 {
+func (x *UploadChunk) GetSha256() string {
 	if x != nil {
-		return x.MaxSize
+		return x.Sha256
 	}
-	return 0
+	return ""
 }
 
-// 写文件
-type WriteFileRequest struct {
+type UploadCommit struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
-	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *WriteFileRequest) Reset() {
-	*x = WriteFileRequest{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[12]
+func (x *UploadCommit) Reset() {
+	*x = UploadCommit{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[43]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *WriteFileRequest) String() string {
+func (x *UploadCommit) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*WriteFileRequest) ProtoMessage() {}
+func (*UploadCommit) ProtoMessage() {}
 
-func (x *WriteFileRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[12]
+func (x *UploadCommit) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[43]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1040,51 +4268,97 @@ func (x *WriteFileRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use WriteFileRequest.ProtoReflect.Descriptor instead.
-func (*WriteFileRequest) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{12}
+// Deprecated: Use UploadCommit.ProtoReflect.Descriptor instead.
+func (*UploadCommit) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{43}
 }
 
-func (x *WriteFileRequest) GetPath() string {
+func (x *UploadCommit) GetTransferId() string {
 	if x != nil {
-		return x.Path
+		return x.TransferId
 	}
 	return ""
 }
 
-func (x *WriteFileRequest) GetContent() string {
+// 中止上传（删除临时文件）或正在进行的下载
+type TransferAbort struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"` // 上传的目标路径：重连后未重新 Begin 时用来找到临时文件
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferAbort) Reset() {
+	*x = TransferAbort{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferAbort) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferAbort) ProtoMessage() {}
+
+func (x *TransferAbort) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[44]
 	if x != nil {
-		return x.Content
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferAbort.ProtoReflect.Descriptor instead.
+func (*TransferAbort) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *TransferAbort) GetTransferId() string {
+	if x != nil {
+		return x.TransferId
 	}
 	return ""
 }
 
-// 编辑文件（查找替换）
-type EditFileRequest struct {
+func (x *TransferAbort) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type DownloadBegin struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
-	OldString     string                 `protobuf:"bytes,2,opt,name=old_string,json=oldString,proto3" json:"old_string,omitempty"`
-	NewString     string                 `protobuf:"bytes,3,opt,name=new_string,json=newString,proto3" json:"new_string,omitempty"`
-	ReplaceAll    bool                   `protobuf:"varint,4,opt,name=replace_all,json=replaceAll,proto3" json:"replace_all,omitempty"`
+	TransferId    string                 `protobuf:"bytes,1,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	Path          string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Offset        int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`                        // 起始偏移（续传）
+	Sha256        string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`                         // 续传时期望的文件 SHA-256，不一致则失败
+	ChunkSize     uint32                 `protobuf:"varint,5,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"` // 初始块大小（0 = 默认 256KB），之后自动调整
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *EditFileRequest) Reset() {
-	*x = EditFileRequest{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[13]
+func (x *DownloadBegin) Reset() {
+	*x = DownloadBegin{}
+	mi := &file_epiral_v1_epiral_proto_msgTypes[45]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *EditFileRequest) String() string {
+func (x *DownloadBegin) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*EditFileRequest) ProtoMessage() {}
+func (*DownloadBegin) ProtoMessage() {}
 
-func (x *EditFileRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[13]
+func (x *DownloadBegin) ProtoReflect() protoreflect.Message {
+	mi := &file_epiral_v1_epiral_proto_msgTypes[45]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1095,43 +4369,50 @@ func (x *EditFileRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use EditFileRequest.ProtoReflect.Descriptor instead.
-func (*EditFileRequest) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{13}
+// Deprecated: Use DownloadBegin.ProtoReflect.Descriptor instead.
+func (*DownloadBegin) Descriptor() ([]byte, []int) {
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{45}
 }
 
-func (x *EditFileRequest) GetPath() string {
+func (x *DownloadBegin) GetTransferId() string {
 	if x != nil {
-		return x.Path
+		return x.TransferId
 	}
 	return ""
 }
 
-func (x *EditFileRequest) GetOldString() string {
+func (x *DownloadBegin) GetPath() string {
 	if x != nil {
-		return x.OldString
+		return x.Path
 	}
 	return ""
 }
 
-func (x *EditFileRequest) GetNewString() string {
+func (x *DownloadBegin) GetOffset() int64 {
 	if x != nil {
-		return x.NewString
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *DownloadBegin) GetSha256() string {
+	if x != nil {
+		return x.Sha256
 	}
 	return ""
 }
 
-func (x *EditFileRequest) GetReplaceAll() bool {
+func (x *DownloadBegin) GetChunkSize() uint32 {
 	if x != nil {
-		return x.ReplaceAll
+		return x.ChunkSize
 	}
-	return false
+	return 0
 }
 
 // 浏览器命令（转发给插件执行）
 type BrowserExecRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	CommandJson   string                 `protobuf:"bytes,1,opt,name=command_json,json=commandJson,proto3" json:"command_json,omitempty"` // 结构化命令 JSON（bb-browser Request 协议）
+	CommandJson   string                 `protobuf:"bytes,1,opt,name=command_json,json=commandJson,proto3" json:"command_json,omitempty"` // 结构化命令 JSON（bb-browser Request 协议），转发时 id 替换为 request_id
 	TimeoutMs     int32                  `protobuf:"varint,2,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`      // 超时毫秒（0 = 默认 30000）
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -1139,7 +4420,7 @@ type BrowserExecRequest struct {
 
 func (x *BrowserExecRequest) Reset() {
 	*x = BrowserExecRequest{}
-	mi := &file_epiral_v1_epiral_proto_msgTypes[14]
+	mi := &file_epiral_v1_epiral_proto_msgTypes[46]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1151,7 +4432,7 @@ func (x *BrowserExecRequest) String() string {
 func (*BrowserExecRequest) ProtoMessage() {}
 
 func (x *BrowserExecRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_epiral_v1_epiral_proto_msgTypes[14]
+	mi := &file_epiral_v1_epiral_proto_msgTypes[46]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1164,7 +4445,7 @@ func (x *BrowserExecRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BrowserExecRequest.ProtoReflect.Descriptor instead.
 func (*BrowserExecRequest) Descriptor() ([]byte, []int) {
-	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{14}
+	return file_epiral_v1_epiral_proto_rawDescGZIP(), []int{46}
 }
 
 func (x *BrowserExecRequest) GetCommandJson() string {
@@ -1185,7 +4466,7 @@ var File_epiral_v1_epiral_proto protoreflect.FileDescriptor
 
 const file_epiral_v1_epiral_proto_rawDesc = "" +
 	"\n" +
-	"\x16epiral/v1/epiral.proto\x12\tepiral.v1\"\xf0\x03\n" +
+	"\x16epiral/v1/epiral.proto\x12\tepiral.v1\"\xe4\x06\n" +
 	"\x0eConnectRequest\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x01 \x01(\tR\trequestId\x12=\n" +
@@ -1197,8 +4478,15 @@ const file_epiral_v1_epiral_proto_rawDesc = "" +
 	"\top_result\x18\r \x01(\v2\x13.epiral.v1.OpResultH\x00R\bopResult\x12%\n" +
 	"\x04ping\x18\x0e \x01(\v2\x0f.epiral.v1.PingH\x00R\x04ping\x12S\n" +
 	"\x14browser_registration\x18\x0f \x01(\v2\x1e.epiral.v1.BrowserRegistrationH\x00R\x13browserRegistration\x12N\n" +
-	"\x13browser_exec_output\x18\x10 \x01(\v2\x1c.epiral.v1.BrowserExecOutputH\x00R\x11browserExecOutputB\t\n" +
-	"\apayload\"\xd5\x02\n" +
+	"\x13browser_exec_output\x18\x10 \x01(\v2\x1c.epiral.v1.BrowserExecOutputH\x00R\x11browserExecOutput\x12D\n" +
+	"\x0ftransfer_status\x18\x11 \x01(\v2\x19.epiral.v1.TransferStatusH\x00R\x0etransferStatus\x12A\n" +
+	"\x0etransfer_chunk\x18\x12 \x01(\v2\x18.epiral.v1.TransferChunkH\x00R\rtransferChunk\x128\n" +
+	"\vdir_listing\x18\x13 \x01(\v2\x15.epiral.v1.DirListingH\x00R\n" +
+	"dirListing\x12>\n" +
+	"\rsearch_result\x18\x14 \x01(\v2\x17.epiral.v1.SearchResultH\x00R\fsearchResult\x122\n" +
+	"\tfile_stat\x18\x15 \x01(\v2\x13.epiral.v1.FileStatH\x00R\bfileStat\x12;\n" +
+	"\fpatch_result\x18\x16 \x01(\v2\x16.epiral.v1.PatchResultH\x00R\vpatchResultB\t\n" +
+	"\apayload\"\xf6\x03\n" +
 	"\fRegistration\x12\x1f\n" +
 	"\vcomputer_id\x18\x01 \x01(\tR\n" +
 	"computerId\x12 \n" +
@@ -1209,41 +4497,159 @@ const file_epiral_v1_epiral_proto_rawDesc = "" +
 	"\bhome_dir\x18\x06 \x01(\tR\ahomeDir\x128\n" +
 	"\x05tools\x18\a \x03(\v2\".epiral.v1.Registration.ToolsEntryR\x05tools\x12#\n" +
 	"\rallowed_paths\x18\b \x03(\tR\fallowedPaths\x12\x14\n" +
-	"\x05token\x18\t \x01(\tR\x05token\x1a8\n" +
+	"\x05token\x18\t \x01(\tR\x05token\x12/\n" +
+	"\x05paths\x18\n" +
+	" \x03(\v2\x19.epiral.v1.PathPermissionR\x05paths\x12)\n" +
+	"\x10protocol_version\x18\v \x01(\rR\x0fprotocolVersion\x12\x1f\n" +
+	"\vcli_version\x18\f \x01(\tR\n" +
+	"cliVersion\x12\"\n" +
+	"\fcapabilities\x18\r \x03(\tR\fcapabilities\x1a8\n" +
 	"\n" +
 	"ToolsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"n\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x8c\x01\n" +
+	"\x0fRegistrationAck\x12\x1a\n" +
+	"\baccepted\x18\x01 \x01(\bR\baccepted\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12)\n" +
+	"\x10protocol_version\x18\x03 \x01(\rR\x0fprotocolVersion\x12\x1a\n" +
+	"\bfeatures\x18\x04 \x03(\tR\bfeatures\"b\n" +
+	"\x0ePathPermission\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04read\x18\x02 \x01(\bR\x04read\x12\x14\n" +
+	"\x05write\x18\x03 \x01(\bR\x05write\x12\x12\n" +
+	"\x04exec\x18\x04 \x01(\bR\x04exec\"n\n" +
 	"\x13BrowserRegistration\x12\x1d\n" +
 	"\n" +
 	"browser_id\x18\x01 \x01(\tR\tbrowserId\x12 \n" +
 	"\vdescription\x18\x02 \x01(\tR\vdescription\x12\x16\n" +
-	"\x06online\x18\x03 \x01(\bR\x06online\"\x87\x01\n" +
+	"\x06online\x18\x03 \x01(\bR\x06online\"\xb1\x02\n" +
 	"\n" +
 	"ExecOutput\x12\x16\n" +
 	"\x06stdout\x18\x01 \x01(\tR\x06stdout\x12\x16\n" +
 	"\x06stderr\x18\x02 \x01(\tR\x06stderr\x12\x1b\n" +
 	"\texit_code\x18\x03 \x01(\x05R\bexitCode\x12\x12\n" +
 	"\x04done\x18\x04 \x01(\bR\x04done\x12\x18\n" +
-	"\aworkdir\x18\x05 \x01(\tR\aworkdir\"{\n" +
+	"\aworkdir\x18\x05 \x01(\tR\aworkdir\x12\x10\n" +
+	"\x03seq\x18\x06 \x01(\x03R\x03seq\x12)\n" +
+	"\x10stdout_truncated\x18\a \x01(\bR\x0fstdoutTruncated\x12)\n" +
+	"\x10stderr_truncated\x18\b \x01(\bR\x0fstderrTruncated\x12\x16\n" +
+	"\x06binary\x18\t \x01(\bR\x06binary\x12(\n" +
+	"\x04code\x18\n" +
+	" \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\"\x84\x02\n" +
 	"\vFileContent\x12\x18\n" +
 	"\acontent\x18\x01 \x01(\tR\acontent\x12\x1f\n" +
 	"\vtotal_lines\x18\x02 \x01(\x03R\n" +
 	"totalLines\x12\x1b\n" +
 	"\tfile_size\x18\x03 \x01(\x03R\bfileSize\x12\x14\n" +
-	"\x05error\x18\x04 \x01(\tR\x05error\":\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x12\n" +
+	"\x04data\x18\x05 \x01(\fR\x04data\x12\x16\n" +
+	"\x06sha256\x18\x06 \x01(\tR\x06sha256\x12\x19\n" +
+	"\bmtime_ms\x18\a \x01(\x03R\amtimeMs\x12(\n" +
+	"\x04code\x18\b \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\x12\x16\n" +
+	"\x06binary\x18\t \x01(\bR\x06binary\"\xad\x01\n" +
+	"\n" +
+	"DirListing\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12-\n" +
+	"\aentries\x18\x02 \x03(\v2\x13.epiral.v1.DirEntryR\aentries\x12\x1c\n" +
+	"\ttruncated\x18\x03 \x01(\bR\ttruncated\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12(\n" +
+	"\x04code\x18\x05 \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\"\xac\x01\n" +
+	"\bDirEntry\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12(\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x14.epiral.v1.EntryTypeR\x04type\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x12\n" +
+	"\x04mode\x18\x04 \x01(\rR\x04mode\x12\x19\n" +
+	"\bmtime_ms\x18\x05 \x01(\x03R\amtimeMs\x12\x1f\n" +
+	"\vlink_target\x18\x06 \x01(\tR\n" +
+	"linkTarget\"\xd9\x01\n" +
+	"\fSearchResult\x120\n" +
+	"\amatches\x18\x01 \x03(\v2\x16.epiral.v1.SearchMatchR\amatches\x12\x12\n" +
+	"\x04done\x18\x02 \x01(\bR\x04done\x12\x1c\n" +
+	"\ttruncated\x18\x03 \x01(\bR\ttruncated\x12%\n" +
+	"\x0efiles_searched\x18\x04 \x01(\x03R\rfilesSearched\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12(\n" +
+	"\x04code\x18\x06 \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\"\x8f\x01\n" +
+	"\vSearchMatch\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04line\x18\x02 \x01(\x05R\x04line\x12\x16\n" +
+	"\x06column\x18\x03 \x01(\x05R\x06column\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12\x16\n" +
+	"\x06before\x18\x05 \x03(\tR\x06before\x12\x14\n" +
+	"\x05after\x18\x06 \x03(\tR\x05after\"\x99\x01\n" +
+	"\vPatchResult\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x120\n" +
+	"\x05files\x18\x03 \x03(\v2\x1a.epiral.v1.PatchFileResultR\x05files\x12(\n" +
+	"\x04code\x18\x04 \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\"\x93\x02\n" +
+	"\x0fPatchFileResult\x12\x19\n" +
+	"\bold_path\x18\x01 \x01(\tR\aoldPath\x12\x19\n" +
+	"\bnew_path\x18\x02 \x01(\tR\anewPath\x12.\n" +
+	"\x06action\x18\x03 \x01(\x0e2\x16.epiral.v1.PatchActionR\x06action\x12+\n" +
+	"\x05hunks\x18\x04 \x03(\v2\x15.epiral.v1.HunkResultR\x05hunks\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12-\n" +
+	"\x06change\x18\x06 \x01(\v2\x15.epiral.v1.FileChangeR\x06change\x12(\n" +
+	"\x04code\x18\a \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\"\x92\x01\n" +
+	"\n" +
+	"HunkResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x18\n" +
+	"\aapplied\x18\x02 \x01(\bR\aapplied\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\x05R\x04line\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x05R\x06offset\x12\x12\n" +
+	"\x04fuzz\x18\x05 \x01(\x05R\x04fuzz\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\"\x9f\x01\n" +
+	"\bFileStat\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x16\n" +
+	"\x06exists\x18\x02 \x01(\bR\x06exists\x12'\n" +
+	"\x04info\x18\x03 \x01(\v2\x13.epiral.v1.DirEntryR\x04info\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12(\n" +
+	"\x04code\x18\x05 \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\"\x94\x02\n" +
 	"\bOpResult\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error\"^\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x12(\n" +
+	"\x04code\x18\x03 \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\x12\x1f\n" +
+	"\vfailed_edit\x18\x05 \x01(\x05R\n" +
+	"failedEdit\x12\x18\n" +
+	"\asnippet\x18\x06 \x01(\tR\asnippet\x12,\n" +
+	"\x12snippet_start_line\x18\a \x01(\x05R\x10snippetStartLine\x12-\n" +
+	"\x06change\x18\b \x01(\v2\x15.epiral.v1.FileChangeR\x06change\"\xc4\x01\n" +
+	"\n" +
+	"FileChange\x12\x16\n" +
+	"\x06sha256\x18\x01 \x01(\tR\x06sha256\x12\x1d\n" +
+	"\n" +
+	"line_count\x18\x02 \x01(\x03R\tlineCount\x12\x12\n" +
+	"\x04diff\x18\x03 \x01(\tR\x04diff\x12%\n" +
+	"\x0ediff_truncated\x18\x04 \x01(\bR\rdiffTruncated\x12\x1f\n" +
+	"\vlines_added\x18\x05 \x01(\x05R\n" +
+	"linesAdded\x12#\n" +
+	"\rlines_removed\x18\x06 \x01(\x05R\flinesRemoved\"\xe8\x01\n" +
+	"\x0eTransferStatus\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x05 \x01(\rR\tchunkSize\x12\x12\n" +
+	"\x04done\x18\x06 \x01(\bR\x04done\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\x12(\n" +
+	"\x04code\x18\b \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\"t\n" +
+	"\rTransferChunk\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\"\x88\x01\n" +
 	"\x11BrowserExecOutput\x12\x1f\n" +
 	"\vresult_json\x18\x01 \x01(\tR\n" +
 	"resultJson\x12\x14\n" +
 	"\x05error\x18\x02 \x01(\tR\x05error\x12\x12\n" +
-	"\x04done\x18\x03 \x01(\bR\x04done\"$\n" +
+	"\x04done\x18\x03 \x01(\bR\x04done\x12(\n" +
+	"\x04code\x18\x04 \x01(\x0e2\x14.epiral.v1.ErrorCodeR\x04code\"$\n" +
 	"\x04Ping\x12\x1c\n" +
 	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\"$\n" +
 	"\x04Pong\x12\x1c\n" +
-	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\"\x88\x03\n" +
+	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\"\xc1\v\n" +
 	"\x0fConnectResponse\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x01 \x01(\tR\trequestId\x12,\n" +
@@ -1254,23 +4660,71 @@ const file_epiral_v1_epiral_proto_rawDesc = "" +
 	"write_file\x18\f \x01(\v2\x1b.epiral.v1.WriteFileRequestH\x00R\twriteFile\x129\n" +
 	"\tedit_file\x18\r \x01(\v2\x1a.epiral.v1.EditFileRequestH\x00R\beditFile\x12%\n" +
 	"\x04pong\x18\x0e \x01(\v2\x0f.epiral.v1.PongH\x00R\x04pong\x12B\n" +
-	"\fbrowser_exec\x18\x0f \x01(\v2\x1d.epiral.v1.BrowserExecRequestH\x00R\vbrowserExecB\t\n" +
-	"\apayload\"\x7f\n" +
+	"\fbrowser_exec\x18\x0f \x01(\v2\x1d.epiral.v1.BrowserExecRequestH\x00R\vbrowserExec\x122\n" +
+	"\x06cancel\x18\x10 \x01(\v2\x18.epiral.v1.CancelRequestH\x00R\x06cancel\x125\n" +
+	"\n" +
+	"exec_input\x18\x11 \x01(\v2\x14.epiral.v1.ExecInputH\x00R\texecInput\x128\n" +
+	"\vexec_resize\x18\x12 \x01(\v2\x15.epiral.v1.ExecResizeH\x00R\n" +
+	"execResize\x12;\n" +
+	"\fupload_begin\x18\x13 \x01(\v2\x16.epiral.v1.UploadBeginH\x00R\vuploadBegin\x12;\n" +
+	"\fupload_chunk\x18\x14 \x01(\v2\x16.epiral.v1.UploadChunkH\x00R\vuploadChunk\x12>\n" +
+	"\rupload_commit\x18\x15 \x01(\v2\x17.epiral.v1.UploadCommitH\x00R\fuploadCommit\x12A\n" +
+	"\x0etransfer_abort\x18\x16 \x01(\v2\x18.epiral.v1.TransferAbortH\x00R\rtransferAbort\x12A\n" +
+	"\x0edownload_begin\x18\x17 \x01(\v2\x18.epiral.v1.DownloadBeginH\x00R\rdownloadBegin\x126\n" +
+	"\blist_dir\x18\x18 \x01(\v2\x19.epiral.v1.ListDirRequestH\x00R\alistDir\x122\n" +
+	"\x06search\x18\x19 \x01(\v2\x18.epiral.v1.SearchRequestH\x00R\x06search\x12,\n" +
+	"\x04stat\x18\x1a \x01(\v2\x16.epiral.v1.StatRequestH\x00R\x04stat\x122\n" +
+	"\x06remove\x18\x1b \x01(\v2\x18.epiral.v1.RemoveRequestH\x00R\x06remove\x12,\n" +
+	"\x04move\x18\x1c \x01(\v2\x16.epiral.v1.MoveRequestH\x00R\x04move\x12,\n" +
+	"\x04copy\x18\x1d \x01(\v2\x16.epiral.v1.CopyRequestH\x00R\x04copy\x12/\n" +
+	"\x05mkdir\x18\x1e \x01(\v2\x17.epiral.v1.MkdirRequestH\x00R\x05mkdir\x12/\n" +
+	"\x05chmod\x18\x1f \x01(\v2\x17.epiral.v1.ChmodRequestH\x00R\x05chmod\x12<\n" +
+	"\n" +
+	"multi_edit\x18  \x01(\v2\x1b.epiral.v1.MultiEditRequestH\x00R\tmultiEdit\x12?\n" +
+	"\vapply_patch\x18! \x01(\v2\x1c.epiral.v1.ApplyPatchRequestH\x00R\n" +
+	"applyPatch\x12G\n" +
+	"\x10registration_ack\x18\" \x01(\v2\x1a.epiral.v1.RegistrationAckH\x00R\x0fregistrationAckB\t\n" +
+	"\apayload\"\xdc\x02\n" +
 	"\vExecRequest\x12\x18\n" +
 	"\acommand\x18\x01 \x01(\tR\acommand\x12\x18\n" +
 	"\aworkdir\x18\x02 \x01(\tR\aworkdir\x12\x1d\n" +
 	"\n" +
 	"timeout_ms\x18\x03 \x01(\x05R\ttimeoutMs\x12\x1d\n" +
 	"\n" +
-	"session_id\x18\x04 \x01(\tR\tsessionId\"n\n" +
+	"session_id\x18\x04 \x01(\tR\tsessionId\x12\x10\n" +
+	"\x03pty\x18\x05 \x01(\bR\x03pty\x12\x12\n" +
+	"\x04cols\x18\x06 \x01(\rR\x04cols\x12\x12\n" +
+	"\x04rows\x18\a \x01(\rR\x04rows\x121\n" +
+	"\x03env\x18\b \x03(\v2\x1f.epiral.v1.ExecRequest.EnvEntryR\x03env\x126\n" +
+	"\vinherit_env\x18\t \x01(\x0e2\x15.epiral.v1.InheritEnvR\n" +
+	"inheritEnv\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"1\n" +
+	"\tExecInput\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12\x10\n" +
+	"\x03eof\x18\x02 \x01(\bR\x03eof\"4\n" +
+	"\n" +
+	"ExecResize\x12\x12\n" +
+	"\x04cols\x18\x01 \x01(\rR\x04cols\x12\x12\n" +
+	"\x04rows\x18\x02 \x01(\rR\x04rows\"\xd9\x01\n" +
 	"\x0fReadFileRequest\x12\x12\n" +
 	"\x04path\x18\x01 \x01(\tR\x04path\x12\x16\n" +
 	"\x06offset\x18\x02 \x01(\x05R\x06offset\x12\x14\n" +
 	"\x05limit\x18\x03 \x01(\x05R\x05limit\x12\x19\n" +
-	"\bmax_size\x18\x04 \x01(\x03R\amaxSize\"@\n" +
+	"\bmax_size\x18\x04 \x01(\x03R\amaxSize\x12'\n" +
+	"\x04mode\x18\x05 \x01(\x0e2\x13.epiral.v1.ReadModeR\x04mode\x12\x1f\n" +
+	"\vbyte_offset\x18\x06 \x01(\x03R\n" +
+	"byteOffset\x12\x1f\n" +
+	"\vbyte_length\x18\a \x01(\x03R\n" +
+	"byteLength\"\xa5\x01\n" +
 	"\x10WriteFileRequest\x12\x12\n" +
 	"\x04path\x18\x01 \x01(\tR\x04path\x12\x18\n" +
-	"\acontent\x18\x02 \x01(\tR\acontent\"\x84\x01\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x16\n" +
+	"\x06binary\x18\x04 \x01(\bR\x06binary\x12#\n" +
+	"\rexpected_hash\x18\x05 \x01(\tR\fexpectedHash\x12\x12\n" +
+	"\x04mode\x18\x06 \x01(\rR\x04mode\"\xb9\x02\n" +
 	"\x0fEditFileRequest\x12\x12\n" +
 	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1d\n" +
 	"\n" +
@@ -1278,11 +4732,140 @@ const file_epiral_v1_epiral_proto_rawDesc = "" +
 	"\n" +
 	"new_string\x18\x03 \x01(\tR\tnewString\x12\x1f\n" +
 	"\vreplace_all\x18\x04 \x01(\bR\n" +
-	"replaceAll\"V\n" +
+	"replaceAll\x12#\n" +
+	"\rexpected_hash\x18\x05 \x01(\tR\fexpectedHash\x12'\n" +
+	"\x04mode\x18\x06 \x01(\x0e2\x13.epiral.v1.EditModeR\x04mode\x12\x1d\n" +
+	"\n" +
+	"start_line\x18\a \x01(\x05R\tstartLine\x12\x19\n" +
+	"\bend_line\x18\b \x01(\x05R\aendLine\x12+\n" +
+	"\x11ignore_whitespace\x18\t \x01(\bR\x10ignoreWhitespace\"{\n" +
+	"\x10MultiEditRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12.\n" +
+	"\x05edits\x18\x02 \x03(\v2\x18.epiral.v1.EditOperationR\x05edits\x12#\n" +
+	"\rexpected_hash\x18\x03 \x01(\tR\fexpectedHash\"n\n" +
+	"\rEditOperation\x12\x1d\n" +
+	"\n" +
+	"old_string\x18\x01 \x01(\tR\toldString\x12\x1d\n" +
+	"\n" +
+	"new_string\x18\x02 \x01(\tR\tnewString\x12\x1f\n" +
+	"\vreplace_all\x18\x03 \x01(\bR\n" +
+	"replaceAll\"x\n" +
+	"\x11ApplyPatchRequest\x12\x14\n" +
+	"\x05patch\x18\x01 \x01(\tR\x05patch\x12\x19\n" +
+	"\bbase_dir\x18\x02 \x01(\tR\abaseDir\x12\x19\n" +
+	"\bmax_fuzz\x18\x03 \x01(\x05R\amaxFuzz\x12\x17\n" +
+	"\adry_run\x18\x04 \x01(\bR\x06dryRun\"n\n" +
+	"\x0eListDirRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x14\n" +
+	"\x05depth\x18\x02 \x01(\x05R\x05depth\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\x12\x1c\n" +
+	"\tgitignore\x18\x04 \x01(\bR\tgitignore\"\xf8\x01\n" +
+	"\rSearchRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04glob\x18\x02 \x01(\tR\x04glob\x12\x18\n" +
+	"\apattern\x18\x03 \x01(\tR\apattern\x12\x1f\n" +
+	"\vignore_case\x18\x04 \x01(\bR\n" +
+	"ignoreCase\x12#\n" +
+	"\rcontext_lines\x18\x05 \x01(\x05R\fcontextLines\x12\x1f\n" +
+	"\vmax_results\x18\x06 \x01(\x05R\n" +
+	"maxResults\x12\x1b\n" +
+	"\tmax_bytes\x18\a \x01(\x03R\bmaxBytes\x12!\n" +
+	"\fno_gitignore\x18\b \x01(\bR\vnoGitignore\"!\n" +
+	"\vStatRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"A\n" +
+	"\rRemoveRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x1c\n" +
+	"\trecursive\x18\x02 \x01(\bR\trecursive\"e\n" +
+	"\vMoveRequest\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12 \n" +
+	"\vdestination\x18\x02 \x01(\tR\vdestination\x12\x1c\n" +
+	"\toverwrite\x18\x03 \x01(\bR\toverwrite\"\x83\x01\n" +
+	"\vCopyRequest\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12 \n" +
+	"\vdestination\x18\x02 \x01(\tR\vdestination\x12\x1c\n" +
+	"\toverwrite\x18\x03 \x01(\bR\toverwrite\x12\x1c\n" +
+	"\trecursive\x18\x04 \x01(\bR\trecursive\"P\n" +
+	"\fMkdirRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x18\n" +
+	"\aparents\x18\x02 \x01(\bR\aparents\x12\x12\n" +
+	"\x04mode\x18\x03 \x01(\rR\x04mode\"6\n" +
+	"\fChmodRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04mode\x18\x02 \x01(\rR\x04mode\".\n" +
+	"\rCancelRequest\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\"n\n" +
+	"\vUploadBegin\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\"r\n" +
+	"\vUploadChunk\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\"/\n" +
+	"\fUploadCommit\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\"D\n" +
+	"\rTransferAbort\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\"\x93\x01\n" +
+	"\rDownloadBegin\x12\x1f\n" +
+	"\vtransfer_id\x18\x01 \x01(\tR\n" +
+	"transferId\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\x12\x1d\n" +
+	"\n" +
+	"chunk_size\x18\x05 \x01(\rR\tchunkSize\"V\n" +
 	"\x12BrowserExecRequest\x12!\n" +
 	"\fcommand_json\x18\x01 \x01(\tR\vcommandJson\x12\x1d\n" +
 	"\n" +
-	"timeout_ms\x18\x02 \x01(\x05R\ttimeoutMs2R\n" +
+	"timeout_ms\x18\x02 \x01(\x05R\ttimeoutMs*z\n" +
+	"\tEntryType\x12\x16\n" +
+	"\x12ENTRY_TYPE_UNKNOWN\x10\x00\x12\x13\n" +
+	"\x0fENTRY_TYPE_FILE\x10\x01\x12\x12\n" +
+	"\x0eENTRY_TYPE_DIR\x10\x02\x12\x16\n" +
+	"\x12ENTRY_TYPE_SYMLINK\x10\x03\x12\x14\n" +
+	"\x10ENTRY_TYPE_OTHER\x10\x04*q\n" +
+	"\vPatchAction\x12\x17\n" +
+	"\x13PATCH_ACTION_MODIFY\x10\x00\x12\x17\n" +
+	"\x13PATCH_ACTION_CREATE\x10\x01\x12\x17\n" +
+	"\x13PATCH_ACTION_DELETE\x10\x02\x12\x17\n" +
+	"\x13PATCH_ACTION_RENAME\x10\x03*\xf9\x02\n" +
+	"\tErrorCode\x12\x1a\n" +
+	"\x16ERROR_CODE_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13ERROR_CODE_CONFLICT\x10\x01\x12\x18\n" +
+	"\x14ERROR_CODE_NOT_FOUND\x10\x02\x12 \n" +
+	"\x1cERROR_CODE_PERMISSION_DENIED\x10\x03\x12\x1f\n" +
+	"\x1bERROR_CODE_INVALID_ARGUMENT\x10\x04\x12\x18\n" +
+	"\x14ERROR_CODE_TOO_LARGE\x10\x05\x12\x1d\n" +
+	"\x19ERROR_CODE_ALREADY_EXISTS\x10\x06\x12\x1e\n" +
+	"\x1aERROR_CODE_AMBIGUOUS_MATCH\x10\a\x12\x16\n" +
+	"\x12ERROR_CODE_TIMEOUT\x10\b\x12\x18\n" +
+	"\x14ERROR_CODE_CANCELLED\x10\t\x12\x1a\n" +
+	"\x16ERROR_CODE_UNAVAILABLE\x10\n" +
+	"\x12\x1a\n" +
+	"\x16ERROR_CODE_UNSUPPORTED\x10\v\x12\x17\n" +
+	"\x13ERROR_CODE_INTERNAL\x10\f*Y\n" +
+	"\n" +
+	"InheritEnv\x12\x1b\n" +
+	"\x17INHERIT_ENV_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14INHERIT_ENV_FILTERED\x10\x01\x12\x14\n" +
+	"\x10INHERIT_ENV_NONE\x10\x02*4\n" +
+	"\bReadMode\x12\x13\n" +
+	"\x0fREAD_MODE_LINES\x10\x00\x12\x13\n" +
+	"\x0fREAD_MODE_BYTES\x10\x01*\x84\x01\n" +
+	"\bEditMode\x12\x15\n" +
+	"\x11EDIT_MODE_REPLACE\x10\x00\x12\x13\n" +
+	"\x0fEDIT_MODE_REGEX\x10\x01\x12\x13\n" +
+	"\x0fEDIT_MODE_LINES\x10\x02\x12\x1b\n" +
+	"\x17EDIT_MODE_INSERT_BEFORE\x10\x03\x12\x1a\n" +
+	"\x16EDIT_MODE_INSERT_AFTER\x10\x042R\n" +
 	"\n" +
 	"HubService\x12D\n" +
 	"\aConnect\x12\x19.epiral.v1.ConnectRequest\x1a\x1a.epiral.v1.ConnectResponse(\x010\x01B\x8f\x01\n" +
@@ -1301,47 +4884,137 @@ func file_epiral_v1_epiral_proto_rawDescGZIP() []byte {
 	return file_epiral_v1_epiral_proto_rawDescData
 }
 
-var file_epiral_v1_epiral_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_epiral_v1_epiral_proto_enumTypes = make([]protoimpl.EnumInfo, 6)
+var file_epiral_v1_epiral_proto_msgTypes = make([]protoimpl.MessageInfo, 49)
 var file_epiral_v1_epiral_proto_goTypes = []any{
-	(*ConnectRequest)(nil),      // 0: epiral.v1.ConnectRequest
-	(*Registration)(nil),        // 1: epiral.v1.Registration
-	(*BrowserRegistration)(nil), // 2: epiral.v1.BrowserRegistration
-	(*ExecOutput)(nil),          // 3: epiral.v1.ExecOutput
-	(*FileContent)(nil),         // 4: epiral.v1.FileContent
-	(*OpResult)(nil),            // 5: epiral.v1.OpResult
-	(*BrowserExecOutput)(nil),   // 6: epiral.v1.BrowserExecOutput
-	(*Ping)(nil),                // 7: epiral.v1.Ping
-	(*Pong)(nil),                // 8: epiral.v1.Pong
-	(*ConnectResponse)(nil),     // 9: epiral.v1.ConnectResponse
-	(*ExecRequest)(nil),         // 10: epiral.v1.ExecRequest
-	(*ReadFileRequest)(nil),     // 11: epiral.v1.ReadFileRequest
-	(*WriteFileRequest)(nil),    // 12: epiral.v1.WriteFileRequest
-	(*EditFileRequest)(nil),     // 13: epiral.v1.EditFileRequest
-	(*BrowserExecRequest)(nil),  // 14: epiral.v1.BrowserExecRequest
-	nil,                         // 15: epiral.v1.Registration.ToolsEntry
+	(EntryType)(0),              // 0: epiral.v1.EntryType
+	(PatchAction)(0),            // 1: epiral.v1.PatchAction
+	(ErrorCode)(0),              // 2: epiral.v1.ErrorCode
+	(InheritEnv)(0),             // 3: epiral.v1.InheritEnv
+	(ReadMode)(0),               // 4: epiral.v1.ReadMode
+	(EditMode)(0),               // 5: epiral.v1.EditMode
+	(*ConnectRequest)(nil),      // 6: epiral.v1.ConnectRequest
+	(*Registration)(nil),        // 7: epiral.v1.Registration
+	(*RegistrationAck)(nil),     // 8: epiral.v1.RegistrationAck
+	(*PathPermission)(nil),      // 9: epiral.v1.PathPermission
+	(*BrowserRegistration)(nil), // 10: epiral.v1.BrowserRegistration
+	(*ExecOutput)(nil),          // 11: epiral.v1.ExecOutput
+	(*FileContent)(nil),         // 12: epiral.v1.FileContent
+	(*DirListing)(nil),          // 13: epiral.v1.DirListing
+	(*DirEntry)(nil),            // 14: epiral.v1.DirEntry
+	(*SearchResult)(nil),        // 15: epiral.v1.SearchResult
+	(*SearchMatch)(nil),         // 16: epiral.v1.SearchMatch
+	(*PatchResult)(nil),         // 17: epiral.v1.PatchResult
+	(*PatchFileResult)(nil),     // 18: epiral.v1.PatchFileResult
+	(*HunkResult)(nil),          // 19: epiral.v1.HunkResult
+	(*FileStat)(nil),            // 20: epiral.v1.FileStat
+	(*OpResult)(nil),            // 21: epiral.v1.OpResult
+	(*FileChange)(nil),          // 22: epiral.v1.FileChange
+	(*TransferStatus)(nil),      // 23: epiral.v1.TransferStatus
+	(*TransferChunk)(nil),       // 24: epiral.v1.TransferChunk
+	(*BrowserExecOutput)(nil),   // 25: epiral.v1.BrowserExecOutput
+	(*Ping)(nil),                // 26: epiral.v1.Ping
+	(*Pong)(nil),                // 27: epiral.v1.Pong
+	(*ConnectResponse)(nil),     // 28: epiral.v1.ConnectResponse
+	(*ExecRequest)(nil),         // 29: epiral.v1.ExecRequest
+	(*ExecInput)(nil),           // 30: epiral.v1.ExecInput
+	(*ExecResize)(nil),          // 31: epiral.v1.ExecResize
+	(*ReadFileRequest)(nil),     // 32: epiral.v1.ReadFileRequest
+	(*WriteFileRequest)(nil),    // 33: epiral.v1.WriteFileRequest
+	(*EditFileRequest)(nil),     // 34: epiral.v1.EditFileRequest
+	(*MultiEditRequest)(nil),    // 35: epiral.v1.MultiEditRequest
+	(*EditOperation)(nil),       // 36: epiral.v1.EditOperation
+	(*ApplyPatchRequest)(nil),   // 37: epiral.v1.ApplyPatchRequest
+	(*ListDirRequest)(nil),      // 38: epiral.v1.ListDirRequest
+	(*SearchRequest)(nil),       // 39: epiral.v1.SearchRequest
+	(*StatRequest)(nil),         // 40: epiral.v1.StatRequest
+	(*RemoveRequest)(nil),       // 41: epiral.v1.RemoveRequest
+	(*MoveRequest)(nil),         // 42: epiral.v1.MoveRequest
+	(*CopyRequest)(nil),         // 43: epiral.v1.CopyRequest
+	(*MkdirRequest)(nil),        // 44: epiral.v1.MkdirRequest
+	(*ChmodRequest)(nil),        // 45: epiral.v1.ChmodRequest
+	(*CancelRequest)(nil),       // 46: epiral.v1.CancelRequest
+	(*UploadBegin)(nil),         // 47: epiral.v1.UploadBegin
+	(*UploadChunk)(nil),         // 48: epiral.v1.UploadChunk
+	(*UploadCommit)(nil),        // 49: epiral.v1.UploadCommit
+	(*TransferAbort)(nil),       // 50: epiral.v1.TransferAbort
+	(*DownloadBegin)(nil),       // 51: epiral.v1.DownloadBegin
+	(*BrowserExecRequest)(nil),  // 52: epiral.v1.BrowserExecRequest
+	nil,                         // 53: epiral.v1.Registration.ToolsEntry
+	nil,                         // 54: epiral.v1.ExecRequest.EnvEntry
 }
 var file_epiral_v1_epiral_proto_depIdxs = []int32{
-	1,  // 0: epiral.v1.ConnectRequest.registration:type_name -> epiral.v1.Registration
-	3,  // 1: epiral.v1.ConnectRequest.exec_output:type_name -> epiral.v1.ExecOutput
-	4,  // 2: epiral.v1.ConnectRequest.file_content:type_name -> epiral.v1.FileContent
-	5,  // 3: epiral.v1.ConnectRequest.op_result:type_name -> epiral.v1.OpResult
-	7,  // 4: epiral.v1.ConnectRequest.ping:type_name -> epiral.v1.Ping
-	2,  // 5: epiral.v1.ConnectRequest.browser_registration:type_name -> epiral.v1.BrowserRegistration
-	6,  // 6: epiral.v1.ConnectRequest.browser_exec_output:type_name -> epiral.v1.BrowserExecOutput
-	15, // 7: epiral.v1.Registration.tools:type_name -> epiral.v1.Registration.ToolsEntry
-	10, // 8: epiral.v1.ConnectResponse.exec:type_name -> epiral.v1.ExecRequest
-	11, // 9: epiral.v1.ConnectResponse.read_file:type_name -> epiral.v1.ReadFileRequest
-	12, // 10: epiral.v1.ConnectResponse.write_file:type_name -> epiral.v1.WriteFileRequest
-	13, // 11: epiral.v1.ConnectResponse.edit_file:type_name -> epiral.v1.EditFileRequest
-	8,  // 12: epiral.v1.ConnectResponse.pong:type_name -> epiral.v1.Pong
-	14, // 13: epiral.v1.ConnectResponse.browser_exec:type_name -> epiral.v1.BrowserExecRequest
-	0,  // 14: epiral.v1.HubService.Connect:input_type -> epiral.v1.ConnectRequest
-	9,  // 15: epiral.v1.HubService.Connect:output_type -> epiral.v1.ConnectResponse
-	15, // [15:16] is the sub-list for method output_type
-	14, // [14:15] is the sub-list for method input_type
-	14, // [14:14] is the sub-list for extension type_name
-	14, // [14:14] is the sub-list for extension extendee
-	0,  // [0:14] is the sub-list for field type_name
+	7,  // 0: epiral.v1.ConnectRequest.registration:type_name -> epiral.v1.Registration
+	11, // 1: epiral.v1.ConnectRequest.exec_output:type_name -> epiral.v1.ExecOutput
+	12, // 2: epiral.v1.ConnectRequest.file_content:type_name -> epiral.v1.FileContent
+	21, // 3: epiral.v1.ConnectRequest.op_result:type_name -> epiral.v1.OpResult
+	26, // 4: epiral.v1.ConnectRequest.ping:type_name -> epiral.v1.Ping
+	10, // 5: epiral.v1.ConnectRequest.browser_registration:type_name -> epiral.v1.BrowserRegistration
+	25, // 6: epiral.v1.ConnectRequest.browser_exec_output:type_name -> epiral.v1.BrowserExecOutput
+	23, // 7: epiral.v1.ConnectRequest.transfer_status:type_name -> epiral.v1.TransferStatus
+	24, // 8: epiral.v1.ConnectRequest.transfer_chunk:type_name -> epiral.v1.TransferChunk
+	13, // 9: epiral.v1.ConnectRequest.dir_listing:type_name -> epiral.v1.DirListing
+	15, // 10: epiral.v1.ConnectRequest.search_result:type_name -> epiral.v1.SearchResult
+	20, // 11: epiral.v1.ConnectRequest.file_stat:type_name -> epiral.v1.FileStat
+	17, // 12: epiral.v1.ConnectRequest.patch_result:type_name -> epiral.v1.PatchResult
+	53, // 13: epiral.v1.Registration.tools:type_name -> epiral.v1.Registration.ToolsEntry
+	9,  // 14: epiral.v1.Registration.paths:type_name -> epiral.v1.PathPermission
+	2,  // 15: epiral.v1.ExecOutput.code:type_name -> epiral.v1.ErrorCode
+	2,  // 16: epiral.v1.FileContent.code:type_name -> epiral.v1.ErrorCode
+	14, // 17: epiral.v1.DirListing.entries:type_name -> epiral.v1.DirEntry
+	2,  // 18: epiral.v1.DirListing.code:type_name -> epiral.v1.ErrorCode
+	0,  // 19: epiral.v1.DirEntry.type:type_name -> epiral.v1.EntryType
+	16, // 20: epiral.v1.SearchResult.matches:type_name -> epiral.v1.SearchMatch
+	2,  // 21: epiral.v1.SearchResult.code:type_name -> epiral.v1.ErrorCode
+	18, // 22: epiral.v1.PatchResult.files:type_name -> epiral.v1.PatchFileResult
+	2,  // 23: epiral.v1.PatchResult.code:type_name -> epiral.v1.ErrorCode
+	1,  // 24: epiral.v1.PatchFileResult.action:type_name -> epiral.v1.PatchAction
+	19, // 25: epiral.v1.PatchFileResult.hunks:type_name -> epiral.v1.HunkResult
+	22, // 26: epiral.v1.PatchFileResult.change:type_name -> epiral.v1.FileChange
+	2,  // 27: epiral.v1.PatchFileResult.code:type_name -> epiral.v1.ErrorCode
+	14, // 28: epiral.v1.FileStat.info:type_name -> epiral.v1.DirEntry
+	2,  // 29: epiral.v1.FileStat.code:type_name -> epiral.v1.ErrorCode
+	2,  // 30: epiral.v1.OpResult.code:type_name -> epiral.v1.ErrorCode
+	22, // 31: epiral.v1.OpResult.change:type_name -> epiral.v1.FileChange
+	2,  // 32: epiral.v1.TransferStatus.code:type_name -> epiral.v1.ErrorCode
+	2,  // 33: epiral.v1.BrowserExecOutput.code:type_name -> epiral.v1.ErrorCode
+	29, // 34: epiral.v1.ConnectResponse.exec:type_name -> epiral.v1.ExecRequest
+	32, // 35: epiral.v1.ConnectResponse.read_file:type_name -> epiral.v1.ReadFileRequest
+	33, // 36: epiral.v1.ConnectResponse.write_file:type_name -> epiral.v1.WriteFileRequest
+	34, // 37: epiral.v1.ConnectResponse.edit_file:type_name -> epiral.v1.EditFileRequest
+	27, // 38: epiral.v1.ConnectResponse.pong:type_name -> epiral.v1.Pong
+	52, // 39: epiral.v1.ConnectResponse.browser_exec:type_name -> epiral.v1.BrowserExecRequest
+	46, // 40: epiral.v1.ConnectResponse.cancel:type_name -> epiral.v1.CancelRequest
+	30, // 41: epiral.v1.ConnectResponse.exec_input:type_name -> epiral.v1.ExecInput
+	31, // 42: epiral.v1.ConnectResponse.exec_resize:type_name -> epiral.v1.ExecResize
+	47, // 43: epiral.v1.ConnectResponse.upload_begin:type_name -> epiral.v1.UploadBegin
+	48, // 44: epiral.v1.ConnectResponse.upload_chunk:type_name -> epiral.v1.UploadChunk
+	49, // 45: epiral.v1.ConnectResponse.upload_commit:type_name -> epiral.v1.UploadCommit
+	50, // 46: epiral.v1.ConnectResponse.transfer_abort:type_name -> epiral.v1.TransferAbort
+	51, // 47: epiral.v1.ConnectResponse.download_begin:type_name -> epiral.v1.DownloadBegin
+	38, // 48: epiral.v1.ConnectResponse.list_dir:type_name -> epiral.v1.ListDirRequest
+	39, // 49: epiral.v1.ConnectResponse.search:type_name -> epiral.v1.SearchRequest
+	40, // 50: epiral.v1.ConnectResponse.stat:type_name -> epiral.v1.StatRequest
+	41, // 51: epiral.v1.ConnectResponse.remove:type_name -> epiral.v1.RemoveRequest
+	42, // 52: epiral.v1.ConnectResponse.move:type_name -> epiral.v1.MoveRequest
+	43, // 53: epiral.v1.ConnectResponse.copy:type_name -> epiral.v1.CopyRequest
+	44, // 54: epiral.v1.ConnectResponse.mkdir:type_name -> epiral.v1.MkdirRequest
+	45, // 55: epiral.v1.ConnectResponse.chmod:type_name -> epiral.v1.ChmodRequest
+	35, // 56: epiral.v1.ConnectResponse.multi_edit:type_name -> epiral.v1.MultiEditRequest
+	37, // 57: epiral.v1.ConnectResponse.apply_patch:type_name -> epiral.v1.ApplyPatchRequest
+	8,  // 58: epiral.v1.ConnectResponse.registration_ack:type_name -> epiral.v1.RegistrationAck
+	54, // 59: epiral.v1.ExecRequest.env:type_name -> epiral.v1.ExecRequest.EnvEntry
+	3,  // 60: epiral.v1.ExecRequest.inherit_env:type_name -> epiral.v1.InheritEnv
+	4,  // 61: epiral.v1.ReadFileRequest.mode:type_name -> epiral.v1.ReadMode
+	5,  // 62: epiral.v1.EditFileRequest.mode:type_name -> epiral.v1.EditMode
+	36, // 63: epiral.v1.MultiEditRequest.edits:type_name -> epiral.v1.EditOperation
+	6,  // 64: epiral.v1.HubService.Connect:input_type -> epiral.v1.ConnectRequest
+	28, // 65: epiral.v1.HubService.Connect:output_type -> epiral.v1.ConnectResponse
+	65, // [65:66] is the sub-list for method output_type
+	64, // [64:65] is the sub-list for method input_type
+	64, // [64:64] is the sub-list for extension type_name
+	64, // [64:64] is the sub-list for extension extendee
+	0,  // [0:64] is the sub-list for field type_name
 }
 
 func init() { file_epiral_v1_epiral_proto_init() }
@@ -1357,27 +5030,53 @@ func file_epiral_v1_epiral_proto_init() {
 		(*ConnectRequest_Ping)(nil),
 		(*ConnectRequest_BrowserRegistration)(nil),
 		(*ConnectRequest_BrowserExecOutput)(nil),
+		(*ConnectRequest_TransferStatus)(nil),
+		(*ConnectRequest_TransferChunk)(nil),
+		(*ConnectRequest_DirListing)(nil),
+		(*ConnectRequest_SearchResult)(nil),
+		(*ConnectRequest_FileStat)(nil),
+		(*ConnectRequest_PatchResult)(nil),
 	}
-	file_epiral_v1_epiral_proto_msgTypes[9].OneofWrappers = []any{
+	file_epiral_v1_epiral_proto_msgTypes[22].OneofWrappers = []any{
 		(*ConnectResponse_Exec)(nil),
 		(*ConnectResponse_ReadFile)(nil),
 		(*ConnectResponse_WriteFile)(nil),
 		(*ConnectResponse_EditFile)(nil),
 		(*ConnectResponse_Pong)(nil),
 		(*ConnectResponse_BrowserExec)(nil),
+		(*ConnectResponse_Cancel)(nil),
+		(*ConnectResponse_ExecInput)(nil),
+		(*ConnectResponse_ExecResize)(nil),
+		(*ConnectResponse_UploadBegin)(nil),
+		(*ConnectResponse_UploadChunk)(nil),
+		(*ConnectResponse_UploadCommit)(nil),
+		(*ConnectResponse_TransferAbort)(nil),
+		(*ConnectResponse_DownloadBegin)(nil),
+		(*ConnectResponse_ListDir)(nil),
+		(*ConnectResponse_Search)(nil),
+		(*ConnectResponse_Stat)(nil),
+		(*ConnectResponse_Remove)(nil),
+		(*ConnectResponse_Move)(nil),
+		(*ConnectResponse_Copy)(nil),
+		(*ConnectResponse_Mkdir)(nil),
+		(*ConnectResponse_Chmod)(nil),
+		(*ConnectResponse_MultiEdit)(nil),
+		(*ConnectResponse_ApplyPatch)(nil),
+		(*ConnectResponse_RegistrationAck)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_epiral_v1_epiral_proto_rawDesc), len(file_epiral_v1_epiral_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   16,
+			NumEnums:      6,
+			NumMessages:   49,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_epiral_v1_epiral_proto_goTypes,
 		DependencyIndexes: file_epiral_v1_epiral_proto_depIdxs,
+		EnumInfos:         file_epiral_v1_epiral_proto_enumTypes,
 		MessageInfos:      file_epiral_v1_epiral_proto_msgTypes,
 	}.Build()
 	File_epiral_v1_epiral_proto = out.File
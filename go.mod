@@ -4,11 +4,10 @@ go 1.25.0
 
 require (
 	connectrpc.com/connect v1.19.1
+	github.com/creack/pty v1.1.24
+	golang.org/x/net v0.49.0
 	google.golang.org/protobuf v1.36.11
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	golang.org/x/net v0.49.0 // indirect
-	golang.org/x/text v0.33.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
-)
+require golang.org/x/text v0.33.0 // indirect
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 
 	"gopkg.in/yaml.v3"
@@ -15,6 +16,7 @@ import (
 type Config struct {
 	Agent    AgentConfig    `yaml:"agent" json:"agent"`
 	Computer ComputerConfig `yaml:"computer" json:"computer"`
+	Browser  BrowserConfig  `yaml:"browser" json:"browser"`
 	Web      WebConfig      `yaml:"web" json:"web"`
 }
 
@@ -28,7 +30,57 @@ type AgentConfig struct {
 type ComputerConfig struct {
 	ID           string   `yaml:"id" json:"id"`
 	Description  string   `yaml:"description" json:"description"`
-	AllowedPaths []string `yaml:"allowed_paths" json:"allowedPaths"`
+	AllowedPaths []string `yaml:"allowed_paths" json:"allowedPaths"` // 拥有全部权限的路径
+	// Paths 按路径细分权限，与 AllowedPaths 一起按最长前缀匹配
+	Paths []PathRule `yaml:"paths,omitempty" json:"paths"`
+	// EnvAllow/EnvDeny 过滤传给子进程的 daemon 环境变量（支持 * 通配），
+	// 用于让 daemon 持有凭据却不暴露给 Agent 执行的命令
+	EnvAllow []string `yaml:"env_allow,omitempty" json:"envAllow"`
+	EnvDeny  []string `yaml:"env_deny,omitempty" json:"envDeny"`
+}
+
+// 路径权限
+const (
+	AccessRead  = "read"  // 读取文件
+	AccessWrite = "write" // 写入、编辑文件
+	AccessExec  = "exec"  // 作为命令的工作目录
+	AccessDeny  = "deny"  // 禁止访问，用于在允许的目录中屏蔽子目录
+)
+
+// PathRule 为一个路径（含其下所有子路径）指定权限。
+// 多条规则都匹配时，路径最长的生效。
+type PathRule struct {
+	Path   string   `yaml:"path" json:"path"`
+	Access []string `yaml:"access" json:"access"` // read / write / exec 的组合，或单独的 deny
+}
+
+// Validate 检查规则是否合法
+func (r PathRule) Validate() error {
+	if r.Path == "" {
+		return fmt.Errorf("路径规则缺少 path")
+	}
+	if len(r.Access) == 0 {
+		return fmt.Errorf("路径 %s 未指定权限", r.Path)
+	}
+	for _, a := range r.Access {
+		switch a {
+		case AccessRead, AccessWrite, AccessExec:
+		case AccessDeny:
+			if len(r.Access) > 1 {
+				return fmt.Errorf("路径 %s 的 deny 不能与其它权限组合", r.Path)
+			}
+		default:
+			return fmt.Errorf("路径 %s 的权限无效: %q（可选 read / write / exec / deny）", r.Path, a)
+		}
+	}
+	return nil
+}
+
+// BrowserConfig 浏览器配置：设置 ID 后在本机开放浏览器插件端点
+type BrowserConfig struct {
+	ID          string `yaml:"id" json:"id"`
+	Description string `yaml:"description" json:"description"`
+	Listen      string `yaml:"listen,omitempty" json:"listen"` // 插件端点监听地址，默认 127.0.0.1:19824
 }
 
 // WebConfig Web 管理面板配置
@@ -36,9 +88,19 @@ type WebConfig struct {
 	Port int `yaml:"port" json:"port"`
 }
 
-// IsConfigured 返回是否已配置最低限度的连接信息
+// IsConfigured 返回是否已配置最低限度的连接信息：Agent 地址，以及电脑或浏览器 ID
 func (c *Config) IsConfigured() bool {
-	return c.Agent.Address != "" && c.Computer.ID != ""
+	return c.Agent.Address != "" && (c.Computer.ID != "" || c.Browser.ID != "")
+}
+
+// Validate 检查配置内容是否合法
+func (c *Config) Validate() error {
+	for _, r := range c.Computer.Paths {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // DefaultConfigDir 返回 ~/.epiral
@@ -81,6 +143,9 @@ func Load(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("配置无效: %w", err)
+	}
 
 	// 确保默认值
 	if cfg.Web.Port == 0 {
@@ -131,9 +196,12 @@ func (s *Store) Get() Config {
 	defer s.mu.RUnlock()
 	c := *s.cfg
 	// 深拷贝 slice
-	if s.cfg.Computer.AllowedPaths != nil {
-		c.Computer.AllowedPaths = make([]string, len(s.cfg.Computer.AllowedPaths))
-		copy(c.Computer.AllowedPaths, s.cfg.Computer.AllowedPaths)
+	c.Computer.AllowedPaths = slices.Clone(s.cfg.Computer.AllowedPaths)
+	c.Computer.EnvAllow = slices.Clone(s.cfg.Computer.EnvAllow)
+	c.Computer.EnvDeny = slices.Clone(s.cfg.Computer.EnvDeny)
+	c.Computer.Paths = make([]PathRule, len(s.cfg.Computer.Paths))
+	for i, r := range s.cfg.Computer.Paths {
+		c.Computer.Paths[i] = PathRule{Path: r.Path, Access: slices.Clone(r.Access)}
 	}
 	return c
 }
@@ -0,0 +1,524 @@
+package daemon
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+const (
+	defaultBrowserListen  = "127.0.0.1:19824"
+	defaultBrowserTimeout = 30 * time.Second
+	browserKeepalive      = 15 * time.Second // SSE 注释行的间隔，防止连接被中间层断开
+	maxBrowserResult      = 16 * 1024 * 1024
+	maxBrowserHistory     = 20 // Web 面板展示的最近命令数
+)
+
+var (
+	errBrowserOffline      = errorf(codeUnavailable, "浏览器插件未连接")
+	errBrowserDisconnected = errorf(codeUnavailable, "浏览器插件已断开")
+	errBrowserClosed       = errorf(codeUnavailable, "连接已断开")
+)
+
+// BrowserStatus 浏览器插件端点的状态快照
+type BrowserStatus struct {
+	ID       string                 `json:"id"`
+	Listen   string                 `json:"listen"`
+	Online   bool                   `json:"online"`             // 至少有一个插件连接
+	LastSeen *time.Time             `json:"lastSeen,omitempty"` // 最后一次收到插件连接、心跳或结果的时间
+	Plugins  []BrowserPluginStatus  `json:"plugins"`
+	Recent   []BrowserCommandStatus `json:"recent"` // 最近的命令，新的在前
+}
+
+// BrowserPluginStatus 一个已连接的插件
+type BrowserPluginStatus struct {
+	ConnectedAt time.Time `json:"connectedAt"`
+	LastSeen    time.Time `json:"lastSeen"`
+	Origin      string    `json:"origin,omitempty"`
+	UserAgent   string    `json:"userAgent,omitempty"`
+}
+
+// BrowserCommandStatus 一条已完成的浏览器命令
+type BrowserCommandStatus struct {
+	RequestID  string    `json:"requestId"`
+	Action     string    `json:"action,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// browserHistory 记录插件最后活动的时间和最近的命令。
+// 由 Manager 持有并交给每次连接的 Daemon，重连后仍然保留。
+type browserHistory struct {
+	mu       sync.Mutex
+	lastSeen time.Time
+	recent   []BrowserCommandStatus // 新的在前
+}
+
+func (h *browserHistory) seen(t time.Time) {
+	h.mu.Lock()
+	h.lastSeen = t
+	h.mu.Unlock()
+}
+
+func (h *browserHistory) record(c BrowserCommandStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recent = append([]BrowserCommandStatus{c}, h.recent[:min(len(h.recent), maxBrowserHistory-1)]...)
+}
+
+// fill 把历史写入状态快照
+func (h *browserHistory) fill(s *BrowserStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.lastSeen.IsZero() {
+		t := h.lastSeen
+		s.LastSeen = &t
+	}
+	s.Recent = slices.Clone(h.recent)
+}
+
+// browserBridge 是浏览器插件的本地端点：
+// 插件通过 GET /sse 订阅命令（event: command），执行后把 Response JSON POST 到 /result，
+// 按其中的 id 与命令对应。可同时连接多个插件，命令发给最后连接的那个。
+type browserBridge struct {
+	mu      sync.Mutex
+	plugins []*browserPlugin
+	calls   map[string]*browserCall // 命令 id → 等待结果的调用
+	closed  bool
+	history *browserHistory
+
+	notifyMu sync.Mutex
+	online   bool              // 最近一次通知的状态
+	onChange func(online bool) // 第一个插件连接 / 最后一个插件断开时调用
+}
+
+// browserPlugin 是一个通过 SSE 连接的插件
+type browserPlugin struct {
+	commands    chan []byte
+	connectedAt time.Time
+	lastSeen    time.Time // 受 browserBridge.mu 保护
+	origin      string
+	userAgent   string
+}
+
+// browserCall 是一条已发给插件、等待结果的命令
+type browserCall struct {
+	plugin *browserPlugin
+	done   chan browserResult
+}
+
+type browserResult struct {
+	json string
+	err  error
+}
+
+func newBrowserBridge(history *browserHistory, onChange func(online bool)) *browserBridge {
+	return &browserBridge{
+		calls:    map[string]*browserCall{},
+		history:  history,
+		onChange: onChange,
+	}
+}
+
+// startBrowser 在 BrowserListen 上启动插件端点，返回的函数关闭端点。
+// 监听失败只记录日志：浏览器命令会返回插件未连接，不影响电脑功能。
+func (d *Daemon) startBrowser() func() {
+	bridge := newBrowserBridge(d.browserHistory, d.sendBrowserRegistration)
+	d.browserMu.Lock()
+	d.browser = bridge
+	d.browserMu.Unlock()
+
+	addr := cmp.Or(d.config.BrowserListen, defaultBrowserListen)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Printf("[浏览器] 监听 %s 失败: %v", addr, err)
+		return bridge.close
+	}
+	srv := &http.Server{
+		Handler:           bridge.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("[浏览器] 插件端点异常: %v", err)
+		}
+	}()
+	log.Printf("[浏览器] 等待插件连接: http://%s/sse", ln.Addr())
+	return func() {
+		bridge.close()
+		_ = srv.Close()
+	}
+}
+
+// currentBrowser 返回本次连接的插件端点，未启动时为 nil
+func (d *Daemon) currentBrowser() *browserBridge {
+	d.browserMu.Lock()
+	defer d.browserMu.Unlock()
+	return d.browser
+}
+
+// fillBrowserStatus 把本次连接中插件的状态写入快照
+func (d *Daemon) fillBrowserStatus(s *BrowserStatus) {
+	if bridge := d.currentBrowser(); bridge != nil {
+		s.Plugins = bridge.pluginStatus()
+		s.Online = len(s.Plugins) > 0
+	}
+}
+
+// handleBrowserExec 把命令转发给插件并回传结果
+func (d *Daemon) handleBrowserExec(ctx context.Context, requestID string, req *v1.BrowserExecRequest) {
+	timeout := defaultBrowserTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+	start := time.Now()
+	command, action, err := browserCommand(req.CommandJson, requestID)
+	bridge := d.currentBrowser()
+	if err == nil && bridge == nil {
+		err = errBrowserOffline
+	}
+	if err == nil {
+		log.Printf("[浏览器] 执行 %s %s (超时 %s)", requestID, action, timeout)
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		var result string
+		if result, err = bridge.exec(ctx, requestID, command); err == nil {
+			d.sendBrowserOutput(requestID, &v1.BrowserExecOutput{ResultJson: result, Done: true})
+		} else if ctx.Err() != nil {
+			// 超时，或被 CancelRequest 取消 / 连接断开
+			err = context.Cause(ctx)
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = errorf(codeTimeout, "浏览器命令超时 (%s)", timeout)
+			}
+		}
+	}
+
+	entry := BrowserCommandStatus{
+		RequestID:  requestID,
+		Action:     action,
+		StartedAt:  start,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		log.Printf("[浏览器] 命令 %s 失败: %v", requestID, err)
+		d.sendBrowserOutput(requestID, &v1.BrowserExecOutput{Error: err.Error(), Code: errorCode(err), Done: true})
+	}
+	d.browserHistory.record(entry)
+}
+
+// browserCommand 把 command_json 的 id 设为 id，插件回传结果时据此对应；同时返回其中的 action
+func browserCommand(commandJSON, id string) (data []byte, action string, err error) {
+	var command map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(commandJSON), &command); err != nil {
+		return nil, "", errorf(codeInvalidArgument, "command_json 无效: %v", err)
+	}
+	if command == nil {
+		return nil, "", errorf(codeInvalidArgument, "command_json 必须是 JSON 对象")
+	}
+	_ = json.Unmarshal(command["action"], &action)
+	command["id"], _ = json.Marshal(id)
+	data, err = json.Marshal(command)
+	return data, action, err
+}
+
+// sendBrowserRegistration 通知 Agent 浏览器上线/下线
+func (d *Daemon) sendBrowserRegistration(online bool) {
+	desc := cmp.Or(d.config.BrowserDesc, d.config.BrowserID)
+	if err := d.send(&v1.ConnectRequest{
+		Payload: &v1.ConnectRequest_BrowserRegistration{BrowserRegistration: &v1.BrowserRegistration{
+			BrowserId:   d.config.BrowserID,
+			Description: desc,
+			Online:      online,
+		}},
+	}); err != nil {
+		log.Printf("[浏览器] 发送注册失败: %v", err)
+		return
+	}
+	if online {
+		log.Printf("[浏览器] 插件已连接，已注册浏览器: %s", d.config.BrowserID)
+	} else {
+		log.Printf("[浏览器] 插件已全部断开，浏览器下线: %s", d.config.BrowserID)
+	}
+}
+
+// sendBrowserOutput 发送浏览器命令结果
+func (d *Daemon) sendBrowserOutput(requestID string, out *v1.BrowserExecOutput) {
+	if err := d.send(&v1.ConnectRequest{
+		RequestId: requestID,
+		Payload:   &v1.ConnectRequest_BrowserExecOutput{BrowserExecOutput: out},
+	}); err != nil {
+		log.Printf("[浏览器] 发送结果失败: %v", err)
+	}
+}
+
+// exec 把命令发给最后连接的插件，等待 id 对应的结果
+func (b *browserBridge) exec(ctx context.Context, id string, command []byte) (string, error) {
+	call := &browserCall{done: make(chan browserResult, 1)}
+	b.mu.Lock()
+	switch {
+	case b.closed:
+		b.mu.Unlock()
+		return "", errBrowserClosed
+	case len(b.plugins) == 0:
+		b.mu.Unlock()
+		return "", errBrowserOffline
+	case b.calls[id] != nil:
+		b.mu.Unlock()
+		return "", errorf(codeInvalidArgument, "命令 id 重复: %s", id)
+	}
+	call.plugin = b.plugins[len(b.plugins)-1]
+	b.calls[id] = call
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.calls, id)
+		b.mu.Unlock()
+	}()
+
+	select {
+	case call.plugin.commands <- command:
+	case r := <-call.done: // 还没发出插件就断开了
+		return "", r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	select {
+	case r := <-call.done:
+		return r.json, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// close 关闭端点：让所有等待中的命令失败，之后不再发送上线/下线通知
+func (b *browserBridge) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, call := range b.calls {
+		call.finish(browserResult{err: errBrowserClosed})
+	}
+}
+
+// finish 交付结果，只有第一次生效
+func (c *browserCall) finish(r browserResult) {
+	select {
+	case c.done <- r:
+	default:
+	}
+}
+
+// handler 返回插件端点的 HTTP 路由
+func (b *browserBridge) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sse", b.handleSSE)
+	mux.HandleFunc("POST /result", b.handleResult)
+	return localOnly(mux)
+}
+
+// handleSSE 保持插件的订阅连接，把命令作为 event: command 推送
+func (b *browserBridge) handleSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	p := &browserPlugin{
+		commands:    make(chan []byte),
+		connectedAt: time.Now(),
+		origin:      r.Header.Get("Origin"),
+		userAgent:   r.UserAgent(),
+	}
+	if !b.attach(p) {
+		http.Error(w, "closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer b.detach(p)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, "event: connected\ndata: {}\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(browserKeepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case command := <-p.commands:
+			if _, err := fmt.Fprintf(w, "event: command\ndata: %s\n\n", command); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+			b.seen(p)
+		}
+	}
+}
+
+// handleResult 接收插件回传的 Response JSON，按 id 交给等待的命令
+func (b *browserBridge) handleResult(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBrowserResult+1))
+	if err != nil {
+		http.Error(w, "读取请求失败", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxBrowserResult {
+		http.Error(w, "结果过大", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.ID == "" {
+		http.Error(w, "缺少 id", http.StatusBadRequest)
+		return
+	}
+
+	b.mu.Lock()
+	call := b.calls[resp.ID]
+	b.mu.Unlock()
+	if call == nil {
+		http.Error(w, "未知的命令 id（可能已超时）", http.StatusNotFound)
+		return
+	}
+	b.seen(call.plugin)
+	call.finish(browserResult{json: string(body)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attach 登记新连接的插件，端点已关闭时返回 false
+func (b *browserBridge) attach(p *browserPlugin) bool {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return false
+	}
+	b.plugins = append(b.plugins, p)
+	n := len(b.plugins)
+	b.mu.Unlock()
+	b.seen(p)
+
+	log.Printf("[浏览器] 插件已连接 (共 %d 个)", n)
+	b.notify()
+	return true
+}
+
+// detach 移除断开的插件，发给它且未完成的命令立即失败
+func (b *browserBridge) detach(p *browserPlugin) {
+	b.mu.Lock()
+	for i, q := range b.plugins {
+		if q == p {
+			b.plugins = append(b.plugins[:i], b.plugins[i+1:]...)
+			break
+		}
+	}
+	for _, call := range b.calls {
+		if call.plugin == p {
+			call.finish(browserResult{err: errBrowserDisconnected})
+		}
+	}
+	n := len(b.plugins)
+	b.mu.Unlock()
+	b.history.seen(time.Now())
+
+	log.Printf("[浏览器] 插件已断开 (剩余 %d 个)", n)
+	b.notify()
+}
+
+// notify 在有无插件的状态变化时调用 onChange。
+// 串行执行并以当前状态为准，插件同时连接、断开时通知也不会乱序。
+func (b *browserBridge) notify() {
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+	b.mu.Lock()
+	online, closed := len(b.plugins) > 0, b.closed
+	b.mu.Unlock()
+	if closed || online == b.online {
+		return
+	}
+	b.online = online
+	if b.onChange != nil {
+		b.onChange(online)
+	}
+}
+
+// seen 记录插件的最后活动时间
+func (b *browserBridge) seen(p *browserPlugin) {
+	now := time.Now()
+	b.mu.Lock()
+	p.lastSeen = now
+	b.mu.Unlock()
+	b.history.seen(now)
+}
+
+// pluginStatus 返回已连接插件的快照，端点关闭后为空
+func (b *browserBridge) pluginStatus() []BrowserPluginStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	plugins := make([]BrowserPluginStatus, 0, len(b.plugins))
+	for _, p := range b.plugins {
+		plugins = append(plugins, BrowserPluginStatus{
+			ConnectedAt: p.connectedAt,
+			LastSeen:    p.lastSeen,
+			Origin:      p.origin,
+			UserAgent:   p.userAgent,
+		})
+	}
+	return plugins
+}
+
+// localOnly 拒绝来自网页的请求：Origin 为 http/https，或 Host 不是本机回环地址。
+// 插件的 Origin 是 chrome-extension:// 等扩展协议，本地工具不带 Origin；
+// DNS rebinding 的网页同源请求也不带 Origin，但 Host 是它自己的域名。
+func localOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://") || !loopbackHost(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// loopbackHost 判断请求的 Host 是否为 127.0.0.1、localhost 或 [::1]，且端口是端点实际监听的端口
+func loopbackHost(r *http.Request) bool {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		return false
+	}
+	switch host {
+	case "127.0.0.1", "localhost", "::1":
+	default:
+		return false
+	}
+	if local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		_, localPort, _ := net.SplitHostPort(local.String())
+		return port == localPort
+	}
+	return true
+}
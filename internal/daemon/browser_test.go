@@ -0,0 +1,290 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// fakePlugin 模拟浏览器插件：订阅 /sse，把收到的命令交给 reply 处理后 POST 到 /result
+type fakePlugin struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func connectFakePlugin(t *testing.T, url string, reply func(command map[string]any) (map[string]any, bool)) *fakePlugin {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url+"/sse", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("订阅失败: %s", resp.Status)
+	}
+
+	p := &fakePlugin{cancel: cancel, done: make(chan struct{})}
+	connected := make(chan struct{})
+	go func() {
+		defer close(p.done)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		var event string
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+				if event == "connected" {
+					close(connected)
+				}
+			case strings.HasPrefix(line, "data: ") && event == "command":
+				var command map[string]any
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &command); err != nil {
+					t.Errorf("命令不是 JSON: %v", err)
+					continue
+				}
+				result, ok := reply(command)
+				if !ok {
+					continue
+				}
+				body, _ := json.Marshal(result)
+				go func() {
+					r, err := http.Post(url+"/result", "application/json", strings.NewReader(string(body)))
+					if err == nil {
+						r.Body.Close()
+					}
+				}()
+			}
+		}
+	}()
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("未收到 connected 事件")
+	}
+	return p
+}
+
+func (p *fakePlugin) disconnect() {
+	p.cancel()
+	<-p.done
+}
+
+// onlineRecorder 记录上线/下线通知
+type onlineRecorder struct {
+	mu     sync.Mutex
+	events []bool
+}
+
+func (r *onlineRecorder) record(online bool) {
+	r.mu.Lock()
+	r.events = append(r.events, online)
+	r.mu.Unlock()
+}
+
+func (r *onlineRecorder) waitFor(t *testing.T, want ...bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		r.mu.Lock()
+		got := append([]bool(nil), r.events...)
+		r.mu.Unlock()
+		if len(got) == len(want) {
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("上线/下线通知 = %v，期望 %v", got, want)
+				}
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("上线/下线通知 = %v，期望 %v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBrowserBridge(t *testing.T) {
+	var rec onlineRecorder
+	history := &browserHistory{}
+	b := newBrowserBridge(history, rec.record)
+	srv := httptest.NewServer(b.handler())
+	defer srv.Close()
+	ctx := context.Background()
+
+	if _, err := b.exec(ctx, "r0", []byte(`{"id":"r0"}`)); !errors.Is(err, errBrowserOffline) {
+		t.Fatalf("无插件时 err = %v，期望 %v", err, errBrowserOffline)
+	}
+
+	echo := connectFakePlugin(t, srv.URL, func(command map[string]any) (map[string]any, bool) {
+		return map[string]any{"id": command["id"], "success": true, "data": command["action"]}, true
+	})
+	rec.waitFor(t, true)
+
+	command, _, err := browserCommand(`{"id":"agent-id","action":"snapshot"}`, "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := b.exec(ctx, "r1", command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal([]byte(result), &resp); err != nil || resp["id"] != "r1" || resp["data"] != "snapshot" {
+		t.Fatalf("结果 = %s", result)
+	}
+
+	if plugins := b.pluginStatus(); len(plugins) != 1 || plugins[0].LastSeen.Before(plugins[0].ConnectedAt) {
+		t.Fatalf("插件状态 = %+v", plugins)
+	}
+
+	// 第二个插件连接后命令发给它；它不回复时命令超时
+	silent := connectFakePlugin(t, srv.URL, func(map[string]any) (map[string]any, bool) { return nil, false })
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := b.exec(timeoutCtx, "r2", []byte(`{"id":"r2"}`)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("不回复时 err = %v，期望超时", err)
+	}
+
+	// 插件断开时，发给它的命令立即失败
+	errc := make(chan error, 1)
+	go func() {
+		_, err := b.exec(ctx, "r3", []byte(`{"id":"r3"}`))
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	silent.disconnect()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errBrowserDisconnected) {
+			t.Fatalf("插件断开时 err = %v，期望 %v", err, errBrowserDisconnected)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("插件断开后命令仍在等待")
+	}
+
+	// 剩下的插件继续工作，全部断开后下线
+	if _, err := b.exec(ctx, "r4", []byte(`{"id":"r4"}`)); err != nil {
+		t.Fatal(err)
+	}
+	echo.disconnect()
+	rec.waitFor(t, true, false)
+	var status BrowserStatus
+	history.fill(&status)
+	if status.LastSeen == nil || len(b.pluginStatus()) != 0 {
+		t.Fatalf("全部断开后状态 = %+v，插件 %+v", status, b.pluginStatus())
+	}
+
+	// 未知 id、缺少 id 的结果被拒绝
+	for body, want := range map[string]int{`{"id":"nope"}`: http.StatusNotFound, `{}`: http.StatusBadRequest} {
+		r, err := http.Post(srv.URL+"/result", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+		if r.StatusCode != want {
+			t.Errorf("POST %s = %d，期望 %d", body, r.StatusCode, want)
+		}
+	}
+
+	// 网页发起的请求被拒绝
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/sse", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	if r.StatusCode != http.StatusForbidden {
+		t.Fatalf("网页来源的订阅 = %d，期望 403", r.StatusCode)
+	}
+
+	// DNS rebinding：不带 Origin，但 Host 不是本机回环地址或端口不符
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	for host, want := range map[string]int{
+		"evil.example:" + port: http.StatusForbidden,
+		"localhost:1":          http.StatusForbidden,
+		"127.0.0.1":            http.StatusForbidden,
+		"localhost:" + port:    http.StatusBadRequest, // 通过检查，缺少 id
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/result", strings.NewReader("{}"))
+		req.Host = host
+		r, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+		if r.StatusCode != want {
+			t.Errorf("Host %s = %d，期望 %d", host, r.StatusCode, want)
+		}
+	}
+
+	b.close()
+	if _, err := b.exec(ctx, "r5", []byte(`{}`)); !errors.Is(err, errBrowserClosed) {
+		t.Fatalf("关闭后 err = %v，期望 %v", err, errBrowserClosed)
+	}
+}
+
+func TestBrowserCommand(t *testing.T) {
+	got, action, err := browserCommand(`{"action":"click","ref":"e3"}`, "req-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"action":"click","id":"req-1","ref":"e3"}` || action != "click" {
+		t.Fatalf("browserCommand = %s, %q", got, action)
+	}
+	for _, bad := range []string{"", "[]", "null", "{"} {
+		if _, _, err := browserCommand(bad, "x"); err == nil {
+			t.Errorf("browserCommand(%q) 应失败", bad)
+		}
+	}
+}
+
+func TestBrowserHistory(t *testing.T) {
+	h := &browserHistory{}
+	for i := range maxBrowserHistory + 5 {
+		h.record(BrowserCommandStatus{RequestID: strconv.Itoa(i)})
+	}
+	var s BrowserStatus
+	h.fill(&s)
+	if len(s.Recent) != maxBrowserHistory || s.Recent[0].RequestID != strconv.Itoa(maxBrowserHistory+4) {
+		t.Fatalf("最近命令 = %d 条，第一条 %q", len(s.Recent), s.Recent[0].RequestID)
+	}
+	if s.LastSeen != nil {
+		t.Fatal("没有插件活动时 LastSeen 应为空")
+	}
+}
+
+func TestBrowserExecUnavailable(t *testing.T) {
+	browserExec := func(hub *testHub, id string) *v1.BrowserExecOutput {
+		msgs := hub.do(t, &v1.ConnectResponse{RequestId: id, Payload: &v1.ConnectResponse_BrowserExec{BrowserExec: &v1.BrowserExecRequest{
+			CommandJson: `{"action":"snapshot"}`,
+		}}}, func(m *v1.ConnectRequest) bool { return m.GetBrowserExecOutput().GetDone() })
+		return msgs[len(msgs)-1].GetBrowserExecOutput()
+	}
+
+	// 未配置浏览器：直接以 UNAVAILABLE 结束，不让 Agent 等到超时
+	_, hub, _ := startTestDaemon(t, Config{})
+	if out := browserExec(hub, "b1"); out.Code != codeUnavailable || out.Error == "" {
+		t.Fatalf("未配置浏览器: %+v", out)
+	}
+
+	// 配置了浏览器但插件未连接
+	_, hub, _ = startTestDaemon(t, Config{BrowserID: "b", BrowserListen: "127.0.0.1:0"})
+	if out := browserExec(hub, "b2"); out.Code != codeUnavailable {
+		t.Fatalf("插件未连接: %+v", out)
+	}
+}
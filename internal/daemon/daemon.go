@@ -20,31 +20,71 @@ import (
 	"connectrpc.com/connect"
 	v1 "github.com/epiral/cli/gen/epiral/v1"
 	"github.com/epiral/cli/gen/epiral/v1/epiralv1connect"
+	"github.com/epiral/cli/internal/config"
 	"golang.org/x/net/http2"
 )
 
+// protocolVersion 是 CLI 实现的协议版本，消息语义不兼容地变化时递增
+const protocolVersion = 1
+
 // Config 是 Daemon 的配置
 type Config struct {
 	AgentAddr    string   // Agent 地址 (如 http://localhost:50051)
 	ComputerID   string   // 电脑 ID
 	ComputerDesc string   // 电脑描述
-	AllowedPaths []string // 允许访问的路径
+	AllowedPaths []string // 允许访问的路径（全部权限）
 	Token        string   // 认证 token
+	EnvAllow     []string // 传给子进程的环境变量白名单（空 = 全部，支持 * 通配）
+	EnvDeny      []string // 环境变量黑名单，优先于白名单
+
+	PathRules []config.PathRule // 按路径细分的权限，与 AllowedPaths 一起按最长前缀匹配
+
+	BrowserID     string // 浏览器 ID，非空时启用浏览器插件端点
+	BrowserDesc   string // 浏览器描述
+	BrowserListen string // 插件端点监听地址（默认 127.0.0.1:19824）
+
+	Version string // CLI 版本，随注册上报
+}
+
+// RejectedError 表示 Agent 拒绝了注册（token 无效、协议版本不兼容等），不修改配置重连也无济于事
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	if e.Reason == "" {
+		return "Agent 拒绝注册"
+	}
+	return "Agent 拒绝注册: " + e.Reason
 }
 
 // Daemon 是核心结构
 type Daemon struct {
-	config      Config
-	stream      *connect.BidiStreamForClient[v1.ConnectRequest, v1.ConnectResponse]
-	sendMu      sync.Mutex // 保护 stream.Send 的并发安全
-	lastPong    time.Time
-	pongMu      sync.Mutex
-	OnConnected func() // 连接成功回调（Manager 使用）
+	config         Config
+	stream         *connect.BidiStreamForClient[v1.ConnectRequest, v1.ConnectResponse]
+	sendMu         sync.Mutex // 保护 stream.Send 的并发安全
+	lastPong       time.Time
+	pongMu         sync.Mutex
+	sessions       *sessionPool // 持久 shell 会话，Run 期间有效
+	requestMu      sync.Mutex
+	requests       map[string]*inflightRequest // 进行中的可取消请求（request_id → 请求）
+	execMu         sync.Mutex
+	execs          map[string]*runningExec // 运行中的命令（request_id → 命令），供终端输入查找
+	fileLocks      pathLocks               // 写入和编辑按路径串行执行
+	transfers      *transfers              // 分块传输，Run 期间有效
+	parts          *partFiles              // 断线时未完成上传的临时文件，跨 Run 保留
+	browserMu      sync.Mutex              // 保护 browser 的替换（Manager.Status 从其他 goroutine 读取）
+	browser        *browserBridge          // 浏览器插件端点，Run 期间有效
+	browserHistory *browserHistory         // 插件最后活动时间和最近的命令，跨 Run 保留
+	OnConnected    func()                  // 连接成功回调（Manager 使用）
+
+	featureMu sync.Mutex
+	features  []string // Agent 在 RegistrationAck 中接受的功能，nil 表示未收到应答
 }
 
 // New 创建一个新的 Daemon
 func New(cfg *Config) *Daemon {
-	return &Daemon{config: *cfg}
+	return &Daemon{config: *cfg, browserHistory: &browserHistory{}, parts: newPartFiles()}
 }
 
 // Run 启动 Daemon，连接 Agent 并处理命令
@@ -72,8 +112,13 @@ func (d *Daemon) Run(ctx context.Context) error {
 	stream := client.Connect(ctx)
 	d.stream = stream
 	defer func() { _ = stream.CloseRequest() }()
+	// ctx 结束时关闭接收端：流建立后 ctx 取消不会打断阻塞中的 Receive
+	defer context.AfterFunc(ctx, func() { _ = stream.CloseResponse() })()
 
 	// 条件注册: Computer
+	d.featureMu.Lock()
+	d.features = nil
+	d.featureMu.Unlock()
 	if d.config.ComputerID != "" {
 		reg := d.buildRegistration()
 		if err := stream.Send(&v1.ConnectRequest{
@@ -84,6 +129,30 @@ func (d *Daemon) Run(ctx context.Context) error {
 		log.Printf("[连接] 已注册电脑: %s (%s/%s)", d.config.ComputerID, reg.Os, reg.Arch)
 	}
 
+	// 条件启用: Browser（插件连接后才注册上线）
+	if d.config.BrowserID != "" {
+		defer d.startBrowser()()
+	}
+
+	// 本次连接启动的 goroutine 都登记在 workers 中，Run 在它们全部结束后才返回，
+	// 不会与下一次 Run 替换的 stream、sessions、transfers 交错
+	var workers sync.WaitGroup
+	defer workers.Wait()
+
+	// Shell 会话池：随本次连接创建，Run 退出时全部销毁
+	d.sessions = newSessionPool(d.shell())
+	defer d.sessions.closeAll()
+	sessionCtx, sessionCancel := context.WithCancel(ctx)
+	defer sessionCancel()
+	workers.Go(func() { d.sessions.reap(sessionCtx) })
+
+	// 分块传输：每个上传的消息由各自的 worker 按序处理
+	d.transfers = newTransfers()
+	defer d.stopTransfers()
+	sweepCtx, sweepCancel := context.WithCancel(ctx)
+	defer sweepCancel()
+	workers.Go(func() { d.sweepParts(sweepCtx) })
+
 	// 初始化 lastPong
 	d.pongMu.Lock()
 	d.lastPong = time.Now()
@@ -92,7 +161,7 @@ func (d *Daemon) Run(ctx context.Context) error {
 	// 启动心跳
 	heartbeatCtx, heartbeatCancel := context.WithCancelCause(ctx)
 	defer heartbeatCancel(nil)
-	go d.heartbeat(heartbeatCtx, heartbeatCancel, 3*time.Second)
+	workers.Go(func() { d.heartbeat(heartbeatCtx, heartbeatCancel, 3*time.Second) })
 
 	// 通知 Manager 连接成功
 	if d.OnConnected != nil {
@@ -101,6 +170,10 @@ func (d *Daemon) Run(ctx context.Context) error {
 
 	log.Println("[连接] 等待 Agent 下发命令...")
 
+	// 请求级 ctx 都派生自 connCtx，断连时一并取消，不留下孤立的 goroutine
+	connCtx, disconnect := context.WithCancelCause(ctx)
+	defer disconnect(errDisconnected)
+
 	// 主循环：接收命令
 	for {
 		resp, err := stream.Receive()
@@ -110,7 +183,30 @@ func (d *Daemon) Run(ctx context.Context) error {
 			}
 			return fmt.Errorf("接收消息失败: %w", err)
 		}
-		go d.handleMessage(ctx, resp)
+		switch payload := resp.Payload.(type) {
+		case *v1.ConnectResponse_RegistrationAck:
+			// 被拒绝时直接结束本次连接，由调用方决定何时重试
+			if err := d.handleRegistrationAck(payload.RegistrationAck); err != nil {
+				return err
+			}
+		case *v1.ConnectResponse_ExecInput, *v1.ConnectResponse_ExecResize:
+			// 终端输入必须保持到达顺序；处理只是入队，不会阻塞接收
+			d.handleMessage(connCtx, resp)
+		case *v1.ConnectResponse_UploadBegin, *v1.ConnectResponse_UploadChunk,
+			*v1.ConnectResponse_UploadCommit, *v1.ConnectResponse_TransferAbort:
+			// 同一上传的数据块必须按序写入，交给该传输的 worker；入队不会阻塞接收
+			d.queueUpload(connCtx, resp)
+		default:
+			if req := resp.GetExec(); req != nil && d.config.ComputerID != "" {
+				// 命令在接收循环中登记，之后到达的 ExecInput / ExecResize 一定能找到它
+				d.trackExec(resp.RequestId, req.Pty)
+			}
+			reqCtx, done := d.beginRequest(connCtx, resp)
+			workers.Go(func() {
+				defer done()
+				d.handleMessage(reqCtx, resp)
+			})
+		}
 	}
 }
 
@@ -127,17 +223,111 @@ func (d *Daemon) handleMessage(ctx context.Context, msg *v1.ConnectResponse) {
 		if d.config.ComputerID == "" {
 			return
 		}
-		d.handleReadFile(msg.RequestId, payload.ReadFile)
+		d.handleReadFile(ctx, msg.RequestId, payload.ReadFile)
 	case *v1.ConnectResponse_WriteFile:
 		if d.config.ComputerID == "" {
 			return
 		}
-		d.handleWriteFile(msg.RequestId, payload.WriteFile)
+		d.handleWriteFile(ctx, msg.RequestId, payload.WriteFile)
 	case *v1.ConnectResponse_EditFile:
 		if d.config.ComputerID == "" {
 			return
 		}
-		d.handleEditFile(msg.RequestId, payload.EditFile)
+		d.handleEditFile(ctx, msg.RequestId, payload.EditFile)
+	case *v1.ConnectResponse_MultiEdit:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleMultiEdit(ctx, msg.RequestId, payload.MultiEdit)
+	case *v1.ConnectResponse_ApplyPatch:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleApplyPatch(ctx, msg.RequestId, payload.ApplyPatch)
+	case *v1.ConnectResponse_ListDir:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleListDir(ctx, msg.RequestId, payload.ListDir)
+	case *v1.ConnectResponse_Search:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleSearch(ctx, msg.RequestId, payload.Search)
+	case *v1.ConnectResponse_Stat:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleStat(msg.RequestId, payload.Stat)
+	case *v1.ConnectResponse_Remove:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleRemove(ctx, msg.RequestId, payload.Remove)
+	case *v1.ConnectResponse_Move:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleMove(ctx, msg.RequestId, payload.Move)
+	case *v1.ConnectResponse_Copy:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleCopy(ctx, msg.RequestId, payload.Copy)
+	case *v1.ConnectResponse_Mkdir:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleMkdir(msg.RequestId, payload.Mkdir)
+	case *v1.ConnectResponse_Chmod:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleChmod(msg.RequestId, payload.Chmod)
+	case *v1.ConnectResponse_Cancel:
+		d.handleCancel(msg.RequestId, payload.Cancel)
+	case *v1.ConnectResponse_ExecInput:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleExecInput(msg.RequestId, payload.ExecInput)
+	case *v1.ConnectResponse_ExecResize:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleExecResize(msg.RequestId, payload.ExecResize)
+	case *v1.ConnectResponse_UploadBegin:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleUploadBegin(ctx, msg.RequestId, payload.UploadBegin)
+	case *v1.ConnectResponse_UploadChunk:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleUploadChunk(ctx, msg.RequestId, payload.UploadChunk)
+	case *v1.ConnectResponse_UploadCommit:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleUploadCommit(ctx, msg.RequestId, payload.UploadCommit)
+	case *v1.ConnectResponse_TransferAbort:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleTransferAbort(msg.RequestId, payload.TransferAbort)
+	case *v1.ConnectResponse_DownloadBegin:
+		if d.config.ComputerID == "" {
+			return
+		}
+		d.handleDownloadBegin(ctx, msg.RequestId, payload.DownloadBegin)
+	case *v1.ConnectResponse_BrowserExec:
+		if d.config.BrowserID == "" {
+			log.Printf("[连接] 收到 BrowserExec 但未启用浏览器功能")
+			d.sendBrowserOutput(msg.RequestId, &v1.BrowserExecOutput{Error: "未启用浏览器功能", Code: codeUnavailable, Done: true})
+			return
+		}
+		d.handleBrowserExec(ctx, msg.RequestId, payload.BrowserExec)
 	case *v1.ConnectResponse_Pong:
 		d.pongMu.Lock()
 		d.lastPong = time.Now()
@@ -175,9 +365,51 @@ func (d *Daemon) buildRegistration() *v1.Registration {
 		Shell:        shell,
 		HomeDir:      homeDir,
 		Tools:        detectTools(),
-		AllowedPaths: d.config.AllowedPaths,
+		AllowedPaths: d.reachablePaths(),
 		Token:        d.config.Token,
+		Paths:        d.pathPermissions(),
+
+		ProtocolVersion: protocolVersion,
+		CliVersion:      d.config.Version,
+		Capabilities:    d.capabilities(),
+	}
+}
+
+// capabilities 返回本 CLI 支持的功能（取值见 proto 中 Registration 的说明）
+func (d *Daemon) capabilities() []string {
+	caps := []string{
+		"exec.session", "exec.pty", "exec.env", "cancel",
+		"file.binary", "file.edit", "file.patch", "file.change",
+		"file.list", "file.search", "file.manage", "transfer",
+	}
+	if d.config.BrowserID != "" {
+		caps = append(caps, "browser")
 	}
+	return caps
+}
+
+// handleRegistrationAck 处理注册应答：记录 Agent 接受的功能，被拒绝时返回 *RejectedError
+func (d *Daemon) handleRegistrationAck(ack *v1.RegistrationAck) error {
+	if !ack.Accepted {
+		err := &RejectedError{Reason: ack.Reason}
+		log.Printf("[连接] %v", err)
+		return err
+	}
+	d.featureMu.Lock()
+	d.features = ack.Features
+	if d.features == nil {
+		d.features = []string{}
+	}
+	d.featureMu.Unlock()
+	log.Printf("[连接] 注册已确认 (Agent 协议 v%d)，启用功能: %s", ack.ProtocolVersion, strings.Join(ack.Features, ", "))
+	return nil
+}
+
+// acceptedFeatures 返回 Agent 接受的功能，未收到应答时返回 nil
+func (d *Daemon) acceptedFeatures() []string {
+	d.featureMu.Lock()
+	defer d.featureMu.Unlock()
+	return d.features
 }
 
 // detectTools 检测本机工具及版本
@@ -242,19 +474,6 @@ func (d *Daemon) heartbeat(ctx context.Context, cancel context.CancelCauseFunc,
 	}
 }
 
-// isPathAllowed 检查路径权限
-func (d *Daemon) isPathAllowed(path string) bool {
-	if len(d.config.AllowedPaths) == 0 {
-		return true
-	}
-	for _, allowed := range d.config.AllowedPaths {
-		if path == allowed || strings.HasPrefix(path, allowed+"/") {
-			return true
-		}
-	}
-	return false
-}
-
 // shell 返回当前 shell 路径
 func (d *Daemon) shell() string {
 	shell := os.Getenv("SHELL")
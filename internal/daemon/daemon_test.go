@@ -0,0 +1,190 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+	"github.com/epiral/cli/gen/epiral/v1/epiralv1connect"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// testHub 是进程内的 HubService：转发下行消息、记录上行消息并应答心跳
+type testHub struct {
+	down chan *v1.ConnectResponse
+
+	mu  sync.Mutex
+	got []*v1.ConnectRequest
+}
+
+func (h *testHub) Connect(ctx context.Context, stream *connect.BidiStream[v1.ConnectRequest, v1.ConnectResponse]) error {
+	var sendMu sync.Mutex
+	send := func(msg *v1.ConnectResponse) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		_ = stream.Send(msg)
+	}
+	// 转发 goroutine 结束后处理函数才能返回，之后不能再写流
+	ctx, cancel := context.WithCancel(ctx)
+	forwarded := make(chan struct{})
+	defer func() {
+		cancel()
+		<-forwarded
+	}()
+	go func() {
+		defer close(forwarded)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-h.down:
+				send(msg)
+			}
+		}
+	}()
+	for {
+		req, err := stream.Receive()
+		if err != nil {
+			return nil
+		}
+		if req.GetPing() != nil {
+			send(&v1.ConnectResponse{Payload: &v1.ConnectResponse_Pong{Pong: &v1.Pong{}}})
+			continue
+		}
+		h.mu.Lock()
+		h.got = append(h.got, req)
+		h.mu.Unlock()
+	}
+}
+
+// messages 返回 request_id 为 id 的上行消息
+func (h *testHub) messages(id string) []*v1.ConnectRequest {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var msgs []*v1.ConnectRequest
+	for _, m := range h.got {
+		if m.RequestId == id {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// wait 等到 request_id 为 id 的某条上行消息满足 until，返回该 id 的全部上行消息
+func (h *testHub) wait(t *testing.T, id string, until func(*v1.ConnectRequest) bool) []*v1.ConnectRequest {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		msgs := h.messages(id)
+		for _, m := range msgs {
+			if until(m) {
+				return msgs
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待请求 %s 的结果超时，已收到 %d 条消息", id, len(msgs))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// do 下发 msg 并等待其结果
+func (h *testHub) do(t *testing.T, msg *v1.ConnectResponse, until func(*v1.ConnectRequest) bool) []*v1.ConnectRequest {
+	t.Helper()
+	h.down <- msg
+	return h.wait(t, msg.RequestId, until)
+}
+
+func execFinished(m *v1.ConnectRequest) bool { return m.GetExecOutput().GetDone() }
+
+func hasOpResult(m *v1.ConnectRequest) bool { return m.GetOpResult() != nil }
+
+// execResultOf 汇总一次执行的 stdout、stderr 和结束消息
+func execResultOf(msgs []*v1.ConnectRequest) (stdout, stderr string, last *v1.ExecOutput) {
+	var out, errOut strings.Builder
+	for _, m := range msgs {
+		o := m.GetExecOutput()
+		if o == nil {
+			continue
+		}
+		out.WriteString(o.Stdout)
+		errOut.WriteString(o.Stderr)
+		if o.Done {
+			last = o
+		}
+	}
+	return out.String(), errOut.String(), last
+}
+
+// startTestDaemon 启动连接到进程内 testHub 的 Daemon。返回的 stop 断开连接并等待 Run 返回，
+// 测试结束时也会自动调用。
+func startTestDaemon(t *testing.T, cfg Config) (d *Daemon, hub *testHub, stop func()) {
+	t.Helper()
+	hub = &testHub{down: make(chan *v1.ConnectResponse, 16)}
+	mux := http.NewServeMux()
+	mux.Handle(epiralv1connect.NewHubServiceHandler(hub))
+	srv := httptest.NewServer(h2c.NewHandler(mux, &http2.Server{}))
+
+	cfg.AgentAddr = srv.URL
+	if cfg.ComputerID == "" && cfg.BrowserID == "" {
+		cfg.ComputerID = "test"
+	}
+	d = New(&cfg)
+	connected := make(chan struct{})
+	d.OnConnected = func() { close(connected) }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = d.Run(ctx)
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Error("Run 未在断开后返回")
+			}
+			srv.Close()
+		})
+	}
+	t.Cleanup(stop)
+
+	select {
+	case <-connected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("未能连接到 testHub")
+	}
+	return d, hub, stop
+}
+
+func TestRunWaitsForHandlers(t *testing.T) {
+	dir := t.TempDir()
+	d, hub, stop := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	hub.down <- &v1.ConnectResponse{RequestId: "sleep", Payload: &v1.ConnectResponse_Exec{Exec: &v1.ExecRequest{
+		Command: "echo started; sleep 30", Workdir: dir,
+	}}}
+	hub.wait(t, "sleep", func(m *v1.ConnectRequest) bool { return strings.Contains(m.GetExecOutput().GetStdout(), "started") })
+
+	// 断开后 Run 先取消并等待进行中的请求，再返回
+	start := time.Now()
+	stop()
+	if elapsed := time.Since(start); elapsed > 8*time.Second {
+		t.Fatalf("Run 用了 %s 才返回", elapsed)
+	}
+	d.requestMu.Lock()
+	defer d.requestMu.Unlock()
+	if len(d.requests) != 0 {
+		t.Fatalf("Run 返回时仍有 %d 个请求未结束", len(d.requests))
+	}
+}
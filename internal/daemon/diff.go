@@ -0,0 +1,278 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+const (
+	diffContext    = 3           // hunk 在改动前后各带的上下文行数
+	maxDiffBytes   = 64 * 1024   // 返回的 diff 最大字节数，超出截断
+	maxDiffInput   = 1024 * 1024 // 旧/新内容超过此大小时不生成 diff
+	maxDiffChanges = 1000        // 编辑距离超过此值时不再求最短 diff，整段按删除+新增处理
+)
+
+// diffLine 是编辑脚本中的一行：' ' 不变、'-' 删除、'+' 新增；text 保留换行符
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// fileChange 计算文件从 oldData 变为 newData 后的 FileChange（新哈希、行数、diff）。
+// oldName 为空表示新建，newName 为空表示删除；
+// 旧内容过大未读取时 oldData 传 nil 且 oldName 非空，此时不生成 diff。
+func fileChange(oldName, newName string, oldData, newData []byte) *v1.FileChange {
+	c := &v1.FileChange{}
+	if newName != "" {
+		c.Sha256 = hashBytes(newData)
+		if isText(newData) {
+			c.LineCount = int64(countLines(newData))
+		}
+	}
+	if (oldName != "" && oldData == nil) || !diffable(oldData) || !diffable(newData) {
+		c.DiffTruncated = true
+		return c
+	}
+
+	lines := diffLines(splitLines(string(oldData)), splitLines(string(newData)))
+	for _, l := range lines {
+		switch l.op {
+		case '+':
+			c.LinesAdded++
+		case '-':
+			c.LinesRemoved++
+		}
+	}
+	c.Diff, c.DiffTruncated = unifiedDiff(oldName, newName, lines)
+	return c
+}
+
+// logChange 把一次写入/编辑的结果记入日志，供 Web 面板查看
+func logChange(action, path string, c *v1.FileChange) {
+	if c.Diff == "" && c.DiffTruncated {
+		log.Printf("[文件] %s %s 完成（未生成 diff）", action, path)
+		return
+	}
+	log.Printf("[文件] %s %s 完成: +%d -%d，%d 行", action, path, c.LinesAdded, c.LinesRemoved, c.LineCount)
+}
+
+// diffable 判断内容是否适合生成文本 diff
+func diffable(data []byte) bool {
+	return len(data) <= maxDiffInput && isText(data)
+}
+
+// isText 判断内容是否为文本：合法 UTF-8 且不含 NUL
+func isText(data []byte) bool {
+	return bytes.IndexByte(data, 0) < 0 && utf8.Valid(data)
+}
+
+// countLines 返回行数，最后一行没有换行符也算一行
+func countLines(data []byte) int {
+	n := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// diffLines 返回把 a 变成 b 的编辑脚本。先去掉公共前后缀，中间部分用 Myers 算法求最短编辑。
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b)-prefix-suffix)
+	for _, l := range a[:prefix] {
+		lines = append(lines, diffLine{' ', l})
+	}
+	lines = append(lines, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', l})
+	}
+	return lines
+}
+
+// myersDiff 用 Myers O(ND) 算法求最短编辑脚本。
+// 编辑距离超过 maxDiffChanges 时放弃，整段按删除 a、新增 b 处理。
+func myersDiff(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffChanges)
+	off := limit + 1
+	v := make([]int, 2*limit+3) // v[off+k]：对角线 k 上走得最远的 x
+	var trace [][]int           // trace[d]：第 d 轮结束时对角线 -d..d 的 v
+	done := false
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1] // 从 k+1 向下：新增 b 的一行
+			} else {
+				x = v[off+k-1] + 1 // 从 k-1 向右：删除 a 的一行
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			done = done || x >= n && y >= m
+		}
+		trace = append(trace, slices.Clone(v[off-d:off+d+1]))
+		if done {
+			return myersBacktrack(a, b, trace)
+		}
+	}
+
+	lines := make([]diffLine, 0, n+m)
+	for _, l := range a {
+		lines = append(lines, diffLine{'-', l})
+	}
+	for _, l := range b {
+		lines = append(lines, diffLine{'+', l})
+	}
+	return lines
+}
+
+// myersBacktrack 从终点沿 trace 倒推出编辑脚本
+func myersBacktrack(a, b []string, trace [][]int) []diffLine {
+	var lines []diffLine
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1] // prev[k+d-1] 是第 d-1 轮对角线 k 的 x
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, diffLine{' ', a[x]})
+		}
+		if prevK == k+1 {
+			lines = append(lines, diffLine{'+', b[prevY]})
+		} else {
+			lines = append(lines, diffLine{'-', a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 {
+		x--
+		lines = append(lines, diffLine{' ', a[x]})
+	}
+	slices.Reverse(lines)
+	return lines
+}
+
+// unifiedDiff 把编辑脚本格式化为 unified diff，超过 maxDiffBytes 时在行边界截断。
+// oldName 为空时旧文件写作 /dev/null，newName 同理。
+func unifiedDiff(oldName, newName string, lines []diffLine) (string, bool) {
+	var b strings.Builder
+	write := func(s string) bool {
+		if b.Len()+len(s) > maxDiffBytes {
+			return false
+		}
+		b.WriteString(s)
+		return true
+	}
+
+	oldLine, newLine := 1, 1 // lines[i] 对应的旧/新行号
+	header := false
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// 以 i 处的改动开始一个 hunk，相邻改动间隔不超过 2*diffContext 行时合并
+		start := max(i-diffContext, 0)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].op != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(lines))
+
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		var oldCount, newCount int
+		for _, l := range lines[start:end] {
+			if l.op != '+' {
+				oldCount++
+			}
+			if l.op != '-' {
+				newCount++
+			}
+		}
+
+		if !header {
+			if !write(fmt.Sprintf("--- %s\n+++ %s\n", diffName(oldName), diffName(newName))) {
+				return "", true
+			}
+			header = true
+		}
+		if !write(fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))) {
+			return b.String(), true
+		}
+		for _, l := range lines[start:end] {
+			s := string(l.op) + l.text
+			if !strings.HasSuffix(s, "\n") {
+				s += "\n\\ No newline at end of file\n"
+			}
+			if !write(s) {
+				return b.String(), true
+			}
+		}
+
+		for _, l := range lines[i:end] {
+			if l.op != '+' {
+				oldLine++
+			}
+			if l.op != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+	return b.String(), false
+}
+
+// diffName 返回 diff 头中的文件名
+func diffName(name string) string {
+	if name == "" {
+		return "/dev/null"
+	}
+	return name
+}
+
+// hunkRange 格式化 hunk 头中的行范围：count 为 1 时省略，为 0 时起始行是前一行
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
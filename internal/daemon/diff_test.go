@@ -0,0 +1,147 @@
+package daemon
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestFileChange(t *testing.T) {
+	tests := []struct {
+		name             string
+		oldName, newName string
+		oldText, newText string
+		want             string
+		added, removed   int32
+		lines            int64
+	}{
+		{
+			name:    "修改一行",
+			oldName: "f", newName: "f",
+			oldText: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			newText: "1\n2\n3\n4\nX\n6\n7\n8\n9\n",
+			want:    "--- f\n+++ f\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+X\n 6\n 7\n 8\n",
+			added:   1, removed: 1, lines: 9,
+		},
+		{
+			name:    "相距较远的改动分成两个 hunk",
+			oldName: "f", newName: "f",
+			oldText: "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			newText: "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want:    "--- f\n+++ f\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+			added:   2, removed: 2, lines: 9,
+		},
+		{
+			name:    "新建",
+			newName: "f",
+			newText: "a\nb",
+			want:    "--- /dev/null\n+++ f\n@@ -0,0 +1,2 @@\n+a\n+b\n\\ No newline at end of file\n",
+			added:   2, lines: 2,
+		},
+		{
+			name:    "删除",
+			oldName: "f",
+			oldText: "a\n",
+			want:    "--- f\n+++ /dev/null\n@@ -1 +0,0 @@\n-a\n",
+			removed: 1,
+		},
+		{
+			name:    "没有改动",
+			oldName: "f", newName: "f",
+			oldText: "a\n", newText: "a\n",
+			lines: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fileChange(tt.oldName, tt.newName, []byte(tt.oldText), []byte(tt.newText))
+			if c.Diff != tt.want || c.DiffTruncated {
+				t.Fatalf("diff = %q (truncated=%v)，期望 %q", c.Diff, c.DiffTruncated, tt.want)
+			}
+			if c.LinesAdded != tt.added || c.LinesRemoved != tt.removed || c.LineCount != tt.lines {
+				t.Fatalf("统计 = +%d -%d %d 行，期望 +%d -%d %d 行",
+					c.LinesAdded, c.LinesRemoved, c.LineCount, tt.added, tt.removed, tt.lines)
+			}
+			if tt.newName != "" && c.Sha256 != hashBytes([]byte(tt.newText)) {
+				t.Fatal("sha256 不是新内容的哈希")
+			}
+		})
+	}
+}
+
+func TestFileChangeLimits(t *testing.T) {
+	big := strings.Repeat("x\n", maxDiffInput)
+	if c := fileChange("f", "f", []byte("a\n"), []byte(big)); c.Diff != "" || !c.DiffTruncated || c.LineCount != maxDiffInput {
+		t.Fatalf("过大的内容不应生成 diff: %+v", c)
+	}
+	if c := fileChange("f", "f", nil, []byte("a\n")); c.Diff != "" || !c.DiffTruncated {
+		t.Fatalf("未读取旧内容时不应生成 diff: %+v", c)
+	}
+	if c := fileChange("f", "f", []byte("a\n"), []byte("a\x00b\n")); c.Diff != "" || !c.DiffTruncated {
+		t.Fatalf("二进制内容不应生成 diff: %+v", c)
+	}
+
+	var b strings.Builder
+	for b.Len() < 2*maxDiffBytes {
+		b.WriteString("some longer line of text\n")
+	}
+	c := fileChange("", "f", nil, []byte(b.String()))
+	if !c.DiffTruncated || len(c.Diff) > maxDiffBytes || !strings.HasSuffix(c.Diff, "\n") {
+		t.Fatalf("diff 应在行边界截断到 %d 字节以内: %d 字节, truncated=%v", maxDiffBytes, len(c.Diff), c.DiffTruncated)
+	}
+	if c.LinesAdded != int32(c.LineCount) {
+		t.Fatalf("截断后统计仍应覆盖全部改动: +%d，%d 行", c.LinesAdded, c.LineCount)
+	}
+}
+
+// 生成的 diff 应用到旧内容上应得到新内容，且改动行数最少
+func TestDiffRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	words := []string{"a\n", "b\n", "c\n", "d\n", "e\n", "f"}
+	random := func() string {
+		var b strings.Builder
+		for range r.IntN(30) {
+			b.WriteString(words[r.IntN(len(words))])
+		}
+		return b.String()
+	}
+	for i := range 500 {
+		oldText, newText := random(), random()
+		c := fileChange("f", "f", []byte(oldText), []byte(newText))
+		if c.Diff == "" {
+			if oldText != newText {
+				t.Fatalf("#%d 内容不同但 diff 为空: %q -> %q", i, oldText, newText)
+			}
+			continue
+		}
+		files, err := parsePatch(c.Diff)
+		if err != nil {
+			t.Fatalf("#%d diff 无法解析: %v\n%s", i, err, c.Diff)
+		}
+		got, results, ok := applyHunks(oldText, files[0].hunks, 0)
+		if !ok || got != newText {
+			t.Fatalf("#%d 应用 diff 得到 %q，期望 %q (%+v)\n%s", i, got, newText, results, c.Diff)
+		}
+		if lcs := lcsLen(splitLines(oldText), splitLines(newText)); int(c.LinesRemoved) != len(splitLines(oldText))-lcs {
+			t.Fatalf("#%d 不是最短编辑: -%d，期望 -%d", i, c.LinesRemoved, len(splitLines(oldText))-lcs)
+		}
+	}
+}
+
+// lcsLen 返回最长公共子序列的长度
+func lcsLen(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				dp[i+1][j+1] = dp[i][j] + 1
+			} else {
+				dp[i+1][j+1] = max(dp[i][j+1], dp[i+1][j])
+			}
+		}
+	}
+	return dp[len(a)][len(b)]
+}
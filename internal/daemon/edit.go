@@ -0,0 +1,300 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// editError 是多处编辑中某一处失败，index 从 1 开始
+type editError struct {
+	index int
+	err   error
+}
+
+func (e *editError) Error() string {
+	return fmt.Sprintf("第 %d 处编辑失败: %v", e.index, e.err)
+}
+
+func (e *editError) Unwrap() error { return e.err }
+
+// handleEditFile 编辑文件：查找替换、正则替换、按行号替换或插入
+func (d *Daemon) handleEditFile(ctx context.Context, requestID string, req *v1.EditFileRequest) {
+	log.Printf("[文件] 编辑 %s", req.Path)
+	d.editFile(ctx, requestID, req.Path, req.ExpectedHash, func(content string) (string, error) {
+		return applyEdit(content, req)
+	})
+}
+
+// handleMultiEdit 在内存中按顺序应用多处查找替换，全部成功才写回文件
+func (d *Daemon) handleMultiEdit(ctx context.Context, requestID string, req *v1.MultiEditRequest) {
+	log.Printf("[文件] 编辑 %s (%d 处)", req.Path, len(req.Edits))
+	d.editFile(ctx, requestID, req.Path, req.ExpectedHash, func(content string) (string, error) {
+		if len(req.Edits) == 0 {
+			return "", errorf(codeInvalidArgument, "edits 不能为空")
+		}
+		for i, e := range req.Edits {
+			var err error
+			if content, err = replaceString(content, e.OldString, e.NewString, e.ReplaceAll); err != nil {
+				return "", &editError{index: i + 1, err: err}
+			}
+		}
+		return content, nil
+	})
+}
+
+// editFile 读取文件、校验 expected_hash，用 edit 计算新内容后原子写回，并发送结果。
+// edit 返回的错误作为失败原因；*editError 会带上失败的编辑序号。请求在写回前被取消则不写回。
+func (d *Daemon) editFile(ctx context.Context, requestID, reqPath, expectedHash string, edit func(content string) (string, error)) {
+	path, err := d.resolvePath(reqPath, accessRead|accessWrite)
+	if err != nil {
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(reqPath, err))
+		return
+	}
+	// 从读取、校验 expected_hash 到写回完成都持有该路径的锁
+	defer d.fileLocks.lock(path)()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("读取失败: %v", err))
+		return
+	}
+	if current := hashBytes(data); expectedHash != "" && !strings.EqualFold(current, expectedHash) {
+		d.sendConflict(requestID, path, current)
+		return
+	}
+
+	newContent, err := edit(string(data))
+	if err != nil {
+		result := &v1.OpResult{Error: err.Error(), Code: errorCode(err)}
+		var ee *editError
+		if errors.As(err, &ee) {
+			result.FailedEdit = int32(ee.index) //nolint:gosec // 编辑数来自单条消息，不会溢出
+		}
+		d.sendResult(requestID, result)
+		return
+	}
+
+	if err := cancelled(ctx); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("未写回: %v", err))
+		return
+	}
+	if err := writeFileAtomic(path, []byte(newContent), 0); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("写回失败: %v", err))
+		return
+	}
+	change := fileChange(path, path, data, []byte(newContent))
+	logChange("编辑", path, change)
+	snippet, line := editSnippet(string(data), newContent)
+	d.sendResult(requestID, &v1.OpResult{
+		Success:          true,
+		Snippet:          snippet,
+		SnippetStartLine: int32(line), //nolint:gosec // 行号不会溢出
+		Change:           change,
+	})
+}
+
+// applyEdit 按 req.Mode 计算编辑后的内容
+func applyEdit(content string, req *v1.EditFileRequest) (string, error) {
+	switch req.Mode {
+	case v1.EditMode_EDIT_MODE_REGEX:
+		if req.OldString == "" {
+			return "", errorf(codeInvalidArgument, "old_string 不能为空")
+		}
+		expr := req.OldString
+		if req.IgnoreWhitespace {
+			expr = looseWhitespace(expr, false)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return "", errorf(codeInvalidArgument, "正则无效: %v", err)
+		}
+		return replacePattern(content, re, req.NewString, req.ReplaceAll, false)
+	case v1.EditMode_EDIT_MODE_LINES:
+		return replaceLines(content, int(req.StartLine), int(req.EndLine), req.NewString)
+	case v1.EditMode_EDIT_MODE_INSERT_BEFORE:
+		return insertLines(content, int(req.StartLine)-1, req.NewString)
+	case v1.EditMode_EDIT_MODE_INSERT_AFTER:
+		return insertLines(content, int(req.StartLine), req.NewString)
+	default:
+		if req.IgnoreWhitespace && strings.TrimSpace(req.OldString) != "" {
+			re := regexp.MustCompile(looseWhitespace(req.OldString, true))
+			return replacePattern(content, re, req.NewString, req.ReplaceAll, true)
+		}
+		return replaceString(content, req.OldString, req.NewString, req.ReplaceAll)
+	}
+}
+
+// replaceString 把 content 中的 oldString 替换为 newString。replaceAll 为 false 时 oldString 必须恰好出现一次。
+func replaceString(content, oldString, newString string, replaceAll bool) (string, error) {
+	if oldString == "" {
+		return "", errorf(codeInvalidArgument, "old_string 不能为空")
+	}
+	count := strings.Count(content, oldString)
+	if count == 0 {
+		return "", errorf(codeNotFound, "old_string 未找到")
+	}
+	if !replaceAll && count > 1 {
+		return "", errorf(codeAmbiguousMatch, "old_string 出现 %d 次，需更多上下文或使用 replace_all", count)
+	}
+	if replaceAll {
+		return strings.ReplaceAll(content, oldString, newString), nil
+	}
+	return strings.Replace(content, oldString, newString, 1), nil
+}
+
+// replacePattern 把 re 的匹配替换为 replacement，replaceAll 为 false 时要求恰好匹配一处。
+// literal 为 false 时展开 replacement 中的 $1、${name}。
+func replacePattern(content string, re *regexp.Regexp, replacement string, replaceAll, literal bool) (string, error) {
+	matches := re.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return "", errorf(codeNotFound, "old_string 未找到")
+	}
+	if !replaceAll && len(matches) > 1 {
+		return "", errorf(codeAmbiguousMatch, "old_string 匹配到 %d 处，需更多上下文或使用 replace_all", len(matches))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(content[last:m[0]])
+		if literal {
+			b.WriteString(replacement)
+		} else {
+			b.Write(re.ExpandString(nil, replacement, content, m))
+		}
+		last = m[1]
+	}
+	b.WriteString(content[last:])
+	return b.String(), nil
+}
+
+// looseWhitespace 把 pattern 中间的每段空白替换为 \s+，使匹配不受空白多少和种类的影响。
+// 开头的空白匹配任意缩进（不跨行），结尾的空白匹配行尾空白（含换行时匹配一个换行），
+// 这样替换时文件原有的缩进会被 new_string 的缩进取代。
+// literal 为 true 时其余字符按字面量转义；否则 pattern 是正则，字符类和转义中的空白保持不变。
+func looseWhitespace(pattern string, literal bool) string {
+	var b strings.Builder
+	inClass, escaped := false, false
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		if unicode.IsSpace(r) && !inClass && !escaped {
+			start := i
+			for i < len(pattern) {
+				if r, size = utf8.DecodeRuneInString(pattern[i:]); !unicode.IsSpace(r) {
+					break
+				}
+				i += size
+			}
+			switch {
+			case start == 0:
+				b.WriteString(`[ \t]*`)
+			case i == len(pattern) && strings.Contains(pattern[start:], "\n"):
+				b.WriteString(`[ \t]*\r?\n`)
+			case i == len(pattern):
+				b.WriteString(`[ \t]*`)
+			default:
+				b.WriteString(`\s+`)
+			}
+			continue
+		}
+		i += size
+		if literal {
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			continue
+		}
+		b.WriteRune(r)
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '[':
+			inClass = true
+		case r == ']':
+			inClass = false
+		}
+	}
+	return b.String()
+}
+
+// replaceLines 用 text 替换第 start..end 行（1-based，含两端），end 为 0 表示只替换 start 一行
+func replaceLines(content string, start, end int, text string) (string, error) {
+	lines := splitLines(content)
+	if end == 0 {
+		end = start
+	}
+	if start < 1 || end < start || end > len(lines) {
+		return "", errorf(codeInvalidArgument, "行范围 %d-%d 无效（文件共 %d 行）", start, end, len(lines))
+	}
+	return spliceLines(lines, start-1, end, text), nil
+}
+
+// insertLines 在第 after 行之后插入 text（after 为 0 表示文件开头）
+func insertLines(content string, after int, text string) (string, error) {
+	lines := splitLines(content)
+	if after < 0 || after > len(lines) {
+		return "", errorf(codeInvalidArgument, "插入位置超出范围（文件共 %d 行）", len(lines))
+	}
+	if text == "" {
+		return "", errorf(codeInvalidArgument, "new_string 不能为空")
+	}
+	return spliceLines(lines, after, after, text), nil
+}
+
+// spliceLines 用 text 替换 lines[from:to]。text 中的换行统一为文件的换行符，
+// 不以换行结尾时补上（替换到文件末尾且原末行没有换行时除外）。
+func spliceLines(lines []string, from, to int, text string) string {
+	b := lineBuilder{eol: lineEnding(lines)}
+	for _, l := range lines[:from] {
+		b.add(l)
+	}
+	if text != "" {
+		text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", b.eol)
+		lastNoEOL := to == len(lines) && to > 0 && !strings.HasSuffix(lines[to-1], "\n")
+		if !strings.HasSuffix(text, "\n") && !lastNoEOL {
+			text += b.eol
+		}
+		b.add(text)
+	}
+	for _, l := range lines[to:] {
+		b.add(l)
+	}
+	return b.String()
+}
+
+const (
+	snippetContext  = 3  // snippet 在改动前后各带的行数
+	maxSnippetLines = 50 // snippet 最多的行数
+)
+
+// editSnippet 返回新内容中改动的部分及其前后几行，以及第一行的行号（1-based）；没有改动时返回空
+func editSnippet(oldContent, newContent string) (string, int) {
+	oldLines, newLines := splitLines(oldContent), splitLines(newContent)
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	if prefix == len(oldLines) && prefix == len(newLines) {
+		return "", 0
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	start := max(prefix-snippetContext, 0)
+	end := min(len(newLines)-suffix+snippetContext, len(newLines), start+maxSnippetLines)
+	if start >= end {
+		return "", 0
+	}
+	return strings.Join(newLines[start:end], ""), start + 1
+}
@@ -0,0 +1,249 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+func TestApplyEdit(t *testing.T) {
+	const src = "func main() {\n\tif ok {\n\t\tfoo(1)\n\t}\n\tbar(2)\n}\n"
+
+	tests := []struct {
+		name    string
+		content string
+		req     *v1.EditFileRequest
+		want    string
+		wantErr v1.ErrorCode
+	}{
+		{
+			name: "精确替换",
+			req:  &v1.EditFileRequest{OldString: "foo(1)", NewString: "foo(10)"},
+			want: "func main() {\n\tif ok {\n\t\tfoo(10)\n\t}\n\tbar(2)\n}\n",
+		},
+		{
+			name:    "精确替换不唯一",
+			req:     &v1.EditFileRequest{OldString: "\t", NewString: " "},
+			wantErr: codeAmbiguousMatch,
+		},
+		{
+			name: "忽略空白：缩进和换行不同",
+			req: &v1.EditFileRequest{
+				OldString:        "    if ok {\n        foo(1)\n    }\n",
+				NewString:        "\tif !ok {\n\t\treturn\n\t}\n",
+				IgnoreWhitespace: true,
+			},
+			want: "func main() {\n\tif !ok {\n\t\treturn\n\t}\n\tbar(2)\n}\n",
+		},
+		{
+			name: "忽略空白：中间空白多少不同",
+			req:  &v1.EditFileRequest{OldString: "if   ok  {", NewString: "if yes {", IgnoreWhitespace: true},
+			want: "func main() {\n\tif yes {\n\t\tfoo(1)\n\t}\n\tbar(2)\n}\n",
+		},
+		{
+			name:    "精确替换未找到",
+			req:     &v1.EditFileRequest{OldString: "baz(3)", NewString: "baz(4)"},
+			wantErr: codeNotFound,
+		},
+		{
+			name:    "忽略空白仍要求唯一",
+			req:     &v1.EditFileRequest{OldString: "(", NewString: "[", IgnoreWhitespace: true},
+			wantErr: codeAmbiguousMatch,
+		},
+		{
+			name: "正则捕获组",
+			req:  &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_REGEX, OldString: `(\w+)\((\d)\)`, NewString: "${1}_v2($2)", ReplaceAll: true},
+			want: "func main() {\n\tif ok {\n\t\tfoo_v2(1)\n\t}\n\tbar_v2(2)\n}\n",
+		},
+		{
+			name: "正则命名捕获组",
+			req:  &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_REGEX, OldString: `bar\((?P<n>\d)\)`, NewString: "baz(${n}${n})"},
+			want: "func main() {\n\tif ok {\n\t\tfoo(1)\n\t}\n\tbaz(22)\n}\n",
+		},
+		{
+			name: "正则忽略空白",
+			req:  &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_REGEX, OldString: `\} bar\((\d)\)`, NewString: "}\n\tqux($1)", IgnoreWhitespace: true},
+			want: "func main() {\n\tif ok {\n\t\tfoo(1)\n\t}\n\tqux(2)\n}\n",
+		},
+		{
+			name:    "正则多处匹配需 replace_all",
+			req:     &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_REGEX, OldString: `\d`, NewString: "0"},
+			wantErr: codeAmbiguousMatch,
+		},
+		{
+			name:    "正则无效",
+			req:     &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_REGEX, OldString: `(`, NewString: ""},
+			wantErr: codeInvalidArgument,
+		},
+		{
+			name: "替换行范围",
+			req:  &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_LINES, StartLine: 2, EndLine: 4, NewString: "\tfoo(1)"},
+			want: "func main() {\n\tfoo(1)\n\tbar(2)\n}\n",
+		},
+		{
+			name: "删除单行",
+			req:  &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_LINES, StartLine: 5},
+			want: "func main() {\n\tif ok {\n\t\tfoo(1)\n\t}\n}\n",
+		},
+		{
+			name:    "行范围越界",
+			req:     &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_LINES, StartLine: 6, EndLine: 7, NewString: "x"},
+			wantErr: codeInvalidArgument,
+		},
+		{
+			name: "在行前插入",
+			req:  &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_INSERT_BEFORE, StartLine: 1, NewString: "// doc"},
+			want: "// doc\n" + src,
+		},
+		{
+			name: "在行后插入多行",
+			req:  &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_INSERT_AFTER, StartLine: 5, NewString: "\tbaz(3)\n\tqux(4)\n"},
+			want: "func main() {\n\tif ok {\n\t\tfoo(1)\n\t}\n\tbar(2)\n\tbaz(3)\n\tqux(4)\n}\n",
+		},
+		{
+			name:    "在末行之后插入，末行没有换行",
+			content: "a\nb",
+			req:     &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_INSERT_AFTER, StartLine: 2, NewString: "c"},
+			want:    "a\nb\nc",
+		},
+		{
+			name:    "插入时沿用 CRLF",
+			content: "a\r\nb\r\n",
+			req:     &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_INSERT_AFTER, StartLine: 1, NewString: "x\ny"},
+			want:    "a\r\nx\r\ny\r\nb\r\n",
+		},
+		{
+			name:    "插入位置越界",
+			req:     &v1.EditFileRequest{Mode: v1.EditMode_EDIT_MODE_INSERT_BEFORE, StartLine: 0, NewString: "x"},
+			wantErr: codeInvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.content
+			if content == "" {
+				content = src
+			}
+			got, err := applyEdit(content, tt.req)
+			if tt.wantErr != codeNone {
+				if code := errorCode(err); code != tt.wantErr {
+					t.Fatalf("错误码 = %v (%v)，期望 %v", code, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("结果 = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	tests := []struct {
+		name      string
+		newText   string
+		want      string
+		wantStart int
+	}{
+		{"中间改一行", "1\n2\n3\n4\nX\n6\n7\n8\n9\n10\n", "2\n3\n4\nX\n6\n7\n8\n", 2},
+		{"开头插入", "0\n" + old, "0\n1\n2\n3\n", 1},
+		{"删除末行", "1\n2\n3\n4\n5\n6\n7\n8\n9\n", "7\n8\n9\n", 7},
+		{"没有改动", old, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, start := editSnippet(old, tt.newText)
+			if got != tt.want || start != tt.wantStart {
+				t.Fatalf("editSnippet = %q@%d，期望 %q@%d", got, start, tt.want, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestMultiEdit(t *testing.T) {
+	dir := t.TempDir()
+	_, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	path := filepath.Join(dir, "f.go")
+	const src = "a := 1\nb := 2\nc := a + b\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var seq int
+	multiEdit := func(expectedHash string, edits ...*v1.EditOperation) *v1.OpResult {
+		t.Helper()
+		seq++
+		msgs := hub.do(t, &v1.ConnectResponse{RequestId: fmt.Sprint("m", seq), Payload: &v1.ConnectResponse_MultiEdit{MultiEdit: &v1.MultiEditRequest{
+			Path: path, Edits: edits, ExpectedHash: expectedHash,
+		}}}, hasOpResult)
+		return msgs[0].GetOpResult()
+	}
+	content := func() string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+
+	tests := []struct {
+		name       string
+		edits      []*v1.EditOperation
+		wantCode   v1.ErrorCode
+		wantFailed int32
+	}{
+		{name: "edits 为空", wantCode: codeInvalidArgument},
+		{
+			name: "中间一处未找到",
+			edits: []*v1.EditOperation{
+				{OldString: "a := 1", NewString: "a := 10"},
+				{OldString: "d := 4", NewString: "d := 40"},
+				{OldString: "b := 2", NewString: "b := 20"},
+			},
+			wantCode: codeNotFound, wantFailed: 2,
+		},
+		{
+			name: "后一处因前一处的修改而不唯一",
+			edits: []*v1.EditOperation{
+				{OldString: "c := a + b", NewString: "c := a + a"},
+				{OldString: "a", NewString: "x"},
+			},
+			wantCode: codeAmbiguousMatch, wantFailed: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := multiEdit("", tt.edits...)
+			if r.Success || r.Code != tt.wantCode || r.FailedEdit != tt.wantFailed {
+				t.Fatalf("success=%v code=%v failed_edit=%d (%s)", r.Success, r.Code, r.FailedEdit, r.Error)
+			}
+			// 任何一处失败都不写回
+			if content() != src {
+				t.Fatalf("失败后文件被修改: %q", content())
+			}
+		})
+	}
+
+	if r := multiEdit(hashBytes([]byte("other"))); r.Code != codeConflict || content() != src {
+		t.Fatalf("expected_hash 不符: code=%v", r.Code)
+	}
+
+	// 依次应用，后一处看到前一处的结果
+	r := multiEdit(hashBytes([]byte(src)),
+		&v1.EditOperation{OldString: "a := 1", NewString: "a := 10"},
+		&v1.EditOperation{OldString: "a := 10\nb", NewString: "a := 10\nbb"},
+		&v1.EditOperation{OldString: "b", NewString: "y", ReplaceAll: true},
+	)
+	if !r.Success {
+		t.Fatalf("编辑失败: %s", r.Error)
+	}
+	if want := "a := 10\nyy := 2\nc := a + y\n"; content() != want {
+		t.Fatalf("内容 = %q，期望 %q", content(), want)
+	}
+	if r.Snippet == "" || r.Change == nil {
+		t.Fatalf("成功时应返回片段和 diff: %+v", r)
+	}
+}
@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// essentialEnv 是干净环境（INHERIT_ENV_NONE）下仍保留的基础变量
+var essentialEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TMPDIR", "TZ", "TERM",
+	"LANG", "LC_ALL", "LC_CTYPE",
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// envPassesFilter 判断 daemon 的环境变量能否传给子进程。
+// allow 非空时只放行匹配的变量；deny 始终优先。两者都支持 path.Match 通配（如 AWS_*）。
+func envPassesFilter(name string, allow, deny []string) bool {
+	if matchEnvPattern(name, deny) {
+		return false
+	}
+	return len(allow) == 0 || matchEnvPattern(name, allow)
+}
+
+func matchEnvPattern(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateEnv 检查请求中的变量名，防止注入到 shell 命令中
+func validateEnv(env map[string]string) error {
+	for name := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("非法环境变量名: %q", name)
+		}
+	}
+	return nil
+}
+
+// inheritedEnv 返回按策略和白/黑名单过滤后的 daemon 环境
+func (d *Daemon) inheritedEnv(policy v1.InheritEnv) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if policy == v1.InheritEnv_INHERIT_ENV_NONE && !slices.Contains(essentialEnv, name) {
+			continue
+		}
+		if envPassesFilter(name, d.config.EnvAllow, d.config.EnvDeny) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// buildEnv 组装子进程环境：过滤后的 daemon 环境，再叠加请求中的变量
+func (d *Daemon) buildEnv(policy v1.InheritEnv, extra map[string]string) []string {
+	env := d.inheritedEnv(policy)
+	if len(extra) == 0 {
+		return env
+	}
+	env = slices.DeleteFunc(env, func(kv string) bool {
+		name, _, _ := strings.Cut(kv, "=")
+		_, overridden := extra[name]
+		return overridden
+	})
+	for _, name := range sortedKeys(extra) {
+		env = append(env, name+"="+extra[name])
+	}
+	return env
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
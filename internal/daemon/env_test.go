@@ -0,0 +1,134 @@
+package daemon
+
+import (
+	"slices"
+	"testing"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+func TestEnvPassesFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         string
+		allow, deny []string
+		want        bool
+	}{
+		{name: "无过滤", env: "FOO", want: true},
+		{name: "只有白名单且匹配", env: "AWS_REGION", allow: []string{"AWS_*"}, want: true},
+		{name: "只有白名单不匹配", env: "GITHUB_TOKEN", allow: []string{"AWS_*"}, want: false},
+		{name: "黑名单", env: "GITHUB_TOKEN", deny: []string{"*_TOKEN"}, want: false},
+		{name: "黑名单不匹配", env: "PATH", deny: []string{"*_TOKEN"}, want: true},
+		{name: "黑名单优先于白名单", env: "AWS_SECRET_ACCESS_KEY", allow: []string{"AWS_*"}, deny: []string{"*SECRET*"}, want: false},
+		{name: "通配不跨越整个名字", env: "MY_AWS_KEY", allow: []string{"AWS_*"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := envPassesFilter(tt.env, tt.allow, tt.deny); got != tt.want {
+				t.Fatalf("envPassesFilter(%q) = %v，期望 %v", tt.env, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		wantErr bool
+	}{
+		{name: "普通变量", env: "FOO_1"},
+		{name: "下划线开头", env: "_X"},
+		{name: "数字开头", env: "1FOO", wantErr: true},
+		{name: "空名字", env: "", wantErr: true},
+		{name: "含等号", env: "A=B", wantErr: true},
+		{name: "含空格", env: "A B", wantErr: true},
+		{name: "shell 注入", env: "A;rm -rf /", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateEnv(map[string]string{tt.env: "v"}); (err != nil) != tt.wantErr {
+				t.Fatalf("validateEnv(%q) err = %v", tt.env, err)
+			}
+		})
+	}
+}
+
+func TestBuildEnv(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin:/bin")
+	t.Setenv("HOME", "/home/test")
+	t.Setenv("EPIRAL_TEST_KEEP", "keep")
+	t.Setenv("EPIRAL_TEST_TOKEN", "secret")
+
+	tests := []struct {
+		name        string
+		policy      v1.InheritEnv
+		allow, deny []string
+		extra       map[string]string
+		want        []string
+		notWant     []string
+	}{
+		{
+			name:   "默认继承全部",
+			policy: v1.InheritEnv_INHERIT_ENV_FILTERED,
+			want:   []string{"PATH=/usr/bin:/bin", "HOME=/home/test", "EPIRAL_TEST_KEEP=keep", "EPIRAL_TEST_TOKEN=secret"},
+		},
+		{
+			name:    "黑名单过滤",
+			policy:  v1.InheritEnv_INHERIT_ENV_FILTERED,
+			deny:    []string{"*_TOKEN"},
+			want:    []string{"PATH=/usr/bin:/bin", "EPIRAL_TEST_KEEP=keep"},
+			notWant: []string{"EPIRAL_TEST_TOKEN=secret"},
+		},
+		{
+			name:    "白名单只放行匹配的变量",
+			policy:  v1.InheritEnv_INHERIT_ENV_FILTERED,
+			allow:   []string{"EPIRAL_TEST_*"},
+			want:    []string{"EPIRAL_TEST_KEEP=keep"},
+			notWant: []string{"PATH=/usr/bin:/bin"},
+		},
+		{
+			name:    "干净环境保留基础变量",
+			policy:  v1.InheritEnv_INHERIT_ENV_NONE,
+			want:    []string{"PATH=/usr/bin:/bin", "HOME=/home/test"},
+			notWant: []string{"EPIRAL_TEST_KEEP=keep"},
+		},
+		{
+			name:    "干净环境仍受黑名单约束",
+			policy:  v1.InheritEnv_INHERIT_ENV_NONE,
+			deny:    []string{"HOME"},
+			want:    []string{"PATH=/usr/bin:/bin"},
+			notWant: []string{"HOME=/home/test"},
+		},
+		{
+			name:    "请求变量覆盖继承的同名变量",
+			policy:  v1.InheritEnv_INHERIT_ENV_FILTERED,
+			extra:   map[string]string{"EPIRAL_TEST_KEEP": "override", "NEW": "1"},
+			want:    []string{"EPIRAL_TEST_KEEP=override", "NEW=1"},
+			notWant: []string{"EPIRAL_TEST_KEEP=keep"},
+		},
+		{
+			name:   "请求变量不受黑名单约束",
+			policy: v1.InheritEnv_INHERIT_ENV_NONE,
+			deny:   []string{"*_TOKEN"},
+			extra:  map[string]string{"EPIRAL_TEST_TOKEN": "explicit"},
+			want:   []string{"EPIRAL_TEST_TOKEN=explicit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(&Config{EnvAllow: tt.allow, EnvDeny: tt.deny})
+			env := d.buildEnv(tt.policy, tt.extra)
+			for _, kv := range tt.want {
+				if !slices.Contains(env, kv) {
+					t.Errorf("缺少 %s", kv)
+				}
+			}
+			for _, kv := range tt.notWant {
+				if slices.Contains(env, kv) {
+					t.Errorf("不应包含 %s", kv)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// 错误码简写。error 文本是给人看的说明，Agent 按错误码区分处理。
+const (
+	codeNone             = v1.ErrorCode_ERROR_CODE_UNSPECIFIED
+	codeConflict         = v1.ErrorCode_ERROR_CODE_CONFLICT
+	codeNotFound         = v1.ErrorCode_ERROR_CODE_NOT_FOUND
+	codePermissionDenied = v1.ErrorCode_ERROR_CODE_PERMISSION_DENIED
+	codeInvalidArgument  = v1.ErrorCode_ERROR_CODE_INVALID_ARGUMENT
+	codeTooLarge         = v1.ErrorCode_ERROR_CODE_TOO_LARGE
+	codeAlreadyExists    = v1.ErrorCode_ERROR_CODE_ALREADY_EXISTS
+	codeAmbiguousMatch   = v1.ErrorCode_ERROR_CODE_AMBIGUOUS_MATCH
+	codeTimeout          = v1.ErrorCode_ERROR_CODE_TIMEOUT
+	codeCancelled        = v1.ErrorCode_ERROR_CODE_CANCELLED
+	codeUnavailable      = v1.ErrorCode_ERROR_CODE_UNAVAILABLE
+	codeUnsupported      = v1.ErrorCode_ERROR_CODE_UNSUPPORTED
+	codeInternal         = v1.ErrorCode_ERROR_CODE_INTERNAL
+)
+
+// codedError 是带错误码的错误
+type codedError struct {
+	code v1.ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// errorf 同 fmt.Errorf，并附上错误码
+func errorf(code v1.ErrorCode, format string, args ...any) error {
+	return &codedError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// errorCode 返回 err 的错误码：带错误码的错误取其错误码，常见的系统错误按类别归类，其余为 INTERNAL
+func errorCode(err error) v1.ErrorCode {
+	var coded *codedError
+	switch {
+	case err == nil:
+		return codeNone
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.Is(err, errPathNotAllowed), errors.Is(err, errPathPermission), errors.Is(err, fs.ErrPermission):
+		return codePermissionDenied
+	case errors.Is(err, fs.ErrNotExist):
+		return codeNotFound
+	case errors.Is(err, fs.ErrExist):
+		return codeAlreadyExists
+	case errors.Is(err, context.DeadlineExceeded):
+		return codeTimeout
+	case errors.Is(err, errCancelled), errors.Is(err, errDisconnected), errors.Is(err, context.Canceled):
+		return codeCancelled
+	default:
+		return codeInternal
+	}
+}
@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+func TestErrorCode(t *testing.T) {
+	_, notExist := os.Stat(filepath.Join(t.TempDir(), "missing"))
+	tests := []struct {
+		err  error
+		want v1.ErrorCode
+	}{
+		{nil, codeNone},
+		{errorf(codeAmbiguousMatch, "x"), codeAmbiguousMatch},
+		{fmt.Errorf("包装: %w", errorf(codeTooLarge, "x")), codeTooLarge},
+		{notExist, codeNotFound},
+		{fs.ErrExist, codeAlreadyExists},
+		{fmt.Errorf("%w（只读）", errPathPermission), codePermissionDenied},
+		{errPathNotAllowed, codePermissionDenied},
+		{context.DeadlineExceeded, codeTimeout},
+		{errCancelled, codeCancelled},
+		{errDisconnected, codeCancelled},
+		{errBrowserOffline, codeUnavailable},
+		{errors.New("其他"), codeInternal},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.want {
+			t.Errorf("errorCode(%v) = %v，期望 %v", tt.err, got, tt.want)
+		}
+	}
+
+	// 路径解析失败时，未归类的错误是参数问题
+	if got := pathErrorCode(errors.New("路径为空")); got != codeInvalidArgument {
+		t.Errorf("pathErrorCode = %v，期望 INVALID_ARGUMENT", got)
+	}
+	if got := pathErrorCode(errPathNotAllowed); got != codePermissionDenied {
+		t.Errorf("pathErrorCode = %v，期望 PERMISSION_DENIED", got)
+	}
+}
@@ -1,19 +1,118 @@
 package daemon
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"os/exec"
+	"sync"
+	"syscall"
 	"time"
 
 	v1 "github.com/epiral/cli/gen/epiral/v1"
 )
 
-const defaultTimeoutMs = 30000
+const (
+	defaultTimeoutMs = 30000
+	cancelGrace      = 2 * time.Second // 取消时每级信号之间的等待时间
+	pipeWaitDelay    = 5 * time.Second // 进程结束后等待输出管道关闭的上限
+
+	exitCodeTimeout   = 124 // 超时（与 coreutils timeout 一致）
+	exitCodeCancelled = 130 // 被 CancelRequest 取消或连接断开（128 + SIGINT）
+)
+
+// runningExec 是一条运行中的命令，供取消和 ExecInput / ExecResize 使用
+type runningExec struct {
+	mu        sync.Mutex
+	cancelled bool
+	stop      func()       // 实际的终止动作，命令启动后设置
+	term      *ptyTerminal // pty 模式的终端（登记时创建），其余模式为 nil
+	out       *execOutput  // 上行输出（登记时创建），接收循环也经它报告输入被丢弃
+}
+
+// cancel 标记取消并触发终止动作，重复调用无副作用
+func (r *runningExec) cancel() {
+	r.mu.Lock()
+	if r.cancelled {
+		r.mu.Unlock()
+		return
+	}
+	r.cancelled = true
+	stop := r.stop
+	r.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+// setStop 设置终止动作；若在此之前已被取消则立即执行
+func (r *runningExec) setStop(stop func()) {
+	r.mu.Lock()
+	r.stop = stop
+	cancelled := r.cancelled
+	r.mu.Unlock()
+	if cancelled {
+		stop()
+	}
+}
+
+func (r *runningExec) isCancelled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancelled
+}
+
+func (r *runningExec) terminal() *ptyTerminal {
+	return r.term
+}
+
+// trackExec 登记命令，pty 模式同时创建终端。接收循环收到 Exec 时即调用，
+// 之后到达的 ExecInput / ExecResize 不会因命令尚未启动而丢失。
+func (d *Daemon) trackExec(requestID string, isPTY bool) *runningExec {
+	run := &runningExec{out: d.newExecOutput(requestID)}
+	if isPTY {
+		run.term = newPTYTerminal()
+	}
+	d.execMu.Lock()
+	if d.execs == nil {
+		d.execs = make(map[string]*runningExec)
+	}
+	d.execs[requestID] = run
+	d.execMu.Unlock()
+	return run
+}
+
+// untrackExec 移除登记。同一 request_id 可能已被新请求覆盖，只移除 run 自己的登记
+func (d *Daemon) untrackExec(requestID string, run *runningExec) {
+	d.execMu.Lock()
+	if d.execs[requestID] == run {
+		delete(d.execs, requestID)
+	}
+	d.execMu.Unlock()
+}
+
+// lookupExec 查找运行中的命令
+func (d *Daemon) lookupExec(requestID string) (*runningExec, bool) {
+	d.execMu.Lock()
+	defer d.execMu.Unlock()
+	run, ok := d.execs[requestID]
+	return run, ok
+}
+
+// terminateProcess 向 p 的进程组逐级发送 SIGINT → SIGTERM → SIGKILL，进程退出（done 关闭）即停止
+func terminateProcess(p *os.Process, done <-chan struct{}) {
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM} {
+		_ = signalProcessGroup(p, sig)
+		select {
+		case <-done:
+			return
+		case <-time.After(cancelGrace):
+		}
+	}
+	_ = signalProcessGroup(p, syscall.SIGKILL)
+}
 
 // handleExec 执行命令，流式返回输出
 func (d *Daemon) handleExec(ctx context.Context, requestID string, req *v1.ExecRequest) {
@@ -30,7 +129,39 @@ func (d *Daemon) handleExec(ctx context.Context, requestID string, req *v1.ExecR
 		timeoutMs = defaultTimeoutMs
 	}
 
-	execCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+
+	run, ok := d.lookupExec(requestID)
+	if !ok {
+		run = d.trackExec(requestID, req.Pty)
+	}
+	defer d.untrackExec(requestID, run)
+	out := run.out
+
+	// 请求被取消或连接断开时逐级终止命令；命令本身不绑定 ctx，否则会被直接 SIGKILL
+	stop := context.AfterFunc(ctx, run.cancel)
+	defer stop()
+	ctx = context.WithoutCancel(ctx)
+
+	if err := validateEnv(req.Env); err != nil {
+		out.done(codeInvalidArgument, err.Error(), 1, req.Workdir)
+		logExecResult(1, execStart)
+		return
+	}
+
+	// 持久会话：交给 shell pool
+	if req.SessionId != "" {
+		if req.Pty {
+			out.done(codeUnsupported, "pty 模式不支持 session_id", 1, req.Workdir)
+			logExecResult(1, execStart)
+			return
+		}
+		exitCode := d.handleSessionExec(ctx, req, timeout, run, out)
+		logExecResult(exitCode, execStart)
+		return
+	}
+
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// 工作目录
@@ -38,93 +169,81 @@ func (d *Daemon) handleExec(ctx context.Context, requestID string, req *v1.ExecR
 	if workdir == "" {
 		workdir, _ = os.UserHomeDir()
 	}
-	if !d.isPathAllowed(workdir) {
-		log.Printf("[执行] 拒绝: 路径不允许 %s", workdir)
-		d.sendExecDone(requestID, "", fmt.Sprintf("路径不允许: %s", workdir), 1, workdir)
+	workdir, err := d.resolvePath(workdir, accessExec)
+	if err != nil {
+		log.Printf("[执行] 拒绝: %s", pathErrorMessage(req.Workdir, err))
+		out.done(pathErrorCode(err), pathErrorMessage(req.Workdir, err), 1, req.Workdir)
+		logExecResult(1, execStart)
 		return
 	}
 
-	// 创建命令
+	// 交互式：在伪终端中运行
+	if req.Pty {
+		exitCode := d.runPTY(execCtx, req, workdir, run, out)
+		logExecResult(exitCode, execStart)
+		return
+	}
+
+	// 创建命令：独立进程组，超时/取消时整组终止；
+	// WaitDelay 保证孙进程继承的管道不会让 Wait 永远阻塞
 	cmd := exec.CommandContext(execCtx, d.shell(), "-c", req.Command)
 	cmd.Dir = workdir
-	cmd.Env = os.Environ()
+	cmd.Env = d.buildEnv(req.InheritEnv, req.Env)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return signalProcessGroup(cmd.Process, syscall.SIGKILL) }
+	cmd.WaitDelay = pipeWaitDelay
 
-	// stdout 管道（流式）
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		d.sendExecDone(requestID, "", fmt.Sprintf("stdout 管道失败: %v", err), 1, workdir)
-		return
-	}
-	// stderr 管道
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		d.sendExecDone(requestID, "", fmt.Sprintf("stderr 管道失败: %v", err), 1, workdir)
-		return
-	}
+	// stdout/stderr 按到达顺序分块流式上行
+	chunker := out.newChunker()
+	cmd.Stdout = chunker.writer(streamStdout)
+	cmd.Stderr = chunker.writer(streamStderr)
 
 	if err := cmd.Start(); err != nil {
-		d.sendExecDone(requestID, "", fmt.Sprintf("启动失败: %v", err), 1, workdir)
+		_ = chunker.Close()
+		out.done(errorCode(err), fmt.Sprintf("启动失败: %v", err), 1, workdir)
+		logExecResult(1, execStart)
 		return
 	}
+	exited := make(chan struct{})
+	run.setStop(func() { go terminateProcess(cmd.Process, exited) })
 
-	// 流式发送 stdout
-	go func() {
-		scanner := bufio.NewScanner(stdoutPipe)
-		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
-		for scanner.Scan() {
-			if err := d.send(&v1.ConnectRequest{
-				RequestId: requestID,
-				Payload: &v1.ConnectRequest_ExecOutput{
-					ExecOutput: &v1.ExecOutput{
-						Stdout: scanner.Text() + "\n",
-					},
-				},
-			}); err != nil {
-				log.Printf("[执行] 发送 stdout 失败: %v", err)
-				return
-			}
-		}
-	}()
-
-	// 收集 stderr
-	stderrBytes, _ := io.ReadAll(io.LimitReader(stderrPipe, 100*1024))
-
-	// 等待完成
-	var exitCode int32
-	if err := cmd.Wait(); err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = int32(exitErr.ExitCode()) //nolint:gosec // exit code 不会溢出 int32
-		} else if execCtx.Err() == context.DeadlineExceeded {
-			exitCode = 124
-		} else {
-			exitCode = 1
-		}
+	// 等待完成，输出全部发出后才发送结束消息
+	err = cmd.Wait()
+	close(exited)
+	_ = chunker.Close()
+
+	exitCode, code, note := execResult(execCtx, cmd, err, run)
+	logExecResult(exitCode, execStart)
+
+	out.done(code, note, exitCode, workdir)
+}
+
+// execResult 根据 Wait 的结果、超时与取消状态得出退出码、错误码和附加说明
+func execResult(execCtx context.Context, cmd *exec.Cmd, err error, run *runningExec) (exitCode int32, code v1.ErrorCode, note string) {
+	var exitErr *exec.ExitError
+	switch {
+	case run.isCancelled():
+		return exitCodeCancelled, codeCancelled, "命令已取消\n"
+	case execCtx.Err() == context.DeadlineExceeded:
+		// 整组已被 SIGKILL，顺带清理可能残留的孙进程
+		_ = signalProcessGroup(cmd.Process, syscall.SIGKILL)
+		return exitCodeTimeout, codeTimeout, ""
+	case err == nil, errors.Is(err, exec.ErrWaitDelay):
+		// ErrWaitDelay: 进程正常退出，但有后台孙进程仍占着输出管道
+		return int32(cmd.ProcessState.ExitCode()), codeNone, "" //nolint:gosec // exit code 不会溢出 int32
+	case errors.As(err, &exitErr):
+		return int32(exitErr.ExitCode()), codeNone, "" //nolint:gosec // exit code 不会溢出 int32
+	default:
+		return 1, codeInternal, ""
 	}
+}
 
-	elapsed := time.Since(execStart)
+// logExecResult 记录命令结束日志
+func logExecResult(exitCode int32, start time.Time) {
+	elapsed := time.Since(start)
 	if exitCode == 0 {
 		log.Printf("[执行] 完成 (%.1fs)", elapsed.Seconds())
 	} else {
 		log.Printf("[执行] 失败 exit=%d (%.1fs)", exitCode, elapsed.Seconds())
 	}
-
-	d.sendExecDone(requestID, "", string(stderrBytes), exitCode, workdir)
-}
-
-// sendExecDone 发送执行完成消息
-func (d *Daemon) sendExecDone(requestID, stdout, stderr string, exitCode int32, workdir string) {
-	if err := d.send(&v1.ConnectRequest{
-		RequestId: requestID,
-		Payload: &v1.ConnectRequest_ExecOutput{
-			ExecOutput: &v1.ExecOutput{
-				Stdout:   stdout,
-				Stderr:   stderr,
-				ExitCode: exitCode,
-				Done:     true,
-				Workdir:  workdir,
-			},
-		},
-	}); err != nil {
-		log.Printf("[执行] 发送结果失败: %v", err)
-	}
 }
@@ -0,0 +1,179 @@
+//go:build unix
+
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// processAlive 判断 pid 是否仍在运行，已退出未回收的僵尸进程视为不在运行
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	// 格式为 "pid (comm) state ..."，comm 可能含空格，从最后一个 ')' 之后取状态
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// waitProcessGone 等待 pid 退出，超时则测试失败
+func waitProcessGone(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("进程 %d 仍在运行", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// firstLinePID 从输出的第一行解析 pid
+func firstLinePID(t *testing.T, stdout string) int {
+	t.Helper()
+	line, _, _ := strings.Cut(stdout, "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		t.Fatalf("输出 %q 中没有 pid", stdout)
+	}
+	return pid
+}
+
+func TestTerminateProcess(t *testing.T) {
+	// 脚本设置好 trap 后才输出 ready
+	tests := []struct {
+		name       string
+		script     string
+		minElapsed time.Duration
+		wantExit   int
+		wantSignal syscall.Signal
+	}{
+		{name: "SIGINT 即退出", script: "echo ready; exec sleep 100", wantSignal: syscall.SIGINT},
+		{name: "忽略 SIGINT 时升级到 SIGTERM", script: `trap '' INT; trap 'exit 3' TERM; echo ready; while :; do sleep 0.1; done`,
+			minElapsed: cancelGrace, wantExit: 3},
+		{name: "都忽略时 SIGKILL", script: `trap '' INT TERM; echo ready; while :; do sleep 0.1; done`,
+			minElapsed: 2 * cancelGrace, wantSignal: syscall.SIGKILL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := exec.Command("/bin/sh", "-c", tt.script)
+			setProcessGroup(cmd)
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			// 等 trap 设置完成后再发信号
+			if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+				t.Fatal(err)
+			}
+
+			exited := make(chan struct{})
+			start := time.Now()
+			go terminateProcess(cmd.Process, exited)
+			_ = cmd.Wait()
+			close(exited)
+			elapsed := time.Since(start)
+
+			// 只检查下限：机器繁忙时可能更慢，升级到哪一级由退出状态判断
+			if elapsed < tt.minElapsed {
+				t.Fatalf("用时 %s，期望至少 %s", elapsed, tt.minElapsed)
+			}
+			status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+			if tt.wantSignal != 0 {
+				if !status.Signaled() || status.Signal() != tt.wantSignal {
+					t.Fatalf("status = %v，期望被 %v 终止", cmd.ProcessState, tt.wantSignal)
+				}
+			} else if status.ExitStatus() != tt.wantExit {
+				t.Fatalf("status = %v，期望 exit %d", cmd.ProcessState, tt.wantExit)
+			}
+		})
+	}
+}
+
+func TestExecKillsProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	_, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+
+	// 超时：整组 SIGKILL，后台孙进程一起结束
+	msgs := hub.do(t, &v1.ConnectResponse{RequestId: "timeout", Payload: &v1.ConnectResponse_Exec{Exec: &v1.ExecRequest{
+		Command: "sleep 100 & echo $!; wait", Workdir: dir, TimeoutMs: 500,
+	}}}, execFinished)
+	stdout, _, last := execResultOf(msgs)
+	if last.ExitCode != exitCodeTimeout || last.Code != codeTimeout {
+		t.Fatalf("超时: exit=%d code=%v", last.ExitCode, last.Code)
+	}
+	waitProcessGone(t, firstLinePID(t, stdout))
+
+	// 取消：后台孙进程忽略 SIGINT，升级到 SIGTERM 后随整组结束
+	hub.down <- &v1.ConnectResponse{RequestId: "cancel", Payload: &v1.ConnectResponse_Exec{Exec: &v1.ExecRequest{
+		Command: "sleep 100 & echo $!; wait", Workdir: dir,
+	}}}
+	started := hub.wait(t, "cancel", func(m *v1.ConnectRequest) bool { return m.GetExecOutput().GetStdout() != "" })
+	pid := firstLinePID(t, started[0].GetExecOutput().Stdout)
+	hub.down <- &v1.ConnectResponse{Payload: &v1.ConnectResponse_Cancel{Cancel: &v1.CancelRequest{RequestId: "cancel"}}}
+	_, _, last = execResultOf(hub.wait(t, "cancel", execFinished))
+	if last.ExitCode != exitCodeCancelled || last.Code != codeCancelled {
+		t.Fatalf("取消: exit=%d code=%v", last.ExitCode, last.Code)
+	}
+	waitProcessGone(t, pid)
+}
+
+// lockedBuffer 是可并发写入的日志缓冲区
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestExecStartFailure(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SHELL", "/nonexistent/sh")
+	var logs lockedBuffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	_, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+
+	msgs := hub.do(t, &v1.ConnectResponse{RequestId: "x", Payload: &v1.ConnectResponse_Exec{Exec: &v1.ExecRequest{
+		Command: "true", Workdir: dir,
+	}}}, execFinished)
+	_, _, last := execResultOf(msgs)
+	if last.ExitCode != 1 || !strings.Contains(last.Stderr, "启动失败") {
+		t.Fatalf("exit=%d stderr=%q", last.ExitCode, last.Stderr)
+	}
+	// 启动失败也记录结束日志
+	if !strings.Contains(logs.String(), "[执行] 失败 exit=1") {
+		t.Fatalf("日志中没有结束记录:\n%s", logs.String())
+	}
+}
@@ -1,12 +1,20 @@
 package daemon
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"unicode/utf8"
 
 	v1 "github.com/epiral/cli/gen/epiral/v1"
 )
@@ -17,21 +25,21 @@ const (
 )
 
 // handleReadFile 读取文件
-func (d *Daemon) handleReadFile(requestID string, req *v1.ReadFileRequest) {
-	path := req.Path
-	log.Printf("[文件] 读取 %s", path)
-	if !d.isPathAllowed(path) {
-		d.sendFileContent(requestID, "", 0, 0, fmt.Sprintf("路径不允许: %s", path))
+func (d *Daemon) handleReadFile(ctx context.Context, requestID string, req *v1.ReadFileRequest) {
+	log.Printf("[文件] 读取 %s", req.Path)
+	path, err := d.resolvePath(req.Path, accessRead)
+	if err != nil {
+		d.sendFileContent(requestID, fileError(0, pathErrorCode(err), pathErrorMessage(req.Path, err)))
 		return
 	}
 
 	info, err := os.Stat(path)
 	if err != nil {
-		d.sendFileContent(requestID, "", 0, 0, fmt.Sprintf("文件不存在: %s", path))
+		d.sendFileContent(requestID, fileError(0, errorCode(err), fmt.Sprintf("文件不存在: %s", path)))
 		return
 	}
 	if info.IsDir() {
-		d.sendFileContent(requestID, "", 0, 0, fmt.Sprintf("路径是目录: %s", path))
+		d.sendFileContent(requestID, fileError(0, codeInvalidArgument, fmt.Sprintf("路径是目录: %s", path)))
 		return
 	}
 
@@ -39,141 +47,611 @@ func (d *Daemon) handleReadFile(requestID string, req *v1.ReadFileRequest) {
 	if maxSize <= 0 {
 		maxSize = defaultMaxFileSize
 	}
-	if info.Size() > maxSize {
-		d.sendFileContent(requestID, "", 0, info.Size(),
-			fmt.Sprintf("文件过大: %d 字节（上限 %d）", info.Size(), maxSize))
-		return
-	}
 
 	file, err := os.Open(path)
 	if err != nil {
-		d.sendFileContent(requestID, "", 0, 0, fmt.Sprintf("打开失败: %v", err))
+		d.sendFileContent(requestID, fileError(0, errorCode(err), fmt.Sprintf("打开失败: %v", err)))
 		return
 	}
 	defer file.Close()
+	// 取消时关闭文件，打断管道等可能一直阻塞的读取
+	stop := context.AfterFunc(ctx, func() { _ = file.Close() })
+	defer stop()
 
-	offset := int(req.Offset)
-	limit := int(req.Limit)
+	var fc *v1.FileContent
+	if req.Mode == v1.ReadMode_READ_MODE_BYTES {
+		fc = readFileBytes(file, info.Size(), req.ByteOffset, req.ByteLength, maxSize)
+	} else {
+		fc = readFileLines(file, info.Size(), int(req.Offset), int(req.Limit), maxSize)
+	}
+	if ctx.Err() != nil {
+		log.Printf("[文件] 读取 %s 已中止", req.Path)
+		fc = fileError(info.Size(), errorCode(context.Cause(ctx)), fmt.Sprintf("读取失败: %v", context.Cause(ctx)))
+	}
+	if fc.Error == "" {
+		fc.MtimeMs = info.ModTime().UnixMilli()
+	}
+	d.sendFileContent(requestID, fc)
+}
+
+// readFileLines 按行读取文本文件。每行保留原始换行符（含 CRLF），
+// 最后一行没有换行符时也不补。非 UTF-8 字节替换为 U+FFFD 并标记 binary，sha256 仍是原始内容的哈希。
+func readFileLines(file *os.File, size int64, offset, limit int, maxSize int64) *v1.FileContent {
+	if size > maxSize {
+		return fileError(size, codeTooLarge, fmt.Sprintf("文件过大: %d 字节（上限 %d）", size, maxSize))
+	}
 	if limit <= 0 {
 		limit = defaultLineLimit
 	}
 
-	var lines []string
+	data, err := io.ReadAll(io.LimitReader(file, maxSize))
+	if err != nil {
+		return fileError(size, errorCode(err), fmt.Sprintf("读取失败: %v", err))
+	}
+	text, binary := data, !utf8.Valid(data)
+	if binary {
+		text = bytes.ToValidUTF8(data, []byte(string(utf8.RuneError)))
+	}
+
+	var content strings.Builder
 	totalLines := 0
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
-	for scanner.Scan() {
-		totalLines++
-		if totalLines-1 < offset {
-			continue
+	for rest := text; len(rest) > 0; totalLines++ {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
 		}
-		if len(lines) < limit {
-			lines = append(lines, scanner.Text())
+		rest = rest[len(line):]
+		if totalLines >= offset && totalLines-offset < limit {
+			content.Write(line)
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		d.sendFileContent(requestID, "", 0, info.Size(), fmt.Sprintf("读取失败: %v", err))
-		return
+	return &v1.FileContent{
+		Content:    content.String(),
+		TotalLines: int64(totalLines),
+		FileSize:   size,
+		Sha256:     hashBytes(data),
+		Binary:     binary,
 	}
+}
 
-	content := strings.Join(lines, "\n")
-	if len(lines) > 0 {
-		content += "\n"
+// readFileBytes 读取 [offset, offset+length) 的原始字节，length 为 0 时读到文件末尾。
+// 单次读取不超过 maxSize，更大的文件需分段读取。只有读取覆盖整个文件时才给出 sha256，
+// 分段读取不必为每一段重新读一遍整个文件。
+func readFileBytes(file *os.File, size, offset, length, maxSize int64) *v1.FileContent {
+	if offset < 0 || length < 0 {
+		return fileError(size, codeInvalidArgument, "byte_offset/byte_length 不能为负数")
+	}
+	if offset > size {
+		return fileError(size, codeInvalidArgument, fmt.Sprintf("偏移超出文件大小: %d > %d", offset, size))
+	}
+	if length == 0 || length > size-offset {
+		length = size - offset
+	}
+	if length > maxSize {
+		return fileError(size, codeTooLarge, fmt.Sprintf("读取范围过大: %d 字节（上限 %d），请用 byte_offset/byte_length 分段读取", length, maxSize))
+	}
+
+	data := make([]byte, length)
+	n, err := file.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return fileError(size, errorCode(err), fmt.Sprintf("读取失败: %v", err))
+	}
+	fc := &v1.FileContent{Data: data[:n], FileSize: size}
+	if offset == 0 && int64(n) == size {
+		fc.Sha256 = hashBytes(data[:n])
 	}
-	d.sendFileContent(requestID, content, int64(totalLines), info.Size(), "")
+	return fc
 }
 
 // handleWriteFile 写入文件
-func (d *Daemon) handleWriteFile(requestID string, req *v1.WriteFileRequest) {
-	log.Printf("[文件] 写入 %s (%d 字节)", req.Path, len(req.Content))
-	if !d.isPathAllowed(req.Path) {
-		d.sendOpResult(requestID, false, fmt.Sprintf("路径不允许: %s", req.Path))
+func (d *Daemon) handleWriteFile(ctx context.Context, requestID string, req *v1.WriteFileRequest) {
+	content := []byte(req.Content)
+	if req.Binary {
+		content = req.Data
+	}
+	log.Printf("[文件] 写入 %s (%d 字节)", req.Path, len(content))
+	path, err := d.resolvePath(req.Path, accessWrite)
+	if err != nil {
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Path, err))
 		return
 	}
-	if err := os.MkdirAll(filepath.Dir(req.Path), 0o755); err != nil {
-		d.sendOpResult(requestID, false, fmt.Sprintf("创建目录失败: %v", err))
+	// 从读取旧内容、校验 expected_hash 到写入完成都持有该路径的锁
+	defer d.fileLocks.lock(path)()
+	old, exists, err := readForDiff(path)
+	if err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("读取失败: %v", err))
 		return
 	}
-	if err := os.WriteFile(req.Path, []byte(req.Content), 0o600); err != nil {
-		d.sendOpResult(requestID, false, fmt.Sprintf("写入失败: %v", err))
+	if req.ExpectedHash != "" {
+		var current string
+		switch {
+		case old != nil:
+			current = hashBytes(old)
+		case exists:
+			if current, err = fileHash(path); err != nil {
+				d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("读取失败: %v", err))
+				return
+			}
+		}
+		if !strings.EqualFold(current, req.ExpectedHash) {
+			d.sendConflict(requestID, path, current)
+			return
+		}
+	}
+	if err := cancelled(ctx); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("未写入: %v", err))
 		return
 	}
-	d.sendOpResult(requestID, true, "")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("创建目录失败: %v", err))
+		return
+	}
+	if err := writeFileAtomic(path, content, fileMode(req.Mode)); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("写入失败: %v", err))
+		return
+	}
+	oldName := path
+	if !exists {
+		oldName = ""
+	}
+	change := fileChange(oldName, path, old, content)
+	logChange("写入", path, change)
+	d.sendResult(requestID, &v1.OpResult{Success: true, Change: change})
 }
 
-// handleEditFile 编辑文件（查找替换）
-func (d *Daemon) handleEditFile(requestID string, req *v1.EditFileRequest) {
-	log.Printf("[文件] 编辑 %s", req.Path)
-	if !d.isPathAllowed(req.Path) {
-		d.sendOpResult(requestID, false, fmt.Sprintf("路径不允许: %s", req.Path))
+// readForDiff 读取写入前的内容，用于校验 expected_hash 和生成 diff。
+// 文件不存在时 exists 为 false；超过 maxDiffInput 或不是普通文件时不读取，data 为 nil。
+func readForDiff(path string) (data []byte, exists bool, err error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > maxDiffInput {
+		return nil, true, nil
+	}
+	data, err = os.ReadFile(path)
+	return data, true, err
+}
+
+// handleStat 查询文件信息，最后一级的符号链接不跟随
+func (d *Daemon) handleStat(requestID string, req *v1.StatRequest) {
+	log.Printf("[文件] stat %s", req.Path)
+	path, err := d.resolveEntry(req.Path, accessRead)
+	if err != nil {
+		d.sendFileStat(requestID, &v1.FileStat{Error: pathErrorMessage(req.Path, err), Code: pathErrorCode(err)})
 		return
 	}
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		d.sendFileStat(requestID, &v1.FileStat{Path: path})
+		return
+	}
+	if err != nil {
+		d.sendFileStat(requestID, &v1.FileStat{Path: path, Error: fmt.Sprintf("stat 失败: %v", err), Code: errorCode(err)})
+		return
+	}
+	d.sendFileStat(requestID, &v1.FileStat{
+		Path:   path,
+		Exists: true,
+		Info:   dirEntry(filepath.Base(path), path, info),
+	})
+}
 
-	data, err := os.ReadFile(req.Path)
+// handleRemove 删除文件、符号链接或目录
+func (d *Daemon) handleRemove(ctx context.Context, requestID string, req *v1.RemoveRequest) {
+	log.Printf("[文件] 删除 %s (recursive=%v)", req.Path, req.Recursive)
+	path, err := d.resolveEntry(req.Path, accessWrite)
 	if err != nil {
-		d.sendOpResult(requestID, false, fmt.Sprintf("读取失败: %v", err))
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Path, err))
+		return
+	}
+	if filepath.Dir(path) == path {
+		d.sendOpResult(requestID, codePermissionDenied, "拒绝删除根目录")
+		return
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("路径不存在: %s", path))
+		return
+	}
+
+	remove := os.Remove
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("读取目录失败: %v", err))
+			return
+		}
+		if len(entries) > 0 {
+			if !req.Recursive {
+				d.sendOpResult(requestID, codeInvalidArgument, fmt.Sprintf("目录非空: %s，需要 recursive", path))
+				return
+			}
+			if err := d.checkSubtree(path, accessWrite); err != nil {
+				d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Path, err))
+				return
+			}
+			remove = os.RemoveAll
+		}
+	}
+	if err := cancelled(ctx); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("未删除: %v", err))
+		return
+	}
+	if err := remove(path); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("删除失败: %v", err))
 		return
 	}
-	content := string(data)
+	d.sendOpResult(requestID, codeNone, "")
+}
 
-	if req.OldString == "" {
-		d.sendOpResult(requestID, false, "old_string 不能为空")
+// handleMove 移动/重命名。源和目标的最后一级都不跟随符号链接。
+func (d *Daemon) handleMove(ctx context.Context, requestID string, req *v1.MoveRequest) {
+	log.Printf("[文件] 移动 %s -> %s", req.Source, req.Destination)
+	src, err := d.resolveEntry(req.Source, accessWrite)
+	if err != nil {
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Source, err))
+		return
+	}
+	dst, err := d.resolveEntry(req.Destination, accessWrite)
+	if err != nil {
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Destination, err))
+		return
+	}
+	info, err := os.Lstat(src)
+	if err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("源路径不存在: %s", src))
+		return
+	}
+	if src == dst {
+		d.sendOpResult(requestID, codeNone, "")
+		return
+	}
+	if info.IsDir() {
+		if isWithin(src, dst) {
+			d.sendOpResult(requestID, codeInvalidArgument, "不能把目录移动到它自身之下")
+			return
+		}
+		if err := d.checkSubtree(src, accessWrite); err != nil {
+			d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Source, err))
+			return
+		}
+		if err := d.checkSubtree(dst, accessWrite); err != nil {
+			d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Destination, err))
+			return
+		}
+	}
+	if err := checkDestination(dst, info.IsDir(), req.Overwrite); err != nil {
+		d.sendOpResult(requestID, errorCode(err), err.Error())
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("创建目录失败: %v", err))
+		return
+	}
+
+	if err := cancelled(ctx); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("未移动: %v", err))
+		return
+	}
+	err = os.Rename(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		// 跨文件系统：复制后删除源，中途取消时保留源
+		if err = copyTree(ctx, src, dst, req.Overwrite); err == nil {
+			err = os.RemoveAll(src)
+		}
+	}
+	if err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("移动失败: %v", err))
 		return
 	}
+	d.sendOpResult(requestID, codeNone, "")
+}
 
-	count := strings.Count(content, req.OldString)
-	if count == 0 {
-		d.sendOpResult(requestID, false, "old_string 未找到")
+// handleCopy 复制文件或目录。源的符号链接会被跟随，目录内的符号链接按链接复制。
+func (d *Daemon) handleCopy(ctx context.Context, requestID string, req *v1.CopyRequest) {
+	log.Printf("[文件] 复制 %s -> %s", req.Source, req.Destination)
+	src, err := d.resolvePath(req.Source, accessRead)
+	if err != nil {
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Source, err))
+		return
+	}
+	// 目标也解析符号链接，避免经目标位置已有的链接写到白名单之外
+	dst, err := d.resolvePath(req.Destination, accessWrite)
+	if err != nil {
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Destination, err))
+		return
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("源路径不存在: %s", src))
+		return
+	}
+	if src == dst {
+		d.sendOpResult(requestID, codeInvalidArgument, "源和目标相同")
+		return
+	}
+	if info.IsDir() {
+		if !req.Recursive {
+			d.sendOpResult(requestID, codeInvalidArgument, fmt.Sprintf("源是目录: %s，需要 recursive", src))
+			return
+		}
+		if isWithin(src, dst) {
+			d.sendOpResult(requestID, codeInvalidArgument, "不能把目录复制到它自身之下")
+			return
+		}
+		if err := d.checkSubtree(src, accessRead); err != nil {
+			d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Source, err))
+			return
+		}
+		if err := d.checkSubtree(dst, accessWrite); err != nil {
+			d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Destination, err))
+			return
+		}
+	}
+	if err := checkDestination(dst, info.IsDir(), req.Overwrite); err != nil {
+		d.sendOpResult(requestID, errorCode(err), err.Error())
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("创建目录失败: %v", err))
+		return
+	}
+	if err := copyTree(ctx, src, dst, req.Overwrite); err != nil {
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("复制失败: %v", err))
 		return
 	}
-	if !req.ReplaceAll && count > 1 {
-		d.sendOpResult(requestID, false,
-			fmt.Sprintf("old_string 出现 %d 次，需更多上下文或使用 replace_all", count))
+	d.sendOpResult(requestID, codeNone, "")
+}
+
+// handleMkdir 创建目录
+func (d *Daemon) handleMkdir(requestID string, req *v1.MkdirRequest) {
+	log.Printf("[文件] 创建目录 %s", req.Path)
+	path, err := d.resolvePath(req.Path, accessWrite)
+	if err != nil {
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Path, err))
 		return
 	}
+	mode := fs.FileMode(0o755)
+	if req.Mode != 0 {
+		mode = fileMode(req.Mode)
+	}
 
-	var newContent string
-	if req.ReplaceAll {
-		newContent = strings.ReplaceAll(content, req.OldString, req.NewString)
+	if req.Parents {
+		err = os.MkdirAll(path, mode)
 	} else {
-		newContent = strings.Replace(content, req.OldString, req.NewString, 1)
+		err = os.Mkdir(path, mode)
+	}
+	switch {
+	case errors.Is(err, fs.ErrExist):
+		d.sendOpResult(requestID, codeAlreadyExists, fmt.Sprintf("路径已存在: %s", path))
+	case errors.Is(err, fs.ErrNotExist):
+		d.sendOpResult(requestID, codeNotFound, fmt.Sprintf("上级目录不存在: %s，需要 parents", filepath.Dir(path)))
+	case err != nil:
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("创建目录失败: %v", err))
+	default:
+		d.sendOpResult(requestID, codeNone, "")
 	}
+}
 
-	if err := os.WriteFile(req.Path, []byte(newContent), 0o600); err != nil {
-		d.sendOpResult(requestID, false, fmt.Sprintf("写回失败: %v", err))
+// handleChmod 修改权限位
+func (d *Daemon) handleChmod(requestID string, req *v1.ChmodRequest) {
+	log.Printf("[文件] chmod %s %04o", req.Path, req.Mode&0o7777)
+	path, err := d.resolvePath(req.Path, accessWrite)
+	if err != nil {
+		d.sendOpResult(requestID, pathErrorCode(err), pathErrorMessage(req.Path, err))
+		return
+	}
+	if err := os.Chmod(path, fileMode(req.Mode)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			d.sendOpResult(requestID, codeNotFound, fmt.Sprintf("路径不存在: %s", path))
+			return
+		}
+		d.sendOpResult(requestID, errorCode(err), fmt.Sprintf("chmod 失败: %v", err))
 		return
 	}
-	d.sendOpResult(requestID, true, "")
+	d.sendOpResult(requestID, codeNone, "")
+}
+
+// checkDestination 检查移动/复制的目标，返回非 nil 表示拒绝的原因
+func checkDestination(dst string, srcIsDir, overwrite bool) error {
+	info, err := os.Lstat(dst)
+	if err != nil {
+		return nil
+	}
+	if !overwrite {
+		return errorf(codeAlreadyExists, "目标已存在: %s，需要 overwrite", dst)
+	}
+	if info.IsDir() != srcIsDir {
+		if info.IsDir() {
+			return errorf(codeInvalidArgument, "目标是目录，不能用文件覆盖: %s", dst)
+		}
+		return errorf(codeInvalidArgument, "目标不是目录，不能用目录覆盖: %s", dst)
+	}
+	return nil
+}
+
+// copyTree 把 src（文件或目录）复制到 dst，保留权限位，不跟随目录内的符号链接。
+// overwrite 为 false 时遇到已存在的文件即失败；已存在的目录会被合并。
+// ctx 结束时停止复制并返回原因，已复制的部分保留。
+func copyTree(ctx context.Context, src, dst string, overwrite bool) error {
+	var dirs []string // 复制完内容后再设置目录权限，避免只读目录无法写入
+	var modes []fs.FileMode
+	err := filepath.WalkDir(src, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := cancelled(ctx); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			if err := os.Mkdir(target, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+				return err
+			}
+			dirs = append(dirs, target)
+			modes = append(modes, mode.Perm())
+			return nil
+		case mode&fs.ModeSymlink != 0, mode.IsRegular():
+			// 目标位置已有符号链接时先删除，不写到链接指向的位置
+			if existing, err := os.Lstat(target); err == nil {
+				if !overwrite {
+					return errorf(codeAlreadyExists, "目标已存在: %s", target)
+				}
+				if existing.IsDir() {
+					return errorf(codeInvalidArgument, "目标是目录: %s", target)
+				}
+				if mode&fs.ModeSymlink != 0 || existing.Mode()&fs.ModeSymlink != 0 {
+					if err := os.Remove(target); err != nil {
+						return err
+					}
+				}
+			}
+			if mode&fs.ModeSymlink != 0 {
+				link, err := os.Readlink(path)
+				if err != nil {
+					return err
+				}
+				return os.Symlink(link, target)
+			}
+			return copyFile(ctx, path, target, mode.Perm())
+		default:
+			return errorf(codeUnsupported, "不支持复制特殊文件: %s", path)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], modes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile 复制普通文件内容，并把目标权限设为 perm
+func copyFile(ctx context.Context, src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, ctxReader{ctx, in}); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, perm) // 不受 umask 和已存在文件的原权限影响
+}
+
+// fileMode 把 Unix 权限位（含 setuid/setgid/sticky）转换为 fs.FileMode
+func fileMode(mode uint32) fs.FileMode {
+	m := fs.FileMode(mode & 0o777)
+	if mode&0o4000 != 0 {
+		m |= fs.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		m |= fs.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		m |= fs.ModeSticky
+	}
+	return m
+}
+
+// sendFileStat 发送 stat 结果
+func (d *Daemon) sendFileStat(requestID string, stat *v1.FileStat) {
+	if err := d.send(&v1.ConnectRequest{
+		RequestId: requestID,
+		Payload:   &v1.ConnectRequest_FileStat{FileStat: stat},
+	}); err != nil {
+		log.Printf("[文件] 发送 stat 结果失败: %v", err)
+	}
+}
+
+// fileError 构造失败的 FileContent
+func fileError(fileSize int64, code v1.ErrorCode, msg string) *v1.FileContent {
+	return &v1.FileContent{FileSize: fileSize, Error: msg, Code: code}
 }
 
 // sendFileContent 发送文件内容
-func (d *Daemon) sendFileContent(requestID, content string, totalLines, fileSize int64, errMsg string) {
+func (d *Daemon) sendFileContent(requestID string, fc *v1.FileContent) {
 	if err := d.send(&v1.ConnectRequest{
 		RequestId: requestID,
-		Payload: &v1.ConnectRequest_FileContent{
-			FileContent: &v1.FileContent{
-				Content:    content,
-				TotalLines: totalLines,
-				FileSize:   fileSize,
-				Error:      errMsg,
-			},
-		},
+		Payload:   &v1.ConnectRequest_FileContent{FileContent: fc},
 	}); err != nil {
 		log.Printf("[文件] 发送内容失败: %v", err)
 	}
 }
 
-// sendOpResult 发送操作结果
-func (d *Daemon) sendOpResult(requestID string, success bool, errMsg string) {
+// sendOpResult 发送操作结果，errMsg 为空表示成功
+func (d *Daemon) sendOpResult(requestID string, code v1.ErrorCode, errMsg string) {
+	d.sendResult(requestID, &v1.OpResult{Success: errMsg == "", Error: errMsg, Code: code})
+}
+
+// sendConflict 发送 expected_hash 不匹配的结果，current 为文件当前的哈希（不存在时为空）
+func (d *Daemon) sendConflict(requestID, path, current string) {
+	msg := fmt.Sprintf("文件已被修改: %s，请重新读取后再试", path)
+	if current == "" {
+		msg = fmt.Sprintf("文件不存在: %s，expected_hash 只能用于已存在的文件", path)
+	}
+	log.Printf("[文件] 冲突 %s", path)
+	d.sendResult(requestID, &v1.OpResult{
+		Error:  msg,
+		Code:   codeConflict,
+		Sha256: current,
+	})
+}
+
+// sendResult 发送完整的 OpResult
+func (d *Daemon) sendResult(requestID string, result *v1.OpResult) {
 	if err := d.send(&v1.ConnectRequest{
 		RequestId: requestID,
-		Payload: &v1.ConnectRequest_OpResult{
-			OpResult: &v1.OpResult{
-				Success: success,
-				Error:   errMsg,
-			},
-		},
+		Payload:   &v1.ConnectRequest_OpResult{OpResult: result},
 	}); err != nil {
 		log.Printf("[文件] 发送结果失败: %v", err)
 	}
 }
+
+// hashBytes 返回 data 的 SHA-256（hex）
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fileHash 返回文件内容的 SHA-256（hex）
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashReader(f)
+}
+
+// hashReader 计算 r 中剩余内容的 SHA-256（hex）
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
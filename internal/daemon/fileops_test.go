@@ -0,0 +1,414 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+	"github.com/epiral/cli/internal/config"
+)
+
+func TestReadFileBytes(t *testing.T) {
+	const content = "0123456789"
+	path := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	size := int64(len(content))
+
+	tests := []struct {
+		name           string
+		offset, length int64
+		maxSize        int64
+		want           string
+		wantHash       bool
+		wantErr        v1.ErrorCode
+	}{
+		{name: "整个文件", want: content, wantHash: true},
+		{name: "中间一段", offset: 2, length: 3, want: "234"},
+		{name: "长度超出文件末尾", offset: 8, length: 100, want: "89"},
+		{name: "偏移等于文件大小", offset: size, want: ""},
+		{name: "从头读但不到末尾", length: 5, want: "01234"},
+		{name: "偏移超出文件大小", offset: size + 1, wantErr: codeInvalidArgument},
+		{name: "负偏移", offset: -1, wantErr: codeInvalidArgument},
+		{name: "负长度", length: -1, wantErr: codeInvalidArgument},
+		{name: "超过单次上限", maxSize: 4, wantErr: codeTooLarge},
+		{name: "分段不超过单次上限", offset: 4, length: 4, maxSize: 4, want: "4567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = defaultMaxFileSize
+			}
+			fc := readFileBytes(file, size, tt.offset, tt.length, maxSize)
+			if fc.Code != tt.wantErr {
+				t.Fatalf("code = %v (%s)，期望 %v", fc.Code, fc.Error, tt.wantErr)
+			}
+			if tt.wantErr != codeNone {
+				return
+			}
+			if string(fc.Data) != tt.want || fc.FileSize != size {
+				t.Fatalf("data = %q size=%d", fc.Data, fc.FileSize)
+			}
+			// 只有读取覆盖整个文件时才给出哈希
+			if hasHash := fc.Sha256 != ""; hasHash != tt.wantHash {
+				t.Fatalf("sha256 = %q", fc.Sha256)
+			}
+			if tt.wantHash && fc.Sha256 != hashBytes([]byte(content)) {
+				t.Fatalf("sha256 = %q，与文件内容不符", fc.Sha256)
+			}
+		})
+	}
+}
+
+func TestReadFileLines(t *testing.T) {
+	dir := t.TempDir()
+	open := func(content string) (*os.File, int64) {
+		t.Helper()
+		path := filepath.Join(dir, "f")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { file.Close() })
+		return file, int64(len(content))
+	}
+
+	tests := []struct {
+		name          string
+		content       string
+		offset, limit int
+		want          string
+		wantLines     int64
+		wantBinary    bool
+	}{
+		{name: "保留 CRLF 和末行无换行", content: "a\r\nb\nc", want: "a\r\nb\nc", wantLines: 3},
+		{name: "行偏移和行数", content: "1\n2\n3\n4\n", offset: 1, limit: 2, want: "2\n3\n", wantLines: 4},
+		{name: "偏移超出总行数", content: "1\n2\n", offset: 5, want: "", wantLines: 2},
+		{name: "非 UTF-8 字节替换并标记", content: "ok\n\xff\xfex\n", want: "ok\n�x\n", wantLines: 2, wantBinary: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, size := open(tt.content)
+			fc := readFileLines(file, size, tt.offset, tt.limit, defaultMaxFileSize)
+			if fc.Error != "" {
+				t.Fatal(fc.Error)
+			}
+			if fc.Content != tt.want || fc.TotalLines != tt.wantLines || fc.Binary != tt.wantBinary {
+				t.Fatalf("content=%q lines=%d binary=%v", fc.Content, fc.TotalLines, fc.Binary)
+			}
+			// 哈希总是原始内容的，可以直接用作 expected_hash
+			if fc.Sha256 != hashBytes([]byte(tt.content)) {
+				t.Fatalf("sha256 = %q，与原始内容不符", fc.Sha256)
+			}
+		})
+	}
+
+	file, size := open("0123456789")
+	if fc := readFileLines(file, size, 0, 0, 4); fc.Code != codeTooLarge {
+		t.Fatalf("超过上限: code = %v", fc.Code)
+	}
+}
+
+func TestBinaryFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	_, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	path := filepath.Join(dir, "blob.bin")
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	msgs := hub.do(t, &v1.ConnectResponse{RequestId: "w", Payload: &v1.ConnectResponse_WriteFile{WriteFile: &v1.WriteFileRequest{
+		Path: path, Data: data, Binary: true,
+	}}}, hasOpResult)
+	if r := msgs[0].GetOpResult(); !r.Success {
+		t.Fatalf("写入失败: %s", r.Error)
+	}
+	// 新文件默认只有属主可读写
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Fatalf("新文件权限 = %v，期望 0600", info.Mode().Perm())
+	}
+
+	read := func(id string, req *v1.ReadFileRequest) *v1.FileContent {
+		t.Helper()
+		msgs := hub.do(t, &v1.ConnectResponse{RequestId: id, Payload: &v1.ConnectResponse_ReadFile{ReadFile: req}},
+			func(m *v1.ConnectRequest) bool { return m.GetFileContent() != nil })
+		return msgs[0].GetFileContent()
+	}
+	fc := read("r1", &v1.ReadFileRequest{Path: path, Mode: v1.ReadMode_READ_MODE_BYTES})
+	if !bytes.Equal(fc.Data, data) || fc.Sha256 != hashBytes(data) {
+		t.Fatalf("字节模式读回 %d 字节，sha256=%q", len(fc.Data), fc.Sha256)
+	}
+	fc = read("r2", &v1.ReadFileRequest{Path: path, Mode: v1.ReadMode_READ_MODE_BYTES, ByteOffset: 128, ByteLength: 2})
+	if !bytes.Equal(fc.Data, data[128:130]) || fc.Sha256 != "" {
+		t.Fatalf("分段读取: data=%v sha256=%q", fc.Data, fc.Sha256)
+	}
+	// 行模式也能读，非 UTF-8 字节被替换并标记
+	fc = read("r3", &v1.ReadFileRequest{Path: path})
+	if fc.Error != "" || !fc.Binary || fc.Sha256 != hashBytes(data) {
+		t.Fatalf("行模式: error=%q binary=%v", fc.Error, fc.Binary)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	const writers = 8
+	dir := t.TempDir()
+	_, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	opResults := func(prefix string) []*v1.OpResult {
+		t.Helper()
+		var results []*v1.OpResult
+		for i := range writers {
+			for _, m := range hub.wait(t, fmt.Sprint(prefix, i), hasOpResult) {
+				results = append(results, m.GetOpResult())
+			}
+		}
+		return results
+	}
+
+	// 基于同一 expected_hash 的并发写入只有一个成功，其余 CONFLICT
+	path := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i := range writers {
+		hub.down <- &v1.ConnectResponse{RequestId: fmt.Sprint("w", i), Payload: &v1.ConnectResponse_WriteFile{WriteFile: &v1.WriteFileRequest{
+			Path: path, Content: fmt.Sprint("new ", i), ExpectedHash: hashBytes([]byte("old")),
+		}}}
+	}
+	succeeded := 0
+	for _, r := range opResults("w") {
+		switch {
+		case r.Success:
+			succeeded++
+		case r.Code != codeConflict:
+			t.Fatalf("写入失败: %s", r.Error)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d 个写入成功，期望 1 个", succeeded)
+	}
+
+	// 并发编辑同一文件不丢失更新
+	if err := os.WriteFile(path, []byte("END\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i := range writers {
+		hub.down <- &v1.ConnectResponse{RequestId: fmt.Sprint("e", i), Payload: &v1.ConnectResponse_EditFile{EditFile: &v1.EditFileRequest{
+			Path: path, OldString: "END", NewString: fmt.Sprintf("line %d\nEND", i),
+		}}}
+	}
+	for _, r := range opResults("e") {
+		if !r.Success {
+			t.Fatalf("编辑失败: %s", r.Error)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if n := strings.Count(string(data), "line "); n != writers {
+		t.Fatalf("文件中有 %d 处编辑，期望 %d:\n%s", n, writers, data)
+	}
+}
+
+func TestFileManagement(t *testing.T) {
+	dir := t.TempDir()
+	ro := filepath.Join(dir, "tree", "ro")
+	_, hub, _ := startTestDaemon(t, Config{
+		AllowedPaths: []string{dir},
+		PathRules: []config.PathRule{
+			{Path: ro, Access: []string{config.AccessRead}},
+			{Path: filepath.Join(dir, "secret"), Access: []string{config.AccessDeny}},
+		},
+	})
+	var seq int
+	do := func(msg *v1.ConnectResponse, until func(*v1.ConnectRequest) bool) *v1.ConnectRequest {
+		t.Helper()
+		seq++
+		msg.RequestId = fmt.Sprint("f", seq)
+		return hub.do(t, msg, until)[0]
+	}
+	op := func(msg *v1.ConnectResponse) *v1.OpResult {
+		t.Helper()
+		return do(msg, hasOpResult).GetOpResult()
+	}
+	stat := func(path string) *v1.FileStat {
+		t.Helper()
+		return do(&v1.ConnectResponse{Payload: &v1.ConnectResponse_Stat{Stat: &v1.StatRequest{Path: path}}},
+			func(m *v1.ConnectRequest) bool { return m.GetFileStat() != nil }).GetFileStat()
+	}
+	remove := func(path string, recursive bool) *v1.OpResult {
+		return op(&v1.ConnectResponse{Payload: &v1.ConnectResponse_Remove{Remove: &v1.RemoveRequest{Path: path, Recursive: recursive}}})
+	}
+	move := func(src, dst string, overwrite bool) *v1.OpResult {
+		return op(&v1.ConnectResponse{Payload: &v1.ConnectResponse_Move{Move: &v1.MoveRequest{Source: src, Destination: dst, Overwrite: overwrite}}})
+	}
+	copyPath := func(src, dst string, overwrite, recursive bool) *v1.OpResult {
+		return op(&v1.ConnectResponse{Payload: &v1.ConnectResponse_Copy{Copy: &v1.CopyRequest{Source: src, Destination: dst, Overwrite: overwrite, Recursive: recursive}}})
+	}
+	mkdir := func(path string, parents bool, mode uint32) *v1.OpResult {
+		return op(&v1.ConnectResponse{Payload: &v1.ConnectResponse_Mkdir{Mkdir: &v1.MkdirRequest{Path: path, Parents: parents, Mode: mode}}})
+	}
+	chmod := func(path string, mode uint32) *v1.OpResult {
+		return op(&v1.ConnectResponse{Payload: &v1.ConnectResponse_Chmod{Chmod: &v1.ChmodRequest{Path: path, Mode: mode}}})
+	}
+	expect := func(t *testing.T, r *v1.OpResult, code v1.ErrorCode) {
+		t.Helper()
+		if r.Code != code || r.Success != (code == codeNone) {
+			t.Fatalf("success=%v code=%v (%s)，期望 %v", r.Success, r.Code, r.Error, code)
+		}
+	}
+	exists := func(path string) bool {
+		_, err := os.Lstat(path)
+		return err == nil
+	}
+	read := func(path string) string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+	writeTree(t, dir, map[string]string{
+		"f.txt":          "hello",
+		"tree/a.txt":     "a",
+		"tree/sub/b.txt": "b",
+		"tree/ro/c.txt":  "c",
+		"plain/d.txt":    "d",
+		"secret/s.txt":   "s",
+	})
+	if err := os.Symlink("f.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Stat", func(t *testing.T) {
+		s := stat(filepath.Join(dir, "f.txt"))
+		if !s.Exists || s.Info.Name != "f.txt" || s.Info.Type != v1.EntryType_ENTRY_TYPE_FILE || s.Info.Size != 5 {
+			t.Fatalf("f.txt: %+v", s)
+		}
+		// 最后一级的符号链接不跟随
+		if s := stat(filepath.Join(dir, "link")); s.Info.GetType() != v1.EntryType_ENTRY_TYPE_SYMLINK || s.Info.LinkTarget != "f.txt" {
+			t.Fatalf("link: %+v", s)
+		}
+		// 不存在不算错误
+		if s := stat(filepath.Join(dir, "missing")); s.Exists || s.Error != "" {
+			t.Fatalf("missing: %+v", s)
+		}
+		if s := stat(filepath.Join(dir, "secret", "s.txt")); s.Code != codePermissionDenied {
+			t.Fatalf("无读权限: %+v", s)
+		}
+	})
+
+	t.Run("Mkdir", func(t *testing.T) {
+		expect(t, mkdir(filepath.Join(dir, "m"), false, 0o750), codeNone)
+		if info, err := os.Stat(filepath.Join(dir, "m")); err != nil || !info.IsDir() || info.Mode().Perm()&^0o750 != 0 {
+			t.Fatalf("新目录: %v %v", info.Mode(), err)
+		}
+		expect(t, mkdir(filepath.Join(dir, "m"), false, 0), codeAlreadyExists)
+		expect(t, mkdir(filepath.Join(dir, "m"), true, 0), codeNone)
+		expect(t, mkdir(filepath.Join(dir, "x", "y"), false, 0), codeNotFound)
+		expect(t, mkdir(filepath.Join(dir, "x", "y"), true, 0), codeNone)
+		expect(t, mkdir(filepath.Join(ro, "new"), false, 0), codePermissionDenied)
+	})
+
+	t.Run("Chmod", func(t *testing.T) {
+		path := filepath.Join(dir, "f.txt")
+		expect(t, chmod(path, 0o600), codeNone)
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+			t.Fatalf("权限 = %v", info.Mode().Perm())
+		}
+		expect(t, chmod(filepath.Join(dir, "missing"), 0o600), codeNotFound)
+		expect(t, chmod(filepath.Join(ro, "c.txt"), 0o600), codePermissionDenied)
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		src := filepath.Join(dir, "f.txt")
+		dst := filepath.Join(dir, "copies", "f.txt")
+		expect(t, copyPath(src, dst, false, false), codeNone)
+		if read(dst) != "hello" {
+			t.Fatalf("复制的内容 = %q", read(dst))
+		}
+		if info, _ := os.Stat(dst); info.Mode().Perm() != 0o600 {
+			t.Fatalf("复制后权限 = %v，期望沿用源文件的 0600", info.Mode().Perm())
+		}
+		expect(t, copyPath(src, dst, false, false), codeAlreadyExists)
+		expect(t, copyPath(src, dst, true, false), codeNone)
+		expect(t, copyPath(src, src, true, false), codeInvalidArgument)
+
+		plain := filepath.Join(dir, "plain")
+		expect(t, copyPath(plain, filepath.Join(dir, "plain2"), false, false), codeInvalidArgument)
+		expect(t, copyPath(plain, filepath.Join(plain, "inner"), false, true), codeInvalidArgument)
+		expect(t, copyPath(plain, filepath.Join(dir, "plain2"), false, true), codeNone)
+		if read(filepath.Join(dir, "plain2", "d.txt")) != "d" {
+			t.Fatal("目录未递归复制")
+		}
+		// 目录下有 deny 的子路径时拒绝复制出去
+		expect(t, copyPath(dir, filepath.Join(t.TempDir(), "out"), false, true), codePermissionDenied)
+		// 复制到包含只读子路径的目录上也拒绝
+		expect(t, copyPath(plain, filepath.Join(dir, "tree"), true, true), codePermissionDenied)
+		if exists(filepath.Join(dir, "tree", "d.txt")) {
+			t.Fatal("被拒绝的复制不应写入任何文件")
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		src := filepath.Join(dir, "copies", "f.txt")
+		dst := filepath.Join(dir, "moved", "g.txt")
+		expect(t, move(src, dst, false), codeNone)
+		if exists(src) || read(dst) != "hello" {
+			t.Fatal("文件未移动")
+		}
+		other := filepath.Join(dir, "plain2", "d.txt")
+		expect(t, move(other, dst, false), codeAlreadyExists)
+		expect(t, move(other, filepath.Join(dir, "plain"), true), codeInvalidArgument)
+		expect(t, move(other, dst, true), codeNone)
+		if read(dst) != "d" {
+			t.Fatalf("覆盖后内容 = %q", read(dst))
+		}
+		expect(t, move(filepath.Join(dir, "plain"), filepath.Join(dir, "plain", "inner"), false), codeInvalidArgument)
+		// 源目录下有只读子路径时拒绝
+		expect(t, move(filepath.Join(dir, "tree"), filepath.Join(dir, "tree2"), false), codePermissionDenied)
+		// 移动到包含只读子路径的目标上也拒绝
+		expect(t, mkdir(filepath.Join(dir, "plain3"), false, 0), codeNone)
+		expect(t, move(filepath.Join(dir, "plain3"), filepath.Join(dir, "tree"), true), codePermissionDenied)
+		if !exists(filepath.Join(dir, "plain3")) || !exists(filepath.Join(ro, "c.txt")) {
+			t.Fatal("被拒绝的移动不应改动任何文件")
+		}
+		expect(t, move(filepath.Join(dir, "missing"), filepath.Join(dir, "x"), false), codeNotFound)
+		// 移动符号链接本身，不移动它指向的文件
+		expect(t, move(filepath.Join(dir, "link"), filepath.Join(dir, "link2"), false), codeNone)
+		if target, err := os.Readlink(filepath.Join(dir, "link2")); err != nil || target != "f.txt" || !exists(filepath.Join(dir, "f.txt")) {
+			t.Fatalf("link2 -> %q: %v", target, err)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		// 删除符号链接本身
+		expect(t, remove(filepath.Join(dir, "link2"), false), codeNone)
+		if exists(filepath.Join(dir, "link2")) || !exists(filepath.Join(dir, "f.txt")) {
+			t.Fatal("应只删除符号链接")
+		}
+		expect(t, remove(filepath.Join(dir, "m"), false), codeNone)
+		expect(t, remove(filepath.Join(dir, "plain"), false), codeInvalidArgument)
+		expect(t, remove(filepath.Join(dir, "plain"), true), codeNone)
+		if exists(filepath.Join(dir, "plain")) {
+			t.Fatal("目录未删除")
+		}
+		// 目录下有只读子路径时整体拒绝，什么都不删
+		expect(t, remove(filepath.Join(dir, "tree"), true), codePermissionDenied)
+		if !exists(filepath.Join(dir, "tree", "a.txt")) {
+			t.Fatal("被拒绝的删除不应删除任何文件")
+		}
+		expect(t, remove(filepath.Join(ro, "c.txt"), false), codePermissionDenied)
+		expect(t, remove(filepath.Join(dir, "missing"), false), codeNotFound)
+		expect(t, remove(dir, true), codePermissionDenied)
+	})
+}
@@ -0,0 +1,191 @@
+package daemon
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule 是 .gitignore 中的一行规则
+type ignoreRule struct {
+	re      *regexp.Regexp // 匹配相对 .gitignore 所在目录的路径（/ 分隔）
+	negate  bool           // ! 开头：重新包含
+	dirOnly bool           // / 结尾：只匹配目录
+}
+
+// ignoreFile 是一个目录下的 .gitignore
+type ignoreFile struct {
+	dir   string
+	rules []ignoreRule
+}
+
+// gitignore 是从仓库根到当前目录逐级加载的 .gitignore 规则。
+// 同 git 一样，越深的文件、越靠后的规则优先级越高。
+type gitignore struct {
+	files []*ignoreFile
+}
+
+// newGitignore 为 dir 构建规则：向上找到仓库根（含 .git 的目录），
+// 加载仓库的 .git/info/exclude 和从根到 dir 路径上的所有 .gitignore；
+// 不在仓库中时只加载 dir 自身的 .gitignore。
+func newGitignore(dir string) *gitignore {
+	g := &gitignore{}
+	dirs := []string{dir}
+	for d := dir; ; {
+		if _, err := os.Lstat(filepath.Join(d, ".git")); err == nil {
+			if f := loadIgnoreFile(d, filepath.Join(d, ".git", "info", "exclude")); f != nil {
+				g.files = append(g.files, f)
+			}
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			dirs = dirs[:1] // 没找到仓库根
+			break
+		}
+		d = parent
+		dirs = append(dirs, d)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		g = g.enter(dirs[i])
+	}
+	return g
+}
+
+// enter 返回进入子目录 dir 后的规则（加载 dir 下的 .gitignore），不修改 g
+func (g *gitignore) enter(dir string) *gitignore {
+	f := loadIgnoreFile(dir, filepath.Join(dir, ".gitignore"))
+	if f == nil {
+		return g
+	}
+	files := make([]*ignoreFile, len(g.files), len(g.files)+1)
+	copy(files, g.files)
+	return &gitignore{files: append(files, f)}
+}
+
+// ignored 判断 path（绝对路径）是否被忽略
+func (g *gitignore) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, f := range g.files {
+		rel, err := filepath.Rel(f.dir, path)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, r := range f.rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.re.MatchString(rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// loadIgnoreFile 加载规则文件 name，规则相对 dir；文件不存在或没有规则时返回 nil
+func loadIgnoreFile(dir, name string) *ignoreFile {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	f := &ignoreFile{dir: dir}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if r, ok := parseIgnoreLine(scanner.Text()); ok {
+			f.rules = append(f.rules, r)
+		}
+	}
+	if len(f.rules) == 0 {
+		return nil
+	}
+	return f
+}
+
+// parseIgnoreLine 解析一行 .gitignore，空行和注释返回 false
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// 行尾空格忽略，除非用 \ 转义
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var r ignoreRule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	re, err := compileGlob(line)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// compileGlob 把 gitignore 风格的通配编译为匹配相对路径（/ 分隔）的正则。
+// 含 /（结尾除外）的通配相对基准目录锚定，否则匹配任意层级的名称。
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	prefix := "^(?:.*/)?"
+	if strings.Contains(glob, "/") {
+		prefix = "^"
+		glob = strings.TrimPrefix(glob, "/")
+	}
+	return regexp.Compile(prefix + ignoreGlobToRegexp(glob) + "$")
+}
+
+// ignoreGlobToRegexp 把 gitignore 的通配转换为正则：
+// * 和 ? 不跨越 /，** 匹配任意层级目录，[...] 为字符集，\ 转义下一个字符
+func ignoreGlobToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/'):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRuleMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "dir/a.log", false, true},
+		{"*.log", "a.log.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "doc/sub/a.txt", false, false},
+		{"doc/*.txt", "x/doc/a.txt", false, false},
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", false, true},
+		{"foo/**", "foo/a/b", false, true},
+		{"foo/**", "foo", true, false},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "xa/b", false, false},
+		{"?.go", "a.go", false, true},
+		{"?.go", "ab.go", false, false},
+		{"[abc].go", "b.go", false, true},
+		{"[!abc].go", "b.go", false, false},
+		{"[!abc].go", "d.go", false, true},
+		{`\#notes`, "#notes", false, true},
+		{`\!keep`, "!keep", false, true},
+		{"a.b", "axb", false, false},
+		{"trailing   ", "trailing", false, true},
+	}
+	for _, tt := range tests {
+		r, ok := parseIgnoreLine(tt.pattern)
+		if !ok {
+			t.Errorf("parseIgnoreLine(%q) 解析失败", tt.pattern)
+			continue
+		}
+		got := !(r.dirOnly && !tt.isDir) && r.re.MatchString(tt.path)
+		if got != tt.want {
+			t.Errorf("%q 匹配 %q (dir=%v) = %v，期望 %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "!", "/"} {
+		if _, ok := parseIgnoreLine(line); ok {
+			t.Errorf("parseIgnoreLine(%q) 应被跳过", line)
+		}
+	}
+}
+
+func TestGitignoreNested(t *testing.T) {
+	root := t.TempDir()
+	write := func(p, content string) {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".git/info/exclude", "local.txt\n")
+	write(".gitignore", "*.log\n/dist\nsecret*\n")
+	write("pkg/.gitignore", "!keep.log\ngen/\n")
+
+	tests := []struct {
+		from  string // 从哪个目录开始构建规则
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"", "a.log", false, true},
+		{"", "dist", true, true},
+		{"", "pkg/dist", true, false}, // /dist 只匹配根目录
+		{"", "secret.txt", false, true},
+		{"pkg", "pkg/a.log", false, true},
+		{"pkg", "pkg/keep.log", false, false}, // 子目录的 ! 规则优先
+		{"pkg", "pkg/gen", true, true},
+		{"pkg", "pkg/gen", false, false},
+		{"pkg", "pkg/secret.go", false, true}, // 从子目录开始也会加载仓库根的规则
+		{"", "main.go", false, false},
+		{"pkg", "pkg/local.txt", false, true}, // .git/info/exclude
+	}
+	for _, tt := range tests {
+		g := newGitignore(filepath.Join(root, tt.from))
+		if got := g.ignored(filepath.Join(root, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("从 %q 判断 %q (dir=%v) = %v，期望 %v", tt.from, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
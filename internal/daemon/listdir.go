@@ -0,0 +1,156 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+const (
+	defaultListLimit = 1000
+	maxListLimit     = 10000
+)
+
+// dirLister 深度优先遍历目录，收集条目直到达到上限
+type dirLister struct {
+	ctx       context.Context
+	maxDepth  int
+	limit     int
+	rules     []pathRule // 已规范化的路径规则，为空表示不限制
+	entries   []*v1.DirEntry
+	truncated bool
+}
+
+// handleListDir 列出目录
+func (d *Daemon) handleListDir(ctx context.Context, requestID string, req *v1.ListDirRequest) {
+	log.Printf("[文件] 列目录 %s", req.Path)
+	path, err := d.resolvePath(req.Path, accessRead)
+	if err != nil {
+		d.sendDirListing(requestID, &v1.DirListing{Error: pathErrorMessage(req.Path, err), Code: pathErrorCode(err)})
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		d.sendDirListing(requestID, &v1.DirListing{Error: fmt.Sprintf("目录不存在: %s", path), Code: errorCode(err)})
+		return
+	}
+	if !info.IsDir() {
+		d.sendDirListing(requestID, &v1.DirListing{Error: fmt.Sprintf("路径不是目录: %s", path), Code: codeInvalidArgument})
+		return
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	l := &dirLister{
+		ctx:      ctx,
+		maxDepth: max(int(req.Depth), 1),
+		limit:    min(limit, maxListLimit),
+		rules:    canonicalRules(d.pathRules()),
+	}
+	var ignore *gitignore
+	if req.Gitignore {
+		ignore = newGitignore(path)
+	}
+	if err := l.walk(path, "", 1, ignore); err != nil {
+		d.sendDirListing(requestID, &v1.DirListing{Path: path, Error: fmt.Sprintf("读取目录失败: %v", err), Code: errorCode(err)})
+		return
+	}
+	d.sendDirListing(requestID, &v1.DirListing{Path: path, Entries: l.entries, Truncated: l.truncated})
+}
+
+// walk 列出 dir 下的条目，rel 是 dir 相对所列目录的路径，level 从 1 开始。
+// ignore 已包含 dir 自身的 .gitignore。只有顶层目录读取失败或请求被取消才返回错误，
+// 子目录读取失败（如无权限）直接跳过。
+func (l *dirLister) walk(dir, rel string, level int, ignore *gitignore) error {
+	if err := cancelled(l.ctx); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if level == 1 {
+			return err
+		}
+		return nil
+	}
+	for _, e := range entries {
+		full := filepath.Join(dir, e.Name())
+		name := filepath.ToSlash(filepath.Join(rel, e.Name()))
+		isDir := e.IsDir() // 符号链接不算目录，不会跟随
+		if ignore != nil && (isDir && e.Name() == ".git" || ignore.ignored(full, isDir)) {
+			continue
+		}
+		if !l.readable(full) {
+			continue
+		}
+		if len(l.entries) >= l.limit {
+			l.truncated = true
+			return nil
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // 遍历期间被删除
+		}
+		l.entries = append(l.entries, dirEntry(name, full, info))
+
+		if isDir && level < l.maxDepth {
+			sub := ignore
+			if ignore != nil {
+				sub = ignore.enter(full)
+			}
+			if err := l.walk(full, name, level+1, sub); err != nil {
+				return err
+			}
+			if l.truncated {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// readable 判断条目是否有读权限（目录本身已解析，条目名不含符号链接）
+func (l *dirLister) readable(path string) bool {
+	if len(l.rules) == 0 {
+		return true
+	}
+	_, access := accessFor(path, l.rules)
+	return access&accessRead != 0
+}
+
+func dirEntry(name, full string, info fs.FileInfo) *v1.DirEntry {
+	e := &v1.DirEntry{
+		Name:    name,
+		Mode:    uint32(info.Mode().Perm()),
+		MtimeMs: info.ModTime().UnixMilli(),
+	}
+	switch mode := info.Mode(); {
+	case mode.IsRegular():
+		e.Type = v1.EntryType_ENTRY_TYPE_FILE
+		e.Size = info.Size()
+	case mode.IsDir():
+		e.Type = v1.EntryType_ENTRY_TYPE_DIR
+	case mode&fs.ModeSymlink != 0:
+		e.Type = v1.EntryType_ENTRY_TYPE_SYMLINK
+		e.LinkTarget, _ = os.Readlink(full)
+	default:
+		e.Type = v1.EntryType_ENTRY_TYPE_OTHER
+	}
+	return e
+}
+
+// sendDirListing 发送目录列表
+func (d *Daemon) sendDirListing(requestID string, listing *v1.DirListing) {
+	if err := d.send(&v1.ConnectRequest{
+		RequestId: requestID,
+		Payload:   &v1.ConnectRequest_DirListing{DirListing: listing},
+	}); err != nil {
+		log.Printf("[文件] 发送目录列表失败: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+	"github.com/epiral/cli/internal/config"
+)
+
+// writeTree 在 root 下按 files 创建文件（键为 / 分隔的相对路径），上级目录自动创建
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"b.txt":           "bb",
+		"c.log":           "",
+		".gitignore":      "*.log\n",
+		".git/HEAD":       "ref",
+		"a/x.go":          "package x",
+		"a/sub/deep.txt":  "",
+		"secret/key.pem":  "",
+		"secret/.profile": "",
+	})
+	if err := os.Symlink("b.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	_, hub, _ := startTestDaemon(t, Config{
+		AllowedPaths: []string{dir},
+		PathRules:    []config.PathRule{{Path: filepath.Join(dir, "secret"), Access: []string{config.AccessDeny}}},
+	})
+	var seq int
+	list := func(req *v1.ListDirRequest) *v1.DirListing {
+		t.Helper()
+		seq++
+		msgs := hub.do(t, &v1.ConnectResponse{RequestId: fmt.Sprint("l", seq), Payload: &v1.ConnectResponse_ListDir{ListDir: req}},
+			func(m *v1.ConnectRequest) bool { return m.GetDirListing() != nil })
+		return msgs[0].GetDirListing()
+	}
+	names := func(l *v1.DirListing) []string {
+		var names []string
+		for _, e := range l.Entries {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name          string
+		req           *v1.ListDirRequest
+		want          []string
+		wantTruncated bool
+	}{
+		{
+			name: "只列直接子项，按名称排序，跳过无读权限的目录",
+			req:  &v1.ListDirRequest{Path: dir},
+			want: []string{".git", ".gitignore", "a", "b.txt", "c.log", "link"},
+		},
+		{
+			name: "递归时深度优先",
+			req:  &v1.ListDirRequest{Path: dir, Depth: 3},
+			want: []string{".git", ".git/HEAD", ".gitignore", "a", "a/sub", "a/sub/deep.txt", "a/x.go", "b.txt", "c.log", "link"},
+		},
+		{
+			name: "深度限制",
+			req:  &v1.ListDirRequest{Path: filepath.Join(dir, "a"), Depth: 1},
+			want: []string{"sub", "x.go"},
+		},
+		{
+			name:          "达到 limit 截断",
+			req:           &v1.ListDirRequest{Path: dir, Depth: 3, Limit: 4},
+			want:          []string{".git", ".git/HEAD", ".gitignore", "a"},
+			wantTruncated: true,
+		},
+		{
+			name: "恰好等于 limit 不算截断",
+			req:  &v1.ListDirRequest{Path: filepath.Join(dir, "a"), Limit: 2},
+			want: []string{"sub", "x.go"},
+		},
+		{
+			name: "按 .gitignore 过滤并跳过 .git",
+			req:  &v1.ListDirRequest{Path: dir, Depth: 2, Gitignore: true},
+			want: []string{".gitignore", "a", "a/sub", "a/x.go", "b.txt", "link"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := list(tt.req)
+			if l.Error != "" {
+				t.Fatal(l.Error)
+			}
+			if got := names(l); !slices.Equal(got, tt.want) || l.Truncated != tt.wantTruncated {
+				t.Fatalf("entries = %q truncated=%v，期望 %q truncated=%v", got, l.Truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+
+	// 条目的类型、大小和符号链接目标
+	byName := map[string]*v1.DirEntry{}
+	for _, e := range list(&v1.ListDirRequest{Path: dir}).Entries {
+		byName[e.Name] = e
+	}
+	if e := byName["b.txt"]; e.Type != v1.EntryType_ENTRY_TYPE_FILE || e.Size != 2 || e.Mode != 0o644 {
+		t.Fatalf("b.txt: %+v", e)
+	}
+	if e := byName["a"]; e.Type != v1.EntryType_ENTRY_TYPE_DIR || e.Size != 0 {
+		t.Fatalf("a: %+v", e)
+	}
+	if e := byName["link"]; e.Type != v1.EntryType_ENTRY_TYPE_SYMLINK || e.LinkTarget != "b.txt" {
+		t.Fatalf("link: %+v", e)
+	}
+
+	// 错误
+	errTests := []struct {
+		name string
+		path string
+		want v1.ErrorCode
+	}{
+		{"不是目录", filepath.Join(dir, "b.txt"), codeInvalidArgument},
+		{"不存在", filepath.Join(dir, "missing"), codeNotFound},
+		{"无读权限", filepath.Join(dir, "secret"), codePermissionDenied},
+		{"白名单之外", "/", codePermissionDenied},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if l := list(&v1.ListDirRequest{Path: tt.path}); l.Code != tt.want {
+				t.Fatalf("code = %v (%s)，期望 %v", l.Code, l.Error, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package daemon
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -29,6 +31,8 @@ type Status struct {
 	Reconnects  int             `json:"reconnects"`
 	LastError   string          `json:"lastError,omitempty"`
 	Computer    string          `json:"computer,omitempty"`
+	Features    []string        `json:"features,omitempty"`
+	Browser     *BrowserStatus  `json:"browser,omitempty"` // 配置了浏览器时有效
 }
 
 // Manager 管理 Daemon 的生命周期（启动、重连、停止、重启）
@@ -38,8 +42,12 @@ type Manager struct {
 	lastError   string
 	connectedAt time.Time
 	reconnects  int
+	daemon      *Daemon         // 当前（或最近一次）连接的 Daemon，用于读取浏览器插件状态
+	browser     *browserHistory // 浏览器命令历史，跨重连保留
+	parts       *partFiles      // 未完成上传的临时文件，跨重连保留
 
 	configStore *config.Store
+	version     string // CLI 版本，随注册上报
 
 	cancel    context.CancelFunc
 	done      chan struct{}
@@ -47,10 +55,13 @@ type Manager struct {
 }
 
 // NewManager 创建 Daemon 管理器
-func NewManager(store *config.Store) *Manager {
+func NewManager(store *config.Store, version string) *Manager {
 	return &Manager{
 		configStore: store,
+		version:     version,
 		state:       StateStopped,
+		browser:     &browserHistory{},
+		parts:       newPartFiles(),
 	}
 }
 
@@ -134,6 +145,20 @@ func (m *Manager) Status() Status {
 		LastError:  m.lastError,
 		Computer:   cfg.Computer.ID,
 	}
+	if m.state == StateConnected && m.daemon != nil {
+		s.Features = m.daemon.acceptedFeatures()
+	}
+
+	if cfg.Browser.ID != "" {
+		s.Browser = &BrowserStatus{
+			ID:     cfg.Browser.ID,
+			Listen: cmp.Or(cfg.Browser.Listen, defaultBrowserListen),
+		}
+		m.browser.fill(s.Browser)
+		if m.daemon != nil && m.daemon.config.BrowserID != "" {
+			m.daemon.fillBrowserStatus(s.Browser)
+		}
+	}
 
 	if m.state == StateConnected && !m.connectedAt.IsZero() {
 		t := m.connectedAt
@@ -154,7 +179,10 @@ func (m *Manager) run(ctx context.Context) {
 	}()
 
 	backoff := time.Second
-	const maxBackoff = 30 * time.Second
+	const (
+		maxBackoff    = 30 * time.Second
+		rejectedRetry = 10 * time.Minute // 被 Agent 拒绝后的重试间隔；修改配置会立即重连
+	)
 
 	for {
 		if ctx.Err() != nil {
@@ -175,7 +203,13 @@ func (m *Manager) run(ctx context.Context) {
 		}
 
 		daemonCfg := buildDaemonConfig(&cfg)
+		daemonCfg.Version = m.version
 		d := New(&daemonCfg)
+		d.browserHistory = m.browser
+		d.parts = m.parts
+		m.mu.Lock()
+		m.daemon = d
+		m.mu.Unlock()
 
 		// 设置连接成功回调
 		d.OnConnected = func() {
@@ -202,6 +236,22 @@ func (m *Manager) run(ctx context.Context) {
 			return
 		}
 
+		var rejected *RejectedError
+		if errors.As(err, &rejected) {
+			m.mu.Lock()
+			m.state = StateError
+			m.lastError = err.Error()
+			m.mu.Unlock()
+
+			log.Printf("[连接] 修改配置后将立即重连，否则 %.0f 分钟后重试", rejectedRetry.Minutes())
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(rejectedRetry):
+				continue
+			}
+		}
+
 		connDuration := time.Since(connectStart)
 
 		m.mu.Lock()
@@ -245,6 +295,13 @@ func buildDaemonConfig(cfg *config.Config) Config {
 		ComputerDesc: cfg.Computer.Description,
 		AllowedPaths: cfg.Computer.AllowedPaths,
 		Token:        cfg.Agent.Token,
+		EnvAllow:     cfg.Computer.EnvAllow,
+		EnvDeny:      cfg.Computer.EnvDeny,
+		PathRules:    cfg.Computer.Paths,
+
+		BrowserID:     cfg.Browser.ID,
+		BrowserDesc:   cfg.Browser.Description,
+		BrowserListen: cfg.Browser.Listen,
 	}
 }
 
@@ -0,0 +1,274 @@
+package daemon
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// 输出流
+const (
+	streamStdout = iota
+	streamStderr
+)
+
+const (
+	maxExecStreamBytes = 10 * 1024 * 1024      // 单个输出流的上行上限
+	outputChunkSize    = 32 * 1024             // 单条消息的输出上限，攒够即发
+	outputFlushDelay   = 50 * time.Millisecond // 不足一块时最多攒这么久
+)
+
+// execOutput 负责一次命令执行的全部上行消息。
+// stdout/stderr 经同一个分块器按到达顺序发送，每条消息（含结束消息）按发送顺序分配 seq，
+// Agent 按 seq 排序即可还原两个流的交错顺序。
+type execOutput struct {
+	send func(*v1.ExecOutput) error
+
+	mu        sync.Mutex // 保证 seq 分配顺序与发送顺序一致
+	seq       int64
+	sent      [2]int
+	truncated [2]bool
+	binary    bool
+	broken    bool // 发送失败（连接已断）后不再尝试
+	finished  bool // 已发送结束消息
+}
+
+func (d *Daemon) newExecOutput(requestID string) *execOutput {
+	return &execOutput{send: func(out *v1.ExecOutput) error {
+		return d.send(&v1.ConnectRequest{
+			RequestId: requestID,
+			Payload:   &v1.ConnectRequest_ExecOutput{ExecOutput: out},
+		})
+	}}
+}
+
+// write 发送一块输出。非 UTF-8 字节替换为 U+FFFD 并标记 binary，
+// 超过流上限的部分丢弃并记为截断。
+func (o *execOutput) write(stream int, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	binary := !utf8.Valid(data)
+	if binary {
+		data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+		o.binary = true
+	}
+	if room := maxExecStreamBytes - o.sent[stream]; len(data) > room {
+		room = max(room, 0)
+		for room > 0 && !utf8.RuneStart(data[room]) {
+			room--
+		}
+		data = data[:room]
+		o.truncated[stream] = true
+	}
+	if len(data) == 0 || o.broken {
+		return
+	}
+	o.sent[stream] += len(data)
+
+	out := &v1.ExecOutput{Binary: binary}
+	if stream == streamStdout {
+		out.Stdout = string(data)
+	} else {
+		out.Stderr = string(data)
+	}
+	if err := o.sendLocked(out); err != nil {
+		log.Printf("[执行] 发送输出失败: %v", err)
+		o.broken = true
+	}
+}
+
+// done 发送结束消息。stderr 是 daemon 自身的说明（如"路径不允许"），不受流上限约束；
+// code 是命令未能运行完的原因，命令正常结束（无论退出码）时为 codeNone。
+func (o *execOutput) done(code v1.ErrorCode, stderr string, exitCode int32, workdir string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.finished = true
+	if err := o.sendLocked(&v1.ExecOutput{
+		Stderr:          stderr,
+		ExitCode:        exitCode,
+		Done:            true,
+		Workdir:         workdir,
+		StdoutTruncated: o.truncated[streamStdout],
+		StderrTruncated: o.truncated[streamStderr],
+		Binary:          o.binary,
+		Code:            code,
+	}); err != nil {
+		log.Printf("[执行] 发送结果失败: %v", err)
+	}
+}
+
+// status 发送一条非结束的状态说明（如 pty 输入被丢弃），stderr 是 daemon 自身的说明，不受流上限约束
+func (o *execOutput) status(code v1.ErrorCode, stderr string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.broken || o.finished {
+		return
+	}
+	if err := o.sendLocked(&v1.ExecOutput{Stderr: stderr, Code: code}); err != nil {
+		log.Printf("[执行] 发送状态失败: %v", err)
+		o.broken = true
+	}
+}
+
+func (o *execOutput) sendLocked(out *v1.ExecOutput) error {
+	o.seq++
+	out.Seq = o.seq
+	return o.send(out)
+}
+
+// outputSegment 是一段来自同一个流的输出
+type outputSegment struct {
+	stream int
+	data   []byte
+}
+
+// outputChunker 把 stdout/stderr 按时间/大小聚合成块：缓冲攒够 outputChunkSize 立即发送，
+// 否则最多等待 outputFlushDelay。两个流共用一个缓冲，按到达顺序发送，
+// 因此 seq 的先后就是输出到达的先后。各流块尾不完整的 UTF-8 字符留到该流的下一块。
+type outputChunker struct {
+	out   *execOutput
+	delay time.Duration // 不足一块时最多等待的时间
+	in    chan outputSegment
+	done  chan struct{}
+
+	// 以下只由 loop 访问
+	pending []outputSegment // 待发送的输出，相邻的同流输出合并
+	size    int             // pending 的总字节数
+	carry   [2][]byte       // 各流上一块末尾不完整的 UTF-8 字符
+}
+
+// newChunker 创建并启动分块器，用完必须 Close
+func (o *execOutput) newChunker() *outputChunker {
+	c := &outputChunker{
+		out:   o,
+		delay: outputFlushDelay,
+		in:    make(chan outputSegment, 16),
+		done:  make(chan struct{}),
+	}
+	go c.loop()
+	return c
+}
+
+// writer 返回某个流的写入端，可直接作为 exec.Cmd 的 Stdout/Stderr
+func (c *outputChunker) writer(stream int) io.Writer {
+	return &streamWriter{c: c, stream: stream}
+}
+
+type streamWriter struct {
+	c      *outputChunker
+	stream int
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.c.in <- outputSegment{stream: w.stream, data: bytes.Clone(p)}
+	return len(p), nil
+}
+
+// Close 发送剩余数据并等待分块器退出
+func (c *outputChunker) Close() error {
+	close(c.in)
+	<-c.done
+	return nil
+}
+
+func (c *outputChunker) loop() {
+	defer close(c.done)
+
+	timer := time.NewTimer(c.delay)
+	timer.Stop()
+	armed := false // 计时器是否在等待
+	stale := false // 上次定时发送后没有新数据，剩下的残缺字符不会再补全了
+
+	for {
+		select {
+		case seg, ok := <-c.in:
+			if !ok {
+				timer.Stop()
+				c.flush(true)
+				return
+			}
+			c.add(seg)
+			stale = false
+			if c.size >= outputChunkSize {
+				c.flush(false)
+			}
+			if c.buffered() && !armed {
+				timer.Reset(c.delay)
+				armed = true
+			}
+		case <-timer.C:
+			armed = false
+			c.flush(stale)
+			stale = true
+			if c.buffered() {
+				timer.Reset(c.delay)
+				armed = true
+			}
+		}
+	}
+}
+
+// add 把一段输出排入缓冲，与前一段同流时合并
+func (c *outputChunker) add(seg outputSegment) {
+	if n := len(c.pending); n > 0 && c.pending[n-1].stream == seg.stream {
+		c.pending[n-1].data = append(c.pending[n-1].data, seg.data...)
+	} else {
+		c.pending = append(c.pending, seg)
+	}
+	c.size += len(seg.data)
+}
+
+func (c *outputChunker) buffered() bool {
+	return len(c.pending) > 0 || len(c.carry[streamStdout]) > 0 || len(c.carry[streamStderr]) > 0
+}
+
+// flush 按到达顺序发送缓冲的输出，每条消息不超过 outputChunkSize。
+// 不完整的 UTF-8 尾部留在 carry 中；force 时全部发送。
+func (c *outputChunker) flush(force bool) {
+	for _, seg := range c.pending {
+		data := append(c.carry[seg.stream], seg.data...)
+		c.carry[seg.stream] = nil
+		for len(data) > 0 {
+			piece := data[:min(len(data), outputChunkSize)]
+			if !force || len(piece) < len(data) {
+				if trimmed := trimPartialRune(piece); len(trimmed) > 0 || len(piece) == len(data) {
+					piece = trimmed
+				}
+			}
+			if len(piece) == 0 {
+				break
+			}
+			c.out.write(seg.stream, piece)
+			data = data[len(piece):]
+		}
+		c.carry[seg.stream] = bytes.Clone(data)
+	}
+	c.pending, c.size = nil, 0
+	if force {
+		for stream, tail := range c.carry {
+			if len(tail) > 0 {
+				c.out.write(stream, tail)
+			}
+			c.carry[stream] = nil
+		}
+	}
+}
+
+// trimPartialRune 去掉末尾不完整的 UTF-8 字符
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i > len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
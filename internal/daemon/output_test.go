@@ -0,0 +1,188 @@
+package daemon
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// outputRecorder 记录 execOutput 发出的消息
+type outputRecorder struct {
+	mu   sync.Mutex
+	msgs []*v1.ExecOutput
+}
+
+func (r *outputRecorder) output() *execOutput {
+	return &execOutput{send: func(out *v1.ExecOutput) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.msgs = append(r.msgs, out)
+		return nil
+	}}
+}
+
+// chunks 以 "out:"/"err:" 前缀列出已发出的输出块
+func (r *outputRecorder) chunks() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var chunks []string
+	for _, m := range r.msgs {
+		switch {
+		case m.Stdout != "":
+			chunks = append(chunks, "out:"+m.Stdout)
+		case m.Stderr != "":
+			chunks = append(chunks, "err:"+m.Stderr)
+		}
+	}
+	return chunks
+}
+
+// startChunker 启动一个等待时间为 delay 的分块器
+func startChunker(o *execOutput, delay time.Duration) *outputChunker {
+	c := &outputChunker{out: o, delay: delay, in: make(chan outputSegment, 16), done: make(chan struct{})}
+	go c.loop()
+	return c
+}
+
+func TestOutputChunkerOrder(t *testing.T) {
+	var rec outputRecorder
+	c := startChunker(rec.output(), time.Hour)
+	stdout, stderr := c.writer(streamStdout), c.writer(streamStderr)
+	for _, w := range []struct {
+		stream int
+		text   string
+	}{{streamStdout, "a\n"}, {streamStderr, "b\n"}, {streamStdout, "c\n"}, {streamStdout, "d\n"}} {
+		if w.stream == streamStdout {
+			_, _ = stdout.Write([]byte(w.text))
+		} else {
+			_, _ = stderr.Write([]byte(w.text))
+		}
+	}
+	_ = c.Close()
+
+	// 同一窗口内的输出也按到达顺序发送，相邻的同流输出合并
+	if got, want := rec.chunks(), []string{"out:a\n", "err:b\n", "out:c\nd\n"}; !slices.Equal(got, want) {
+		t.Fatalf("输出块 = %q，期望 %q", got, want)
+	}
+	for i, m := range rec.msgs {
+		if m.Seq != int64(i+1) {
+			t.Fatalf("第 %d 条消息 seq = %d", i, m.Seq)
+		}
+	}
+}
+
+func TestExecOutputOrder(t *testing.T) {
+	dir := t.TempDir()
+	_, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	// 三次写入落在同一个发送窗口内
+	msgs := hub.do(t, &v1.ConnectResponse{RequestId: "1", Payload: &v1.ConnectResponse_Exec{Exec: &v1.ExecRequest{
+		Command: "echo a; sleep 0.01; echo b >&2; sleep 0.01; echo c", Workdir: dir,
+	}}}, execFinished)
+
+	outputs := make([]*v1.ExecOutput, 0, len(msgs))
+	for _, m := range msgs {
+		outputs = append(outputs, m.GetExecOutput())
+	}
+	slices.SortFunc(outputs, func(a, b *v1.ExecOutput) int { return int(a.Seq - b.Seq) })
+	var order string
+	for _, o := range outputs {
+		order += o.Stdout + o.Stderr
+	}
+	if order != "a\nb\nc\n" {
+		t.Fatalf("按 seq 还原的输出 = %q", order)
+	}
+}
+
+func TestOutputChunkerFlush(t *testing.T) {
+	// 每一步先缓冲 add 中的输出，再 flush(force)
+	type step struct {
+		add   []outputSegment
+		force bool
+	}
+	out := func(s string) outputSegment { return outputSegment{stream: streamStdout, data: []byte(s)} }
+	errOut := func(s string) outputSegment { return outputSegment{stream: streamStderr, data: []byte(s)} }
+
+	tests := []struct {
+		name   string
+		steps  []step
+		want   []string
+		binary bool
+	}{
+		{
+			name:  "多字节字符跨块",
+			steps: []step{{add: []outputSegment{out("a\xe4\xbd")}}, {add: []outputSegment{out("\xa0b")}}},
+			want:  []string{"out:a", "out:你b"},
+		},
+		{
+			name:  "残缺字符等到同一个流的下一块，不影响另一个流",
+			steps: []step{{add: []outputSegment{out("\xe4\xbd"), errOut("x"), out("\xa0")}}},
+			want:  []string{"err:x", "out:你"},
+		},
+		{
+			name:   "非法字节替换为 U+FFFD",
+			steps:  []step{{add: []outputSegment{out("a\xffb")}}},
+			want:   []string{"out:a\uFFFDb"},
+			binary: true,
+		},
+		{
+			name:   "始终没有补全的字符最终发送",
+			steps:  []step{{add: []outputSegment{out("a\xe4")}}, {force: true}},
+			want:   []string{"out:a", "out:\uFFFD"},
+			binary: true,
+		},
+		{
+			name:  "超过块大小时切块，不切断字符",
+			steps: []step{{add: []outputSegment{out("a" + strings.Repeat("你", outputChunkSize/3+1))}}},
+			want:  []string{"out:a" + strings.Repeat("你", outputChunkSize/3), "out:你"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec outputRecorder
+			o := rec.output()
+			c := &outputChunker{out: o}
+			for _, st := range tt.steps {
+				for _, seg := range st.add {
+					c.add(seg)
+				}
+				c.flush(st.force)
+			}
+			if got := rec.chunks(); !slices.Equal(got, tt.want) {
+				t.Fatalf("输出块 = %.80q，期望 %.80q", got, tt.want)
+			}
+			if o.binary != tt.binary {
+				t.Fatalf("binary = %v，期望 %v", o.binary, tt.binary)
+			}
+		})
+	}
+}
+
+func TestOutputChunkerSizeFlush(t *testing.T) {
+	var rec outputRecorder
+	c := startChunker(rec.output(), time.Hour)
+	defer c.Close()
+	w := c.writer(streamStdout)
+
+	// 不足一块时等待；攒够一块立即发送，不等计时器
+	_, _ = w.Write([]byte("x"))
+	_, _ = w.Write([]byte(strings.Repeat("y", outputChunkSize-2)))
+	time.Sleep(50 * time.Millisecond)
+	if n := len(rec.chunks()); n != 0 {
+		t.Fatalf("不足一块时发送了 %d 块", n)
+	}
+	_, _ = w.Write([]byte("z"))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.chunks()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("攒够一块后未发送")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := rec.chunks()[0]; len(got) != len("out:")+outputChunkSize {
+		t.Fatalf("块大小 = %d", len(got)-len("out:"))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package daemon
+
+import "io/fs"
+
+// fileOwner 非 Unix 平台没有属主信息
+func fileOwner(fs.FileInfo) (uid, gid int, links uint64, ok bool) {
+	return 0, 0, 0, false
+}
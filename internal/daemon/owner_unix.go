@@ -0,0 +1,17 @@
+//go:build unix
+
+package daemon
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner 返回文件的属主、属组和硬链接数
+func fileOwner(info fs.FileInfo) (uid, gid int, links uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), uint64(st.Nlink), true //nolint:unconvert,gosec // Nlink 的类型因平台而异；uid/gid 不会溢出 int
+}
@@ -0,0 +1,709 @@
+package daemon
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+const defaultPatchFuzz = 2
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// filePatch 是补丁中一个文件的改动
+type filePatch struct {
+	oldPath string      // 去掉 a/ 前缀后的路径，新建时为空
+	newPath string      // 去掉 b/ 前缀后的路径，删除时为空
+	mode    fs.FileMode // new file mode / new mode 中的权限位，0 表示不变
+	binary  bool
+	git     bool // 来自 diff --git 头
+	header  bool // 已读到 ---/+++ 头
+	hunks   []*hunk
+}
+
+type hunk struct {
+	oldStart, oldCount int
+	newStart, newCount int
+	lines              []hunkLine
+}
+
+type hunkLine struct {
+	op    byte   // ' '、'-'、'+'
+	text  string // 不含换行符
+	noEOL bool   // 其后是 "\ No newline at end of file"
+}
+
+// patchTarget 是应用补丁过程中一个文件在内存中的状态，全部检查通过后才写入磁盘
+type patchTarget struct {
+	path     string
+	existed  bool        // 应用前是否存在
+	perm     fs.FileMode // 应用前的权限位
+	original []byte
+	exists   bool        // 应用后是否存在
+	content  []byte      // 应用后的内容
+	mode     fs.FileMode // 要设置的权限位，0 表示不变
+}
+
+// patcher 在内存中依次应用补丁里的各个文件，同一文件被多次改动时看到的是前面改动后的内容
+type patcher struct {
+	d       *Daemon
+	baseDir string
+	maxFuzz int
+	targets map[string]*patchTarget
+	order   []*patchTarget
+	locked  map[string]bool // 已持有路径锁的文件
+}
+
+// handleApplyPatch 应用 unified diff，全部文件、全部 hunk 都能应用才写入
+func (d *Daemon) handleApplyPatch(ctx context.Context, requestID string, req *v1.ApplyPatchRequest) {
+	log.Printf("[文件] 应用补丁 (%d 字节)", len(req.Patch))
+	patches, err := parsePatch(req.Patch)
+	if err != nil {
+		d.sendPatchResult(requestID, &v1.PatchResult{Error: fmt.Sprintf("补丁格式错误: %v", err), Code: codeInvalidArgument})
+		return
+	}
+	if len(patches) == 0 {
+		d.sendPatchResult(requestID, &v1.PatchResult{Error: "补丁中没有文件改动", Code: codeInvalidArgument})
+		return
+	}
+
+	p := &patcher{
+		d:       d,
+		baseDir: req.BaseDir,
+		maxFuzz: int(req.MaxFuzz),
+		targets: map[string]*patchTarget{},
+	}
+	switch {
+	case p.maxFuzz == 0:
+		p.maxFuzz = defaultPatchFuzz
+	case p.maxFuzz < 0:
+		p.maxFuzz = 0
+	}
+	// 从读取各文件到写入完成都持有它们的路径锁，不会与并发的写入、编辑交错
+	defer p.lockTargets(patches)()
+	result := &v1.PatchResult{}
+	files, ok := p.applyAll(patches)
+	result.Files = files
+	if !ok {
+		result.Error = "补丁无法完整应用，未修改任何文件"
+		for _, fr := range files {
+			if fr.Code != codeNone {
+				result.Code = fr.Code
+				break
+			}
+		}
+		d.sendPatchResult(requestID, result)
+		return
+	}
+	if !req.DryRun {
+		if err := cancelled(ctx); err != nil {
+			result.Error = fmt.Sprintf("未修改任何文件: %v", err)
+			result.Code = errorCode(err)
+			d.sendPatchResult(requestID, result)
+			return
+		}
+		if err := p.commit(); err != nil {
+			result.Error = fmt.Sprintf("写入失败，已回滚: %v", err)
+			result.Code = errorCode(err)
+			d.sendPatchResult(requestID, result)
+			return
+		}
+		log.Printf("[文件] 补丁已应用: %d 个文件", len(patches))
+		for _, fr := range files {
+			logChange("补丁", cmp.Or(fr.NewPath, fr.OldPath), fr.Change)
+		}
+	}
+	result.Success = true
+	d.sendPatchResult(requestID, result)
+}
+
+// applyAll 在内存中依次应用所有文件的改动，全部文件、全部 hunk 都成功时 ok 为 true
+func (p *patcher) applyAll(patches []*filePatch) (files []*v1.PatchFileResult, ok bool) {
+	ok = true
+	for _, fp := range patches {
+		fr := p.apply(fp)
+		files = append(files, fr)
+		ok = ok && fr.Error == ""
+		for _, h := range fr.Hunks {
+			ok = ok && h.Applied
+		}
+	}
+	return files, ok
+}
+
+// apply 在内存中应用一个文件的改动
+func (p *patcher) apply(fp *filePatch) *v1.PatchFileResult {
+	fr := &v1.PatchFileResult{}
+	switch {
+	case fp.oldPath == "" && fp.newPath == "":
+		fr.Error, fr.Code = "缺少文件路径", codeInvalidArgument
+		return fr
+	case fp.oldPath == "":
+		fr.Action = v1.PatchAction_PATCH_ACTION_CREATE
+	case fp.newPath == "":
+		fr.Action = v1.PatchAction_PATCH_ACTION_DELETE
+	case fp.oldPath != fp.newPath:
+		fr.Action = v1.PatchAction_PATCH_ACTION_RENAME
+	}
+	if fp.binary {
+		fr.Error, fr.Code = "不支持二进制补丁", codeUnsupported
+		return fr
+	}
+
+	var src, dst *patchTarget
+	var err error
+	if fp.oldPath != "" {
+		if src, err = p.target(fp.oldPath, accessRead|accessWrite); err != nil {
+			fr.Error, fr.Code = err.Error(), errorCode(err)
+			return fr
+		}
+		fr.OldPath = src.path
+		if !src.exists {
+			fr.Error, fr.Code = fmt.Sprintf("文件不存在: %s", src.path), codeNotFound
+			return fr
+		}
+	}
+	if fp.newPath != "" {
+		if dst, err = p.target(fp.newPath, accessWrite); err != nil {
+			fr.Error, fr.Code = err.Error(), errorCode(err)
+			return fr
+		}
+		fr.NewPath = dst.path
+		if dst != src && dst.exists {
+			fr.Error, fr.Code = fmt.Sprintf("文件已存在: %s", dst.path), codeAlreadyExists
+			return fr
+		}
+	}
+
+	var content string
+	if src != nil {
+		content = string(src.content)
+	}
+	newContent, hunks, ok := applyHunks(content, fp.hunks, p.maxFuzz)
+	fr.Hunks = hunks
+	if !ok {
+		fr.Code = codeConflict
+		return fr
+	}
+	if dst == nil && newContent != "" {
+		fr.Error, fr.Code = fmt.Sprintf("删除的文件内容与补丁不一致: %s", src.path), codeConflict
+		return fr
+	}
+
+	var oldName, newName string
+	if src != nil {
+		oldName = src.path
+	}
+	if dst != nil {
+		newName = dst.path
+	}
+	fr.Change = fileChange(oldName, newName, []byte(content), []byte(newContent))
+
+	if src != nil && src != dst {
+		src.exists, src.content = false, nil
+	}
+	if dst != nil {
+		dst.exists, dst.content = true, []byte(newContent)
+		switch {
+		case fp.mode != 0:
+			dst.mode = fp.mode
+		case src != nil && src != dst:
+			dst.mode = src.perm // 重命名保留原权限
+		}
+	}
+	return fr
+}
+
+// resolve 解析补丁中的路径（相对路径基于 baseDir）并检查权限
+func (p *patcher) resolve(name string, need pathAccess) (string, error) {
+	if !filepath.IsAbs(name) && p.baseDir != "" {
+		name = filepath.Join(p.baseDir, name)
+	}
+	path, err := p.d.resolvePath(name, need)
+	if err != nil {
+		return "", errorf(pathErrorCode(err), "%s", pathErrorMessage(name, err))
+	}
+	return path, nil
+}
+
+// lockTargets 按路径顺序锁住补丁涉及的全部文件，返回解锁函数。
+// 无法解析的路径不加锁，应用时由 target 报告错误。
+func (p *patcher) lockTargets(patches []*filePatch) (unlock func()) {
+	var paths []string
+	for _, fp := range patches {
+		for _, name := range []string{fp.oldPath, fp.newPath} {
+			if name == "" {
+				continue
+			}
+			if path, err := p.resolve(name, 0); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	p.locked = make(map[string]bool, len(paths))
+	for _, path := range paths {
+		p.locked[path] = true
+	}
+	return p.d.fileLocks.lockAll(paths)
+}
+
+// target 解析补丁中的路径并加载文件当前的内容
+func (p *patcher) target(name string, need pathAccess) (*patchTarget, error) {
+	path, err := p.resolve(name, need)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.targets[path]; t != nil {
+		return t, nil
+	}
+	if !p.locked[path] {
+		// 加锁之后路径中的符号链接被改动，解析到了未加锁的文件
+		return nil, errorf(codeConflict, "路径在应用补丁期间发生变化: %s", name)
+	}
+
+	t := &patchTarget{path: path}
+	if info, err := os.Stat(path); err == nil {
+		if !info.Mode().IsRegular() {
+			return nil, errorf(codeInvalidArgument, "不是普通文件: %s", path)
+		}
+		if t.original, err = os.ReadFile(path); err != nil {
+			return nil, errorf(errorCode(err), "读取失败: %v", err)
+		}
+		t.existed, t.exists = true, true
+		t.perm = info.Mode().Perm()
+		t.content = t.original
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, errorf(errorCode(err), "读取失败: %v", err)
+	}
+	p.targets[path] = t
+	p.order = append(p.order, t)
+	return t, nil
+}
+
+// commit 把内存中的结果写入磁盘：先写入新建/修改的文件，再删除文件。
+// 中途失败时尽力恢复已写入和已删除的文件。
+func (p *patcher) commit() (err error) {
+	var written, removed []*patchTarget
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, t := range written {
+			if t.existed {
+				_ = writeFileAtomic(t.path, t.original, 0)
+				_ = os.Chmod(t.path, t.perm)
+			} else {
+				_ = os.Remove(t.path)
+			}
+		}
+		for _, t := range removed {
+			_ = writeFileAtomic(t.path, t.original, t.perm)
+		}
+	}()
+
+	for _, t := range p.order {
+		if !t.exists || t.existed && t.mode == 0 && bytes.Equal(t.content, t.original) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(t.path, t.content, t.mode); err != nil {
+			return err
+		}
+		written = append(written, t)
+		if t.existed && t.mode != 0 {
+			if err := os.Chmod(t.path, t.mode); err != nil {
+				return err
+			}
+		}
+	}
+	for _, t := range p.order {
+		if t.existed && !t.exists {
+			if err := os.Remove(t.path); err != nil {
+				return err
+			}
+			removed = append(removed, t)
+		}
+	}
+	return nil
+}
+
+// sendPatchResult 发送补丁结果
+func (d *Daemon) sendPatchResult(requestID string, result *v1.PatchResult) {
+	if err := d.send(&v1.ConnectRequest{
+		RequestId: requestID,
+		Payload:   &v1.ConnectRequest_PatchResult{PatchResult: result},
+	}); err != nil {
+		log.Printf("[文件] 发送补丁结果失败: %v", err)
+	}
+}
+
+// parsePatch 解析 unified diff，支持 git diff 的扩展头（新建/删除/重命名/权限）
+func parsePatch(text string) ([]*filePatch, error) {
+	lines := strings.Split(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var files []*filePatch
+	var cur *filePatch
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			cur = &filePatch{git: true}
+			cur.oldPath, cur.newPath = stripPatchPrefix(parseGitDiffLine(line[len("diff --git "):]))
+			files = append(files, cur)
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if cur == nil || !cur.git || cur.header {
+				cur = &filePatch{}
+				files = append(files, cur)
+			}
+			cur.oldPath, cur.newPath = stripPatchPrefix(parsePatchPath(line[4:]), parsePatchPath(lines[i+1][4:]))
+			cur.header = true
+			i++
+		case strings.HasPrefix(line, "@@ "):
+			if cur == nil {
+				return nil, fmt.Errorf("第 %d 行: hunk 之前缺少文件头", i+1)
+			}
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			cur.hunks = append(cur.hunks, h)
+			i = next - 1
+		case cur == nil:
+			// 文件头之前的说明文字
+		case strings.HasPrefix(line, "new file mode "):
+			cur.oldPath = ""
+			cur.mode = parseGitMode(line[len("new file mode "):])
+		case strings.HasPrefix(line, "deleted file mode "):
+			cur.newPath = ""
+		case strings.HasPrefix(line, "new mode "):
+			cur.mode = parseGitMode(line[len("new mode "):])
+		case strings.HasPrefix(line, "rename from "):
+			cur.oldPath = parsePatchPath(line[len("rename from "):])
+		case strings.HasPrefix(line, "rename to "):
+			cur.newPath = parsePatchPath(line[len("rename to "):])
+		case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch":
+			cur.binary = true
+		}
+	}
+	return files, nil
+}
+
+// parseHunk 解析从第 i 行开始的 hunk，返回 hunk 之后的行号
+func parseHunk(lines []string, i int) (*hunk, int, error) {
+	m := hunkHeader.FindStringSubmatch(strings.TrimSuffix(lines[i], "\r"))
+	if m == nil {
+		return nil, 0, fmt.Errorf("第 %d 行: 无效的 hunk 头", i+1)
+	}
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	h := &hunk{}
+	h.oldStart, _ = strconv.Atoi(m[1])
+	h.oldCount = count(m[2])
+	h.newStart, _ = strconv.Atoi(m[3])
+	h.newCount = count(m[4])
+
+	oldSeen, newSeen := 0, 0
+	j := i + 1
+	for ; oldSeen < h.oldCount || newSeen < h.newCount; j++ {
+		if j >= len(lines) {
+			return nil, 0, fmt.Errorf("第 %d 行的 hunk 不完整", i+1)
+		}
+		l := strings.TrimSuffix(lines[j], "\r")
+		if l == "" {
+			l = " " // 有的编辑器会去掉空上下文行的前导空格
+		}
+		switch l[0] {
+		case ' ':
+			oldSeen++
+			newSeen++
+		case '-':
+			oldSeen++
+		case '+':
+			newSeen++
+		case '\\':
+			if len(h.lines) > 0 {
+				h.lines[len(h.lines)-1].noEOL = true
+			}
+			continue
+		default:
+			return nil, 0, fmt.Errorf("第 %d 行: 无效的 hunk 内容", j+1)
+		}
+		if oldSeen > h.oldCount || newSeen > h.newCount {
+			return nil, 0, fmt.Errorf("第 %d 行: hunk 的行数与头部不符", j+1)
+		}
+		h.lines = append(h.lines, hunkLine{op: l[0], text: l[1:]})
+	}
+	if j < len(lines) && strings.HasPrefix(lines[j], `\`) && len(h.lines) > 0 {
+		h.lines[len(h.lines)-1].noEOL = true
+		j++
+	}
+	return h, j, nil
+}
+
+// parseGitDiffLine 解析 "diff --git a/x b/y" 中的两个路径（含前缀）
+func parseGitDiffLine(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		if q, err := strconv.QuotedPrefix(s); err == nil {
+			return parsePatchPath(q), parsePatchPath(strings.TrimSpace(s[len(q):]))
+		}
+	}
+	if i := strings.Index(s, " b/"); i >= 0 {
+		return s[:i], parsePatchPath(s[i+1:])
+	}
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], parsePatchPath(s[i+1:])
+	}
+	return s, s
+}
+
+// parsePatchPath 解析 ---/+++ 行中的路径：去掉 tab 之后的时间戳，解开 git 的引号，/dev/null 返回空
+func parsePatchPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSuffix(s, "\r")
+	if strings.HasPrefix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			s = u
+		}
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// stripPatchPrefix 两个路径分别以 a/、b/ 开头（或为空）时去掉前缀
+func stripPatchPrefix(oldPath, newPath string) (string, string) {
+	if oldPath == "" && newPath == "" {
+		return "", ""
+	}
+	if (oldPath == "" || strings.HasPrefix(oldPath, "a/")) && (newPath == "" || strings.HasPrefix(newPath, "b/")) {
+		return strings.TrimPrefix(oldPath, "a/"), strings.TrimPrefix(newPath, "b/")
+	}
+	return oldPath, newPath
+}
+
+// parseGitMode 解析 git 的文件模式（如 100755），只保留权限位
+func parseGitMode(s string) fs.FileMode {
+	m, err := strconv.ParseUint(strings.TrimSpace(s), 8, 32)
+	if err != nil {
+		return 0
+	}
+	return fs.FileMode(m & 0o777)
+}
+
+// applyHunks 把 hunks 依次应用到 content。每个 hunk 先在预期位置精确匹配，
+// 再在全文件中就近寻找，再逐级忽略首尾上下文（最多 maxFuzz 行，负数视同 0）。
+// 有 hunk 无法应用时 ok 为 false，结果中给出原因。
+func applyHunks(content string, hunks []*hunk, maxFuzz int) (string, []*v1.HunkResult, bool) {
+	maxFuzz = max(maxFuzz, 0)
+	lines := splitLines(content)
+	keys := make([]string, len(lines))
+	for i, l := range lines {
+		keys[i] = trimEOL(l)
+	}
+	eol := lineEnding(lines)
+
+	type placement struct {
+		h           *hunk
+		pos         int // 去掉 fuzz 上下文后在原文件中的起始行（0-based）
+		lead, trail int
+	}
+	var places []placement
+	var results []*v1.HunkResult
+	ok := true
+	minPos, offset := 0, 0
+	for i, h := range hunks {
+		expected := h.oldStart - 1
+		if h.oldCount == 0 {
+			expected = h.oldStart // 纯插入：插在第 oldStart 行之后
+		}
+		res := &v1.HunkResult{Index: int32(i + 1)} //nolint:gosec // hunk 数来自单条消息，不会溢出
+		results = append(results, res)
+		pos, fuzz, lead, trail, found := locateHunk(keys, h, expected+offset, minPos, maxFuzz)
+		if !found {
+			res.Error = fmt.Sprintf("上下文不匹配（原第 %d 行附近）", h.oldStart)
+			ok = false
+			continue
+		}
+		start := pos - lead
+		offset = start - expected
+		res.Applied = true
+		res.Line = int32(start + 1) //nolint:gosec // 同上
+		res.Offset = int32(offset)  //nolint:gosec // 同上
+		res.Fuzz = int32(fuzz)      //nolint:gosec // 同上
+		places = append(places, placement{h: h, pos: pos, lead: lead, trail: trail})
+		minPos = pos + oldLen(h) - lead - trail
+	}
+	if !ok {
+		return "", results, false
+	}
+
+	var b lineBuilder
+	b.eol = eol
+	cur := 0
+	for _, p := range places {
+		for _, l := range lines[cur:p.pos] {
+			b.add(l)
+		}
+		k := p.pos
+		for _, l := range p.h.lines[p.lead : len(p.h.lines)-p.trail] {
+			switch l.op {
+			case ' ':
+				b.add(lines[k])
+				k++
+			case '-':
+				k++
+			case '+':
+				if l.noEOL {
+					b.add(l.text)
+				} else {
+					b.add(l.text + eol)
+				}
+			}
+		}
+		cur = k
+	}
+	for _, l := range lines[cur:] {
+		b.add(l)
+	}
+	return b.String(), results, true
+}
+
+// locateHunk 寻找 hunk 在文件中的位置，返回去掉 lead/trail 行上下文后的起始行
+func locateHunk(keys []string, h *hunk, want, minPos, maxFuzz int) (pos, fuzz, lead, trail int, ok bool) {
+	var old []string
+	for _, l := range h.lines {
+		if l.op != '+' {
+			old = append(old, trimEOL(l.text))
+		}
+	}
+	if len(old) == 0 {
+		return min(max(want, minPos), len(keys)), 0, 0, 0, true
+	}
+
+	leadCtx, trailCtx := 0, 0
+	for _, l := range h.lines {
+		if l.op != ' ' {
+			break
+		}
+		leadCtx++
+	}
+	for i := len(h.lines) - 1; i >= 0 && h.lines[i].op == ' '; i-- {
+		trailCtx++
+	}
+
+	for fuzz = 0; fuzz <= maxFuzz; fuzz++ {
+		if fuzz > max(leadCtx, trailCtx) {
+			break // 没有更多上下文可以忽略
+		}
+		lead, trail = min(fuzz, leadCtx), min(fuzz, trailCtx)
+		pattern := old[lead : len(old)-trail]
+		if len(pattern) == 0 {
+			break
+		}
+		if pos, ok = searchLines(keys, pattern, want+lead, minPos); ok {
+			return pos, fuzz, lead, trail, true
+		}
+	}
+	return 0, 0, 0, 0, false
+}
+
+// searchLines 从 start 开始向两侧交替寻找 pattern，不早于 minPos
+func searchLines(keys, pattern []string, start, minPos int) (int, bool) {
+	maxPos := len(keys) - len(pattern)
+	if maxPos < minPos {
+		return 0, false
+	}
+	start = min(max(start, minPos), maxPos)
+	match := func(p int) bool {
+		for i, l := range pattern {
+			if keys[p+i] != l {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; start-d >= minPos || start+d <= maxPos; d++ {
+		if p := start - d; p >= minPos && match(p) {
+			return p, true
+		}
+		if p := start + d; d > 0 && p <= maxPos && match(p) {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// oldLen 返回 hunk 在原文件中占的行数
+func oldLen(h *hunk) int {
+	n := 0
+	for _, l := range h.lines {
+		if l.op != '+' {
+			n++
+		}
+	}
+	return n
+}
+
+// splitLines 按行切分，每行保留换行符
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEnding 返回文件使用的换行符，以第一行为准
+func lineEnding(lines []string) string {
+	if len(lines) > 0 && strings.HasSuffix(lines[0], "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// trimEOL 去掉行尾的 \n 或 \r\n
+func trimEOL(s string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+}
+
+// lineBuilder 拼接行，前一行没有换行符时在追加下一行前补上
+type lineBuilder struct {
+	strings.Builder
+	eol     string
+	pending bool // 最后一行没有换行符
+}
+
+func (b *lineBuilder) add(line string) {
+	if line == "" {
+		return
+	}
+	if b.pending {
+		b.WriteString(b.eol)
+	}
+	b.WriteString(line)
+	b.pending = !strings.HasSuffix(line, "\n")
+}
@@ -0,0 +1,353 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+func TestParsePatch(t *testing.T) {
+	patch := `--- plain.txt	2024-01-01 00:00:00
++++ plain.txt	2024-01-02 00:00:00
+@@ -1 +1 @@
+-x
++y
+diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var a = 1
++var a = 2
+
+diff --git a/new.sh b/new.sh
+new file mode 100755
+--- /dev/null
++++ b/new.sh
+@@ -0,0 +1 @@
++echo hi
+\ No newline at end of file
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/from.go b/to.go
+similarity index 100%
+rename from from.go
+rename to to.go
+diff --git "a/with space.txt" "b/with space.txt"
+old mode 100644
+new mode 100755
+`
+	files, err := parsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		oldPath, newPath string
+		hunks            int
+		mode             os.FileMode
+	}{
+		{"plain.txt", "plain.txt", 1, 0},
+		{"main.go", "main.go", 1, 0},
+		{"", "new.sh", 1, 0o755},
+		{"old.txt", "", 1, 0},
+		{"from.go", "to.go", 0, 0},
+		{"with space.txt", "with space.txt", 0, 0o755},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("解析出 %d 个文件，期望 %d", len(files), len(want))
+	}
+	for i, w := range want {
+		f := files[i]
+		if f.oldPath != w.oldPath || f.newPath != w.newPath || len(f.hunks) != w.hunks || f.mode != w.mode {
+			t.Errorf("文件 %d = %q -> %q (%d hunks, mode %v)，期望 %q -> %q (%d hunks, mode %v)",
+				i, f.oldPath, f.newPath, len(f.hunks), f.mode, w.oldPath, w.newPath, w.hunks, w.mode)
+		}
+	}
+	if h := files[1].hunks[0]; len(h.lines) != 4 || h.lines[3].op != ' ' || h.lines[3].text != "" {
+		t.Errorf("去掉前导空格的空上下文行未被识别: %+v", h.lines)
+	}
+	if l := files[2].hunks[0].lines[0]; !l.noEOL {
+		t.Error("未识别 \\ No newline at end of file")
+	}
+
+	for _, bad := range []string{
+		"@@ -1 +1 @@\n-a\n+b\n",               // 缺少文件头
+		"--- a\n+++ b\n@@ -1,2 +1,2 @@\n-a\n", // hunk 不完整
+		"--- a\n+++ b\n@@ -1 +1 @@\n*a\n",     // 无效行
+	} {
+		if _, err := parsePatch(bad); err == nil {
+			t.Errorf("parsePatch(%q) 应失败", bad)
+		}
+	}
+}
+
+func TestApplyHunks(t *testing.T) {
+	hunks := func(t *testing.T, patch string) []*hunk {
+		t.Helper()
+		files, err := parsePatch("--- a/f\n+++ b/f\n" + patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return files[0].hunks
+	}
+	numbered := func(n int) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			b.WriteString("line" + string(rune('0'+i%10)) + "\n")
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		patch    string
+		maxFuzz  int
+		want     string
+		offset   int32
+		fuzz     int32
+		rejected bool
+	}{
+		{
+			name:    "精确匹配",
+			content: "a\nb\nc\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "位置偏移",
+			content: "x\ny\na\nb\nc\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "x\ny\na\nB\nc\n",
+			offset:  2,
+		},
+		{
+			name:    "忽略不匹配的上下文",
+			content: "a\nb\nC\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			maxFuzz: 1,
+			want:    "a\nB\nC\n",
+			fuzz:    1,
+		},
+		{
+			name:     "不允许 fuzz 时拒绝",
+			content:  "a\nb\nC\n",
+			patch:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			rejected: true,
+		},
+		{
+			name:    "负数 max_fuzz 仍可精确匹配",
+			content: "x\na\nb\nc\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			maxFuzz: -1,
+			want:    "x\na\nB\nc\n",
+			offset:  1,
+		},
+		{
+			name:     "负数 max_fuzz 不忽略上下文",
+			content:  "a\nb\nC\n",
+			patch:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			maxFuzz:  -1,
+			rejected: true,
+		},
+		{
+			name:     "删除的行不匹配",
+			content:  "a\nx\nc\n",
+			patch:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			maxFuzz:  2,
+			rejected: true,
+		},
+		{
+			name:    "多个 hunk",
+			content: numbered(9),
+			patch:   "@@ -1,2 +1,2 @@\n-line1\n+first\n line2\n@@ -8,2 +8,3 @@\n line8\n+extra\n line9\n",
+			want:    strings.Replace(strings.Replace(numbered(9), "line1\n", "first\n", 1), "line8\n", "line8\nextra\n", 1),
+		},
+		{
+			name:    "保留 CRLF",
+			content: "a\r\nb\r\n",
+			patch:   "@@ -1,2 +1,3 @@\n a\n-b\n+B\n+c\n",
+			want:    "a\r\nB\r\nc\r\n",
+		},
+		{
+			name:    "去掉末尾换行",
+			content: "a\nb\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+			want:    "a\nb",
+		},
+		{
+			name:    "补上末尾换行",
+			content: "a\nb",
+			patch:   "@@ -1,2 +1,3 @@\n a\n-b\n\\ No newline at end of file\n+b\n+c\n",
+			want:    "a\nb\nc\n",
+		},
+		{
+			name:    "新建文件",
+			content: "",
+			patch:   "@@ -0,0 +1,2 @@\n+a\n+b\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "在开头插入",
+			content: "b\n",
+			patch:   "@@ -0,0 +1 @@\n+a\n",
+			want:    "a\nb\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, results, ok := applyHunks(tt.content, hunks(t, tt.patch), tt.maxFuzz)
+			if tt.rejected {
+				if ok {
+					t.Fatalf("应被拒绝，得到 %q", got)
+				}
+				if results[0].Applied || results[0].Error == "" {
+					t.Fatalf("hunk 结果 = %+v，期望带原因的拒绝", results[0])
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("应用失败: %+v", results)
+			}
+			if got != tt.want {
+				t.Fatalf("结果 = %q，期望 %q", got, tt.want)
+			}
+			if results[0].Offset != tt.offset || results[0].Fuzz != tt.fuzz {
+				t.Fatalf("offset/fuzz = %d/%d，期望 %d/%d", results[0].Offset, results[0].Fuzz, tt.offset, tt.fuzz)
+			}
+		})
+	}
+}
+
+func TestPatcherAllOrNothing(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	write("a.txt", "a\n")
+	write("b.txt", "b\n")
+	write("gone.txt", "bye\n")
+
+	apply := func(patch string) ([]*v1.PatchFileResult, error) {
+		files, err := parsePatch(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := &patcher{
+			d:       &Daemon{config: Config{AllowedPaths: []string{dir}}},
+			baseDir: dir,
+			targets: map[string]*patchTarget{},
+		}
+		defer p.lockTargets(files)()
+		results, ok := p.applyAll(files)
+		if !ok {
+			return results, os.ErrInvalid
+		}
+		return results, p.commit()
+	}
+
+	// 第二个文件的 hunk 不匹配：第一个文件也不能被修改
+	results, err := apply("--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+A\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-x\n+X\n")
+	if err == nil {
+		t.Fatal("应失败")
+	}
+	if !results[0].Hunks[0].Applied || results[1].Hunks[0].Applied || results[1].Code != codeConflict {
+		t.Fatalf("hunk 结果不符: %+v", results)
+	}
+	if read("a.txt") != "a\n" {
+		t.Fatal("失败时不应修改任何文件")
+	}
+
+	// 路径逃逸
+	if _, err := apply("--- /dev/null\n+++ b/../escape.txt\n@@ -0,0 +1 @@\n+x\n"); err == nil {
+		t.Fatal("白名单外的路径应被拒绝")
+	}
+
+	// 修改、新建、删除、重命名一起成功
+	_, err = apply(`--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-a
++A
+--- /dev/null
++++ b/sub/new.txt
+@@ -0,0 +1 @@
++new
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/b.txt b/c.txt
+rename from b.txt
+rename to c.txt
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"a.txt":       "A\n",
+		"sub/new.txt": "new\n",
+		"gone.txt":    "<missing>",
+		"b.txt":       "<missing>",
+		"c.txt":       "b\n",
+	} {
+		if got := read(name); got != want {
+			t.Errorf("%s = %q，期望 %q", name, got, want)
+		}
+	}
+}
+
+func TestApplyPatchHoldsPathLocks(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	path := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(path, []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个写入持有该文件的锁时，补丁等它写完再读取，基于写入后的内容判断能否应用
+	unlock := d.fileLocks.lock(path)
+	hub.down <- &v1.ConnectResponse{RequestId: "p", Payload: &v1.ConnectResponse_ApplyPatch{ApplyPatch: &v1.ApplyPatchRequest{
+		Patch: "--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-a\n+A\n", BaseDir: dir,
+	}}}
+	time.Sleep(100 * time.Millisecond)
+	if msgs := hub.messages("p"); len(msgs) != 0 {
+		t.Fatal("持有路径锁时补丁不应完成")
+	}
+	if err := os.WriteFile(path, []byte("b\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+
+	msgs := hub.wait(t, "p", func(m *v1.ConnectRequest) bool { return m.GetPatchResult() != nil })
+	if r := msgs[0].GetPatchResult(); r.Success || r.Code != codeConflict {
+		t.Fatalf("success=%v code=%v (%s)", r.Success, r.Code, r.Error)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "b\n" {
+		t.Fatalf("其他写入的内容被覆盖: %q", data)
+	}
+}
@@ -0,0 +1,298 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+	"github.com/epiral/cli/internal/config"
+)
+
+// maxSymlinkDepth 是解析符号链接的最大跳数，超过视为循环
+const maxSymlinkDepth = 40
+
+// pathAccess 是路径权限的位集合，0 表示禁止访问
+type pathAccess uint8
+
+const (
+	accessRead pathAccess = 1 << iota
+	accessWrite
+	accessExec
+
+	accessAll = accessRead | accessWrite | accessExec
+)
+
+func (a pathAccess) String() string {
+	var names []string
+	for _, p := range []struct {
+		bit  pathAccess
+		name string
+	}{{accessRead, config.AccessRead}, {accessWrite, config.AccessWrite}, {accessExec, config.AccessExec}} {
+		if a&p.bit != 0 {
+			names = append(names, p.name)
+		}
+	}
+	if len(names) == 0 {
+		return config.AccessDeny
+	}
+	return strings.Join(names, "+")
+}
+
+// parseAccess 把配置中的权限名转为位集合，未知名称忽略（配置加载时已校验）
+func parseAccess(names []string) pathAccess {
+	var a pathAccess
+	for _, n := range names {
+		switch n {
+		case config.AccessRead:
+			a |= accessRead
+		case config.AccessWrite:
+			a |= accessWrite
+		case config.AccessExec:
+			a |= accessExec
+		}
+	}
+	return a
+}
+
+// pathRule 是一条生效的路径权限
+type pathRule struct {
+	path   string
+	access pathAccess
+}
+
+var (
+	// errPathNotAllowed 表示路径不在任何规则内，或命中 deny 规则
+	errPathNotAllowed = errors.New("路径不允许")
+	// errPathPermission 表示路径可访问，但缺少本次操作需要的权限
+	errPathPermission = errors.New("路径权限不足")
+)
+
+// pathRules 合并 AllowedPaths（全部权限）与按路径细分的规则，为空表示不限制
+func (d *Daemon) pathRules() []pathRule {
+	rules := make([]pathRule, 0, len(d.config.AllowedPaths)+len(d.config.PathRules))
+	for _, p := range d.config.AllowedPaths {
+		rules = append(rules, pathRule{path: p, access: accessAll})
+	}
+	for _, r := range d.config.PathRules {
+		rules = append(rules, pathRule{path: r.Path, access: parseAccess(r.Access)})
+	}
+	return rules
+}
+
+// reachablePaths 返回可以访问（非 deny）的路径，填入 Registration.allowed_paths 供旧版 Agent 使用
+func (d *Daemon) reachablePaths() []string {
+	var paths []string
+	for _, r := range d.pathRules() {
+		if r.access != 0 {
+			paths = append(paths, r.path)
+		}
+	}
+	return paths
+}
+
+// pathPermissions 返回上报给 Agent 的路径权限
+func (d *Daemon) pathPermissions() []*v1.PathPermission {
+	rules := d.pathRules()
+	perms := make([]*v1.PathPermission, 0, len(rules))
+	for _, r := range rules {
+		perms = append(perms, &v1.PathPermission{
+			Path:  r.path,
+			Read:  r.access&accessRead != 0,
+			Write: r.access&accessWrite != 0,
+			Exec:  r.access&accessExec != 0,
+		})
+	}
+	return perms
+}
+
+// resolvePath 把请求中的路径规范化为真实的绝对路径，并检查是否具有 need 权限。
+// 返回的路径已解析符号链接，后续操作应使用它而不是原始路径。
+func (d *Daemon) resolvePath(path string, need pathAccess) (string, error) {
+	return resolveAllowedPath(path, d.pathRules(), need)
+}
+
+// resolveEntry 与 resolvePath 相同，但不解析最后一级的符号链接：
+// 删除、移动、stat 等操作针对的是链接本身而不是它指向的目标。
+func (d *Daemon) resolveEntry(path string, need pathAccess) (string, error) {
+	return resolveAllowedEntry(path, d.pathRules(), need)
+}
+
+// checkSubtree 检查 root 之下是否有缺少 need 权限的规则（如只读或 deny 的子目录），
+// 用于递归删除、移动、复制整个目录前确认不会波及受保护的路径
+func (d *Daemon) checkSubtree(root string, need pathAccess) error {
+	for _, r := range canonicalRules(d.pathRules()) {
+		if r.path != root && isWithin(root, r.path) && r.access&need != need {
+			return fmt.Errorf("%w（%s 下的 %s 仅允许 %s）", errPathPermission, root, r.path, r.access)
+		}
+	}
+	return nil
+}
+
+// pathErrorMessage 生成路径校验失败时返回给 Agent 的说明
+func pathErrorMessage(path string, err error) string {
+	if errors.Is(err, errPathNotAllowed) || errors.Is(err, errPathPermission) {
+		return fmt.Sprintf("%v: %s", err, path)
+	}
+	return fmt.Sprintf("路径无效: %v", err)
+}
+
+// pathErrorCode 返回路径校验失败时的错误码，与 pathErrorMessage 配合使用
+func pathErrorCode(err error) v1.ErrorCode {
+	if code := errorCode(err); code != codeInternal {
+		return code
+	}
+	return codeInvalidArgument
+}
+
+// resolveAllowedPath 规范化 path，找到路径最长的匹配规则并检查是否包含 need 权限。
+// 相对路径相对于用户主目录；已存在的部分逐级解析符号链接，
+// 不存在的部分（新文件）以最深的已存在父目录为准。rules 为空时不限制。
+// 同一路径有多条规则时取权限的交集。
+func resolveAllowedPath(path string, rules []pathRule, need pathAccess) (string, error) {
+	if path == "" {
+		return "", errors.New("路径为空")
+	}
+	resolved, err := canonicalPath(path)
+	if err != nil {
+		return "", err
+	}
+	return resolved, checkAccess(resolved, rules, need)
+}
+
+// resolveAllowedEntry 规范化 path 的父目录，最后一级保持原样（不跟随符号链接），再检查权限
+func resolveAllowedEntry(path string, rules []pathRule, need pathAccess) (string, error) {
+	if path == "" {
+		return "", errors.New("路径为空")
+	}
+	if !filepath.IsAbs(path) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("无法解析相对路径 %s: %w", path, err)
+		}
+		path = filepath.Join(home, path)
+	}
+	path = filepath.Clean(path)
+	parent, name := filepath.Dir(path), filepath.Base(path)
+	if parent == path {
+		// 根目录
+		return resolveAllowedPath(path, rules, need)
+	}
+	realParent, err := resolveSymlinks(parent, 0)
+	if err != nil {
+		return "", err
+	}
+	resolved := filepath.Join(realParent, name)
+	return resolved, checkAccess(resolved, rules, need)
+}
+
+// checkAccess 检查规范化路径 resolved 是否具有 need 权限，rules 为空时不限制
+func checkAccess(resolved string, rules []pathRule, need pathAccess) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	matched, access := accessFor(resolved, canonicalRules(rules))
+	if matched == "" || access == 0 {
+		return errPathNotAllowed
+	}
+	if missing := need &^ access; missing != 0 {
+		return fmt.Errorf("%w（需要 %s，%s 仅允许 %s）", errPathPermission, missing, matched, access)
+	}
+	return nil
+}
+
+// canonicalRules 返回路径已规范化的规则副本，无法解析的规则忽略
+func canonicalRules(rules []pathRule) []pathRule {
+	out := make([]pathRule, 0, len(rules))
+	for _, r := range rules {
+		if r.path == "" {
+			continue
+		}
+		root, err := canonicalPath(r.path)
+		if err != nil {
+			continue
+		}
+		out = append(out, pathRule{path: root, access: r.access})
+	}
+	return out
+}
+
+// accessFor 返回规范化路径 resolved 命中的最长规则及其权限，未命中时 matched 为空。
+// rules 须已经过 canonicalRules。
+func accessFor(resolved string, rules []pathRule) (matched string, access pathAccess) {
+	for _, r := range rules {
+		if !isWithin(r.path, resolved) {
+			continue
+		}
+		switch {
+		case matched == "" || len(r.path) > len(matched):
+			matched, access = r.path, r.access
+		case r.path == matched:
+			access &= r.access
+		}
+	}
+	return matched, access
+}
+
+// canonicalPath 返回 path 的绝对、已清理且已解析符号链接的形式
+func canonicalPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("无法解析相对路径 %s: %w", path, err)
+		}
+		path = filepath.Join(home, path)
+	}
+	return resolveSymlinks(filepath.Clean(path), 0)
+}
+
+// resolveSymlinks 解析 path 中的符号链接。path 不存在时解析其最深的已存在父目录，
+// 悬空的符号链接按其目标继续解析，避免经由它在允许目录外创建文件。
+func resolveSymlinks(path string, depth int) (string, error) {
+	if depth > maxSymlinkDepth {
+		return "", fmt.Errorf("符号链接层数过多: %s", path)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	info, lerr := os.Lstat(path)
+	switch {
+	case lerr == nil && info.Mode()&fs.ModeSymlink != 0:
+		// 悬空链接：沿着目标继续
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		return resolveSymlinks(filepath.Clean(target), depth+1)
+	case lerr != nil && !errors.Is(lerr, fs.ErrNotExist):
+		return "", lerr
+	}
+
+	// 不存在：解析父目录后拼回最后一段
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	realParent, err := resolveSymlinks(parent, depth)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realParent, filepath.Base(path)), nil
+}
+
+// isWithin 判断 path 是否是 root 本身或位于其下（两者均为规范化后的绝对路径）
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
@@ -0,0 +1,334 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pathFixture 在临时目录中搭建:
+//
+//	allowed/
+//	  file.txt
+//	  sub/
+//	  link-in   -> allowed/sub          （目录内链接）
+//	  link-out  -> outside              （逃逸链接）
+//	  file-out  -> outside/secret.txt   （逃逸的文件链接）
+//	  dangling  -> outside/new.txt      （悬空逃逸链接）
+//	  dangle-in -> sub/new.txt          （悬空内部链接，相对目标）
+//	  loop-a    -> loop-b，loop-b -> loop-a
+//	allowed-sibling/                    （前缀相同的兄弟目录）
+//	outside/
+//	  secret.txt
+//	root-link -> allowed                （允许目录本身经链接配置）
+func pathFixture(t *testing.T) string {
+	t.Helper()
+	// TempDir 自身可能位于符号链接下（如 macOS 的 /var），先规范化
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkdir := func(p string) {
+		if err := os.MkdirAll(filepath.Join(base, p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(p string) {
+		if err := os.WriteFile(filepath.Join(base, p), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(target, p string) {
+		if err := os.Symlink(target, filepath.Join(base, p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mkdir("allowed/sub")
+	mkdir("allowed-sibling")
+	mkdir("outside")
+	write("allowed/file.txt")
+	write("outside/secret.txt")
+	link(filepath.Join(base, "allowed/sub"), "allowed/link-in")
+	link(filepath.Join(base, "outside"), "allowed/link-out")
+	link(filepath.Join(base, "outside/secret.txt"), "allowed/file-out")
+	link(filepath.Join(base, "outside/new.txt"), "allowed/dangling")
+	link("sub/new.txt", "allowed/dangle-in")
+	link("loop-b", "allowed/loop-a")
+	link("loop-a", "allowed/loop-b")
+	link(filepath.Join(base, "allowed"), "root-link")
+	return base
+}
+
+// fullAccess 把路径列表转为全部权限的规则，等同于 AllowedPaths
+func fullAccess(paths ...string) []pathRule {
+	rules := make([]pathRule, 0, len(paths))
+	for _, p := range paths {
+		rules = append(rules, pathRule{path: p, access: accessAll})
+	}
+	return rules
+}
+
+func TestResolveAllowedPath(t *testing.T) {
+	base := pathFixture(t)
+	allowed := filepath.Join(base, "allowed")
+	abs := func(p string) string { return filepath.Join(base, p) }
+
+	tests := []struct {
+		name    string
+		path    string
+		allowed []string
+		want    string // 期望的规范化路径，空表示期望出错
+		denied  bool   // 期望 errPathNotAllowed（否则为其它错误）
+	}{
+		{name: "允许目录本身", path: allowed, want: allowed},
+		{name: "允许目录本身带尾斜杠", path: allowed + "/", want: allowed},
+		{name: "目录内已存在文件", path: abs("allowed/file.txt"), want: abs("allowed/file.txt")},
+		{name: "目录内新文件", path: abs("allowed/new.txt"), want: abs("allowed/new.txt")},
+		{name: "目录内多级新路径", path: abs("allowed/a/b/c.txt"), want: abs("allowed/a/b/c.txt")},
+		{name: "冗余分隔符和点", path: abs("allowed//sub/./x"), want: abs("allowed/sub/x")},
+		{name: "目录内回退", path: abs("allowed/sub/../file.txt"), want: abs("allowed/file.txt")},
+
+		{name: "上级回退逃逸", path: abs("allowed/../outside/secret.txt"), denied: true},
+		{name: "多级回退逃逸", path: abs("allowed/sub/../../outside"), denied: true},
+		{name: "回退到根", path: abs("allowed/../../../../../../etc/passwd"), denied: true},
+		{name: "前缀相同的兄弟目录", path: abs("allowed-sibling"), denied: true},
+		{name: "前缀相同的兄弟目录内文件", path: abs("allowed-sibling/x"), denied: true},
+		{name: "允许目录的父目录", path: base, denied: true},
+		{name: "无关路径", path: "/etc/passwd", denied: true},
+
+		{name: "目录内链接", path: abs("allowed/link-in/x"), want: abs("allowed/sub/x")},
+		{name: "链接逃逸到目录", path: abs("allowed/link-out"), denied: true},
+		{name: "经链接访问外部文件", path: abs("allowed/link-out/secret.txt"), denied: true},
+		{name: "经链接在外部建新文件", path: abs("allowed/link-out/new.txt"), denied: true},
+		{name: "文件链接逃逸", path: abs("allowed/file-out"), denied: true},
+		{name: "悬空链接逃逸", path: abs("allowed/dangling"), denied: true},
+		{name: "悬空链接指向目录内", path: abs("allowed/dangle-in"), want: abs("allowed/sub/new.txt")},
+		{name: "链接后回退", path: abs("allowed/link-in/../file.txt"), want: abs("allowed/file.txt")},
+
+		{name: "允许目录经链接配置", path: abs("allowed/file.txt"), allowed: []string{abs("root-link")}, want: abs("allowed/file.txt")},
+		{name: "经链接路径访问允许目录", path: abs("root-link/file.txt"), want: abs("allowed/file.txt")},
+		{name: "允许目录带尾斜杠配置", path: abs("allowed/file.txt"), allowed: []string{allowed + "/"}, want: abs("allowed/file.txt")},
+		{name: "允许目录含回退配置", path: abs("allowed/file.txt"), allowed: []string{abs("outside/../allowed")}, want: abs("allowed/file.txt")},
+		{name: "多个允许目录", path: abs("outside/secret.txt"), allowed: []string{allowed, abs("outside")}, want: abs("outside/secret.txt")},
+		{name: "根目录允许一切", path: abs("outside/secret.txt"), allowed: []string{"/"}, want: abs("outside/secret.txt")},
+		{name: "不限制时仍规范化", path: abs("allowed/link-in/../x"), allowed: []string{}, want: abs("allowed/x")},
+		{name: "不限制时解析链接", path: abs("allowed/link-out/secret.txt"), allowed: []string{}, want: abs("outside/secret.txt")},
+
+		{name: "空路径", path: ""},
+		{name: "链接循环", path: abs("allowed/loop-a")},
+		{name: "文件当作目录", path: abs("allowed/file.txt/x")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := tt.allowed
+			if roots == nil {
+				roots = []string{allowed}
+			}
+			got, err := resolveAllowedPath(tt.path, fullAccess(roots...), accessAll)
+			switch {
+			case tt.want != "":
+				if err != nil {
+					t.Fatalf("resolveAllowedPath(%q) 出错: %v", tt.path, err)
+				}
+				if got != tt.want {
+					t.Fatalf("resolveAllowedPath(%q) = %q，期望 %q", tt.path, got, tt.want)
+				}
+			case tt.denied:
+				if !errors.Is(err, errPathNotAllowed) {
+					t.Fatalf("resolveAllowedPath(%q) = %q, %v，期望路径不允许", tt.path, got, err)
+				}
+			default:
+				if err == nil || errors.Is(err, errPathNotAllowed) {
+					t.Fatalf("resolveAllowedPath(%q) = %q, %v，期望解析错误", tt.path, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveAllowedPathRelative(t *testing.T) {
+	base := pathFixture(t)
+	t.Setenv("HOME", base)
+
+	tests := []struct {
+		name    string
+		path    string
+		allowed []string
+		want    string
+		denied  bool
+	}{
+		{name: "相对主目录", path: "allowed/file.txt", allowed: []string{filepath.Join(base, "allowed")}, want: filepath.Join(base, "allowed/file.txt")},
+		{name: "相对路径回退逃逸", path: "allowed/../outside", allowed: []string{filepath.Join(base, "allowed")}, denied: true},
+		{name: "相对的允许目录", path: filepath.Join(base, "allowed/file.txt"), allowed: []string{"allowed"}, want: filepath.Join(base, "allowed/file.txt")},
+		{name: "点号即主目录", path: ".", allowed: []string{base}, want: base},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveAllowedPath(tt.path, fullAccess(tt.allowed...), accessAll)
+			if tt.denied {
+				if !errors.Is(err, errPathNotAllowed) {
+					t.Fatalf("resolveAllowedPath(%q) = %q, %v，期望路径不允许", tt.path, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveAllowedPath(%q) = %q, %v，期望 %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveAllowedPathAccess(t *testing.T) {
+	base := pathFixture(t)
+	abs := func(p string) string { return filepath.Join(base, p) }
+
+	// allowed 全部权限，allowed/sub 只读，allowed/sub/scratch 可写，allowed/link-in（指向 sub）不单独配置，
+	// outside 禁止，base 仅可执行
+	rules := []pathRule{
+		{path: abs("allowed"), access: accessAll},
+		{path: abs("allowed/sub"), access: accessRead},
+		{path: abs("allowed/sub/scratch"), access: accessRead | accessWrite},
+		{path: abs("outside"), access: 0},
+		{path: base, access: accessExec},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		need pathAccess
+		err  error // nil 表示允许
+	}{
+		{name: "全部权限目录读", path: abs("allowed/file.txt"), need: accessRead},
+		{name: "全部权限目录写", path: abs("allowed/file.txt"), need: accessWrite},
+		{name: "全部权限目录执行", path: abs("allowed"), need: accessExec},
+		{name: "只读目录读", path: abs("allowed/sub/x"), need: accessRead},
+		{name: "只读目录本身", path: abs("allowed/sub"), need: accessRead},
+		{name: "只读目录写", path: abs("allowed/sub/x"), need: accessWrite, err: errPathPermission},
+		{name: "只读目录编辑", path: abs("allowed/sub/x"), need: accessRead | accessWrite, err: errPathPermission},
+		{name: "只读目录执行", path: abs("allowed/sub"), need: accessExec, err: errPathPermission},
+		{name: "只读目录内更长的可写规则", path: abs("allowed/sub/scratch/a/b"), need: accessWrite},
+		{name: "可写规则不含执行", path: abs("allowed/sub/scratch"), need: accessExec, err: errPathPermission},
+		{name: "经链接进入只读目录仍只读", path: abs("allowed/link-in/x"), need: accessWrite, err: errPathPermission},
+		{name: "经回退进入只读目录仍只读", path: abs("allowed/sub/scratch/../x"), need: accessWrite, err: errPathPermission},
+		{name: "禁止目录读", path: abs("outside/secret.txt"), need: accessRead, err: errPathNotAllowed},
+		{name: "经链接进入禁止目录", path: abs("allowed/link-out/secret.txt"), need: accessRead, err: errPathNotAllowed},
+		{name: "仅执行目录执行", path: abs("allowed-sibling"), need: accessExec},
+		{name: "仅执行目录读", path: abs("allowed-sibling/x"), need: accessRead, err: errPathPermission},
+		{name: "不在任何规则内", path: "/", need: accessRead, err: errPathNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolveAllowedPath(tt.path, rules, tt.need)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("resolveAllowedPath(%q, %v) 出错: %v", tt.path, tt.need, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("resolveAllowedPath(%q, %v) = %v，期望 %v", tt.path, tt.need, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestResolveAllowedPathDuplicateRules(t *testing.T) {
+	base := pathFixture(t)
+	allowed := filepath.Join(base, "allowed")
+	// AllowedPaths 给了全部权限，细分规则又把同一路径设为只读：取交集
+	rules := []pathRule{
+		{path: allowed, access: accessAll},
+		{path: allowed + "/", access: accessRead},
+	}
+	if _, err := resolveAllowedPath(filepath.Join(allowed, "file.txt"), rules, accessRead); err != nil {
+		t.Fatalf("读取应允许: %v", err)
+	}
+	if _, err := resolveAllowedPath(filepath.Join(allowed, "file.txt"), rules, accessWrite); !errors.Is(err, errPathPermission) {
+		t.Fatalf("写入应被拒绝，得到 %v", err)
+	}
+}
+
+func TestResolveAllowedEntry(t *testing.T) {
+	base := pathFixture(t)
+	allowed := filepath.Join(base, "allowed")
+	abs := func(p string) string { return filepath.Join(base, p) }
+
+	// 最后一级不跟随符号链接，上级目录仍解析
+	tests := []struct {
+		name   string
+		path   string
+		want   string
+		denied bool
+	}{
+		{name: "允许目录本身", path: allowed, want: allowed},
+		{name: "普通文件", path: abs("allowed/file.txt"), want: abs("allowed/file.txt")},
+		{name: "逃逸链接本身", path: abs("allowed/link-out"), want: abs("allowed/link-out")},
+		{name: "逃逸文件链接本身", path: abs("allowed/file-out"), want: abs("allowed/file-out")},
+		{name: "链接循环本身", path: abs("allowed/loop-a"), want: abs("allowed/loop-a")},
+		{name: "经目录内链接", path: abs("allowed/link-in/x"), want: abs("allowed/sub/x")},
+		{name: "经逃逸链接的子路径", path: abs("allowed/link-out/secret.txt"), denied: true},
+		{name: "回退逃逸", path: abs("allowed/../outside"), denied: true},
+		{name: "经链接路径访问允许目录", path: abs("root-link"), denied: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveAllowedEntry(tt.path, fullAccess(allowed), accessAll)
+			if tt.denied {
+				if !errors.Is(err, errPathNotAllowed) {
+					t.Fatalf("resolveAllowedEntry(%q) = %q, %v，期望路径不允许", tt.path, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveAllowedEntry(%q) = %q, %v，期望 %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := resolveAllowedEntry("", nil, accessAll); err == nil {
+		t.Fatal("空路径应出错")
+	}
+}
+
+func TestPathAccessString(t *testing.T) {
+	tests := []struct {
+		access pathAccess
+		want   string
+	}{
+		{0, "deny"},
+		{accessRead, "read"},
+		{accessRead | accessWrite, "read+write"},
+		{accessAll, "read+write+exec"},
+		{parseAccess([]string{"exec", "read"}), "read+exec"},
+		{parseAccess([]string{"deny"}), "deny"},
+	}
+	for _, tt := range tests {
+		if got := tt.access.String(); got != tt.want {
+			t.Errorf("pathAccess(%d).String() = %q，期望 %q", tt.access, got, tt.want)
+		}
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		root, path string
+		want       bool
+	}{
+		{"/a", "/a", true},
+		{"/a", "/a/b", true},
+		{"/a", "/a/b/c", true},
+		{"/a", "/ab", false},
+		{"/a", "/", false},
+		{"/a/b", "/a", false},
+		{"/", "/", true},
+		{"/", "/etc", true},
+		{"/a", "/a/..b", true}, // 以 .. 开头的普通文件名
+	}
+	for _, tt := range tests {
+		if got := isWithin(tt.root, tt.path); got != tt.want {
+			t.Errorf("isWithin(%q, %q) = %v，期望 %v", tt.root, tt.path, got, tt.want)
+		}
+	}
+}
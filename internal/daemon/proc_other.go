@@ -0,0 +1,20 @@
+//go:build !unix
+
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup 非 Unix 平台没有进程组，不做处理
+func setProcessGroup(*exec.Cmd) {}
+
+// signalProcessGroup 非 Unix 平台只能作用于直接子进程
+func signalProcessGroup(p *os.Process, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return p.Kill()
+	}
+	return p.Signal(sig)
+}
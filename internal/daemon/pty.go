@@ -0,0 +1,233 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+const (
+	defaultPTYCols  = 80
+	defaultPTYRows  = 24
+	ptyInputBacklog = 256  // 尚未写入终端的 ExecInput 上限
+	ptyEOF          = 0x04 // ^D，规范模式下行首输入即 EOF
+)
+
+// ptyTerminal 是交互式执行的终端（pty master 端）。
+// 收到 Exec 时即创建，终端启动前到达的输入先排队、窗口调整先记下，attach 后生效。
+// 输入经队列由单独的 goroutine 写入，接收循环只负责入队，不会被阻塞。
+type ptyTerminal struct {
+	input chan *v1.ExecInput
+	done  chan struct{}
+
+	overflow bool // 队列已满、正在丢弃输入（只由接收循环访问）
+
+	mu   sync.Mutex
+	tty  *os.File     // attach 之前为 nil
+	size *pty.Winsize // attach 之前收到的最后一次窗口调整
+}
+
+func newPTYTerminal() *ptyTerminal {
+	return &ptyTerminal{
+		input: make(chan *v1.ExecInput, ptyInputBacklog),
+		done:  make(chan struct{}),
+	}
+}
+
+// startSize 返回启动终端时的窗口大小：之前收到过窗口调整则用最后一次的，否则用 def
+func (t *ptyTerminal) startSize(def *pty.Winsize) *pty.Winsize {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	size := def
+	if t.size != nil {
+		size, t.size = t.size, nil
+	}
+	return size
+}
+
+// attach 接上已启动的终端：应用启动期间记下的窗口大小，开始写入排队的输入
+func (t *ptyTerminal) attach(tty *os.File) {
+	t.mu.Lock()
+	t.tty = tty
+	size := t.size
+	t.mu.Unlock()
+	if size != nil {
+		if err := pty.Setsize(tty, size); err != nil {
+			log.Printf("[终端] 调整窗口失败: %v", err)
+		}
+	}
+	go t.writeLoop()
+}
+
+// resize 调整窗口大小，终端尚未启动时记下，attach 时应用
+func (t *ptyTerminal) resize(size *pty.Winsize) error {
+	t.mu.Lock()
+	tty := t.tty
+	if tty == nil {
+		t.size = size
+	}
+	t.mu.Unlock()
+	if tty == nil {
+		return nil
+	}
+	return pty.Setsize(tty, size)
+}
+
+func (t *ptyTerminal) writeLoop() {
+	for {
+		select {
+		case <-t.done:
+			return
+		case in := <-t.input:
+			if len(in.Data) > 0 {
+				if _, err := t.tty.Write(in.Data); err != nil {
+					log.Printf("[终端] 写入失败: %v", err)
+					continue
+				}
+			}
+			if in.Eof {
+				if _, err := t.tty.Write([]byte{ptyEOF}); err != nil {
+					log.Printf("[终端] 写入 EOF 失败: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// enqueue 排入一条输入，终端已结束或队列已满时丢弃并返回 queued=false。
+// 队列满后第一次丢弃时 overflowed 为 true，之后连续丢弃的不再重复，直到再次入队成功。
+func (t *ptyTerminal) enqueue(in *v1.ExecInput) (queued, overflowed bool) {
+	select {
+	case <-t.done:
+		return false, false
+	default:
+	}
+	select {
+	case t.input <- in:
+		t.overflow = false
+		return true, false
+	default:
+		overflowed = !t.overflow
+		t.overflow = true
+		return false, overflowed
+	}
+}
+
+// close 停止写入 goroutine（终端文件由调用方关闭）
+func (t *ptyTerminal) close() {
+	close(t.done)
+}
+
+// withTerm 确保环境中有可用的 TERM，daemon 常以服务方式运行，没有或只有 dumb 终端
+func withTerm(env []string) []string {
+	for _, kv := range env {
+		if term, ok := strings.CutPrefix(kv, "TERM="); ok && term != "" && term != "dumb" {
+			return env
+		}
+	}
+	env = slices.DeleteFunc(env, func(kv string) bool { return strings.HasPrefix(kv, "TERM=") })
+	return append(env, "TERM=xterm-256color")
+}
+
+// ptySize 把请求的窗口大小转为 pty.Winsize，0 使用默认值
+func ptySize(cols, rows uint32) *pty.Winsize {
+	if cols == 0 {
+		cols = defaultPTYCols
+	}
+	if rows == 0 {
+		rows = defaultPTYRows
+	}
+	return &pty.Winsize{
+		Cols: uint16(min(cols, math.MaxUint16)), //nolint:gosec // 已限制在 uint16 范围内
+		Rows: uint16(min(rows, math.MaxUint16)), //nolint:gosec // 已限制在 uint16 范围内
+	}
+}
+
+// runPTY 在伪终端中执行命令，stdout/stderr 合并后流式上行，
+// 终端输入由 ExecInput / ExecResize 驱动。返回退出码。
+func (d *Daemon) runPTY(execCtx context.Context, req *v1.ExecRequest, workdir string, run *runningExec, out *execOutput) int32 {
+	// pty.Start 会让 shell 成为新会话的首进程（Setsid），进程组 ID 即其 pid，
+	// 因此不再单独设置进程组
+	cmd := exec.CommandContext(execCtx, d.shell(), "-c", req.Command)
+	cmd.Dir = workdir
+	cmd.Env = withTerm(d.buildEnv(req.InheritEnv, req.Env))
+	cmd.Cancel = func() error { return signalProcessGroup(cmd.Process, syscall.SIGKILL) }
+
+	term := run.terminal()
+	tty, err := pty.StartWithSize(cmd, term.startSize(ptySize(req.Cols, req.Rows)))
+	if err != nil {
+		out.done(errorCode(err), fmt.Sprintf("启动终端失败: %v", err), 1, workdir)
+		return 1
+	}
+	defer tty.Close()
+	term.attach(tty)
+	defer term.close()
+	exited := make(chan struct{})
+	run.setStop(func() { go terminateProcess(cmd.Process, exited) })
+
+	chunker := out.newChunker()
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		// 所有 slave 端关闭后读 master 返回 EIO，视为结束
+		_, _ = io.Copy(chunker.writer(streamStdout), tty)
+	}()
+
+	err = cmd.Wait()
+	close(exited)
+	// 后台进程可能仍持有终端，最多再等 pipeWaitDelay 读完剩余输出
+	select {
+	case <-readDone:
+	case <-time.After(pipeWaitDelay):
+		_ = tty.Close()
+		<-readDone
+	}
+	_ = chunker.Close()
+
+	exitCode, code, note := execResult(execCtx, cmd, err, run)
+	out.done(code, note, exitCode, workdir)
+	return exitCode
+}
+
+// handleExecInput 把输入排入目标终端。
+// 在接收循环中同步调用，保证按键顺序与到达顺序一致；不等待队列腾出空间，
+// 否则一个不读输入的命令会卡住所有请求。队列已满时丢弃输入，并以 UNAVAILABLE 状态告知 hub。
+func (d *Daemon) handleExecInput(requestID string, in *v1.ExecInput) {
+	run, ok := d.lookupExec(requestID)
+	if !ok || run.terminal() == nil {
+		log.Printf("[终端] 丢弃输入: %s 不是运行中的 pty 命令", requestID)
+		return
+	}
+	queued, overflowed := run.terminal().enqueue(in)
+	switch {
+	case overflowed:
+		log.Printf("[终端] 丢弃输入: %s 输入队列已满", requestID)
+		run.out.status(codeUnavailable, fmt.Sprintf("终端输入队列已满（%d 条未写入），之后的输入被丢弃，直到命令读走积压的输入", ptyInputBacklog))
+	case !queued:
+		log.Printf("[终端] 丢弃输入: %s 输入队列已满或已结束", requestID)
+	}
+}
+
+// handleExecResize 调整目标终端的窗口大小
+func (d *Daemon) handleExecResize(requestID string, req *v1.ExecResize) {
+	run, ok := d.lookupExec(requestID)
+	if !ok || run.terminal() == nil {
+		log.Printf("[终端] 忽略窗口调整: %s 不是运行中的 pty 命令", requestID)
+		return
+	}
+	if err := run.terminal().resize(ptySize(req.Cols, req.Rows)); err != nil {
+		log.Printf("[终端] 调整窗口失败: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+func TestPTYEarlyInput(t *testing.T) {
+	dir := t.TempDir()
+	_, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+
+	// 输入和窗口调整紧跟 Exec 下发，到达时终端多半还没启动，不能丢。
+	// 先 read 再 stty：读到输入时，先于它到达的窗口调整一定已生效
+	hub.down <- &v1.ConnectResponse{RequestId: "p", Payload: &v1.ConnectResponse_Exec{Exec: &v1.ExecRequest{
+		Command: "read line; echo got:$line; stty size", Workdir: dir, Pty: true,
+	}}}
+	hub.down <- &v1.ConnectResponse{RequestId: "p", Payload: &v1.ConnectResponse_ExecResize{ExecResize: &v1.ExecResize{Cols: 100, Rows: 40}}}
+	hub.down <- &v1.ConnectResponse{RequestId: "p", Payload: &v1.ConnectResponse_ExecInput{ExecInput: &v1.ExecInput{Data: []byte("hi\n")}}}
+
+	stdout, _, last := execResultOf(hub.wait(t, "p", execFinished))
+	if last.ExitCode != 0 {
+		t.Fatalf("exit=%d 输出 %q", last.ExitCode, stdout)
+	}
+	if !strings.Contains(stdout, "40 100") || !strings.Contains(stdout, "got:hi") {
+		t.Fatalf("输出 = %q，期望窗口 40x100 且读到输入", stdout)
+	}
+}
+
+func TestPTYInputOverflow(t *testing.T) {
+	d, hub, _ := startTestDaemon(t, Config{})
+	// 终端未启动，没有 goroutine 取走输入，排满队列后再发送的输入都被丢弃
+	run := d.trackExec("full", true)
+	defer d.untrackExec("full", run)
+	input := func(n int) {
+		for range n {
+			d.handleExecInput("full", &v1.ExecInput{Data: []byte("x")})
+		}
+	}
+	statuses := func() []*v1.ExecOutput {
+		var got []*v1.ExecOutput
+		for _, m := range hub.messages("full") {
+			got = append(got, m.GetExecOutput())
+		}
+		return got
+	}
+	isStatus := func(m *v1.ConnectRequest) bool { return m.GetExecOutput().GetCode() == codeUnavailable }
+
+	input(ptyInputBacklog)
+	input(3)
+	hub.wait(t, "full", isStatus)
+	// 连续丢弃只报告一次
+	time.Sleep(50 * time.Millisecond)
+	got := statuses()
+	if len(got) != 1 || got[0].Done || got[0].Stderr == "" || got[0].Seq != 1 {
+		t.Fatalf("状态消息 = %v，期望一条未结束的 UNAVAILABLE 说明", got)
+	}
+
+	// 队列腾出空间、再次入队成功后，重新满时再报告一次
+	<-run.terminal().input
+	input(2)
+	deadline := time.Now().Add(10 * time.Second)
+	for len(statuses()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("队列再次满时没有报告")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := statuses(); len(got) != 2 || got[1].Code != codeUnavailable || got[1].Seq != 2 {
+		t.Fatalf("状态消息 = %v", got)
+	}
+
+	// 命令结束后不再发送状态
+	run.out.done(codeNone, "", 0, "")
+	<-run.terminal().input
+	input(2)
+	time.Sleep(50 * time.Millisecond)
+	if got := statuses(); len(got) != 3 || !got[2].Done {
+		t.Fatalf("结束后仍发送了状态: %v", got)
+	}
+}
@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+var (
+	errCancelled    = errors.New("请求已取消")
+	errDisconnected = errors.New("连接已断开")
+)
+
+// inflightRequest 是一个进行中的可取消请求
+type inflightRequest struct {
+	cancel context.CancelCauseFunc
+}
+
+// cancellable 判断消息是否登记为可取消的请求：除取消、心跳、注册应答和终端输入等控制消息外，
+// 每个请求都登记。耗时的操作在处理过程中检查 ctx，修改文件的操作在写入前检查。
+func cancellable(msg *v1.ConnectResponse) bool {
+	if msg.RequestId == "" {
+		return false
+	}
+	switch msg.Payload.(type) {
+	case *v1.ConnectResponse_Cancel, *v1.ConnectResponse_Pong, *v1.ConnectResponse_RegistrationAck,
+		*v1.ConnectResponse_ExecInput, *v1.ConnectResponse_ExecResize:
+		return false
+	}
+	return true
+}
+
+// cancelled 在请求已取消或连接已断开时返回原因，否则返回 nil
+func cancelled(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// ctxReader 在 ctx 结束后让读取失败，用于中止大文件的复制和哈希
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := cancelled(r.ctx); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// beginRequest 为 msg 派生请求级 ctx 并按 request_id 登记，处理结束后调用返回的 done。
+// 在接收循环中同步调用，保证紧随其后的 CancelRequest 一定能找到它。
+func (d *Daemon) beginRequest(ctx context.Context, msg *v1.ConnectResponse) (reqCtx context.Context, done func()) {
+	if !cancellable(msg) {
+		return ctx, func() {}
+	}
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	r := &inflightRequest{cancel: cancel}
+	d.requestMu.Lock()
+	if d.requests == nil {
+		d.requests = make(map[string]*inflightRequest)
+	}
+	d.requests[msg.RequestId] = r
+	d.requestMu.Unlock()
+
+	return reqCtx, func() {
+		d.requestMu.Lock()
+		// 同一 request_id 可能已被新请求覆盖，只移除自己的登记
+		if d.requests[msg.RequestId] == r {
+			delete(d.requests, msg.RequestId)
+		}
+		d.requestMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelRequest 取消进行中的请求，未找到时返回 false
+func (d *Daemon) cancelRequest(requestID string) bool {
+	d.requestMu.Lock()
+	r, ok := d.requests[requestID]
+	d.requestMu.Unlock()
+	if ok {
+		r.cancel(errCancelled)
+	}
+	return ok
+}
+
+// handleCancel 取消进行中的请求。命令逐级终止后以 exit_code=130 结束，
+// 其余操作以各自的错误结果结束；取消请求本身以 OpResult 应答。
+func (d *Daemon) handleCancel(requestID string, req *v1.CancelRequest) {
+	if !d.cancelRequest(req.RequestId) {
+		log.Printf("[连接] 取消: 未找到进行中的请求 %s", req.RequestId)
+		d.sendOpResult(requestID, codeNotFound, fmt.Sprintf("未找到进行中的请求: %s", req.RequestId))
+		return
+	}
+	log.Printf("[连接] 取消请求 %s", req.RequestId)
+	d.sendOpResult(requestID, codeNone, "")
+}
@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+func TestRequestRegistry(t *testing.T) {
+	d := New(&Config{})
+	conn, disconnect := context.WithCancelCause(context.Background())
+	search := func(id string) *v1.ConnectResponse {
+		return &v1.ConnectResponse{RequestId: id, Payload: &v1.ConnectResponse_Search{Search: &v1.SearchRequest{}}}
+	}
+
+	// 按 request_id 取消
+	ctx, done := d.beginRequest(conn, search("a"))
+	if !d.cancelRequest("a") || !errors.Is(context.Cause(ctx), errCancelled) {
+		t.Fatalf("取消后 cause = %v", context.Cause(ctx))
+	}
+	done()
+	if d.cancelRequest("a") {
+		t.Fatal("结束后的请求不应能再取消")
+	}
+
+	// 控制消息不登记，其余请求都登记
+	if ctx, _ := d.beginRequest(conn, &v1.ConnectResponse{RequestId: "i", Payload: &v1.ConnectResponse_ExecInput{}}); ctx != conn || d.cancelRequest("i") {
+		t.Fatal("ExecInput 不应登记为可取消")
+	}
+	_, writeDone := d.beginRequest(conn, &v1.ConnectResponse{RequestId: "w", Payload: &v1.ConnectResponse_WriteFile{}})
+	if !d.cancelRequest("w") {
+		t.Fatal("WriteFile 应登记为可取消")
+	}
+	writeDone()
+
+	// 同一 request_id 被覆盖后，旧请求结束不影响新请求
+	_, oldDone := d.beginRequest(conn, search("b"))
+	newCtx, newDone := d.beginRequest(conn, search("b"))
+	oldDone()
+	if !d.cancelRequest("b") || newCtx.Err() == nil {
+		t.Fatal("新请求应仍可取消")
+	}
+	newDone()
+
+	// 断连取消所有进行中的请求
+	ctx1, done1 := d.beginRequest(conn, search("c"))
+	ctx2, done2 := d.beginRequest(conn, search("d"))
+	defer done1()
+	defer done2()
+	disconnect(errDisconnected)
+	for _, ctx := range []context.Context{ctx1, ctx2} {
+		if !errors.Is(context.Cause(ctx), errDisconnected) {
+			t.Fatalf("断连后 cause = %v", context.Cause(ctx))
+		}
+	}
+}
+
+func TestCancelledFileOps(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "f"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errCancelled)
+
+	if err := copyTree(ctx, src, filepath.Join(dir, "dst"), false); !errors.Is(err, errCancelled) {
+		t.Fatalf("copyTree: err = %v", err)
+	}
+	l := &dirLister{ctx: ctx, maxDepth: 2, limit: 10}
+	if err := l.walk(src, "", 1, nil); !errors.Is(err, errCancelled) {
+		t.Fatalf("walk: err = %v", err)
+	}
+
+	// 修改文件的请求在写入前被取消则不写入
+	d, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	path := filepath.Join(dir, "edit.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var result *v1.OpResult
+	d.editFile(ctx, "e", path, "", func(string) (string, error) { return "new", nil })
+	for _, m := range hub.wait(t, "e", hasOpResult) {
+		result = m.GetOpResult()
+	}
+	if result.Code != codeCancelled {
+		t.Fatalf("取消的编辑: %+v", result)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Fatalf("取消的编辑不应写回: %q", data)
+	}
+}
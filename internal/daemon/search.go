@@ -0,0 +1,298 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+const (
+	defaultSearchResults = 1000
+	defaultSearchBytes   = 1024 * 1024
+	maxSearchContext     = 10
+	maxSearchFileSize    = 10 * 1024 * 1024 // 更大的文件不搜索内容
+	maxSearchLineBytes   = 1024             // 单行超过此长度时截断
+	binarySniffBytes     = 8000             // 与 git 一致：前 8000 字节含 NUL 视为二进制
+
+	searchBatchSize  = 100                    // 攒够这么多结果即发送一批
+	searchBatchDelay = 200 * time.Millisecond // 或距上一批超过这么久
+)
+
+// errSearchDone 用于提前结束遍历（达到上限或连接断开）
+var errSearchDone = errors.New("search done")
+
+// searcher 执行一次搜索并分批上行结果
+type searcher struct {
+	d         *Daemon
+	requestID string
+
+	glob       *regexp.Regexp // 为 nil 表示所有文件
+	pattern    *regexp.Regexp // 为 nil 表示只按 glob 匹配
+	context    int
+	maxResults int
+	maxBytes   int64
+	rules      []pathRule // 已规范化的路径规则，为空表示不限制
+
+	batch     []*v1.SearchMatch
+	lastSend  time.Time
+	results   int
+	bytes     int64
+	files     int64
+	truncated bool
+	broken    bool // 发送失败（连接已断）
+}
+
+// handleSearch 搜索文件名和内容，流式返回结果
+func (d *Daemon) handleSearch(ctx context.Context, requestID string, req *v1.SearchRequest) {
+	log.Printf("[文件] 搜索 %s glob=%q pattern=%q", req.Path, req.Glob, req.Pattern)
+	fail := func(code v1.ErrorCode, msg string) {
+		d.sendSearchResult(requestID, &v1.SearchResult{Done: true, Error: msg, Code: code})
+	}
+	if req.Glob == "" && req.Pattern == "" {
+		fail(codeInvalidArgument, "glob 和 pattern 不能同时为空")
+		return
+	}
+	root, err := d.resolvePath(req.Path, accessRead)
+	if err != nil {
+		fail(pathErrorCode(err), pathErrorMessage(req.Path, err))
+		return
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		fail(errorCode(err), fmt.Sprintf("路径不存在: %s", root))
+		return
+	}
+
+	s := &searcher{
+		d:          d,
+		requestID:  requestID,
+		context:    min(max(int(req.ContextLines), 0), maxSearchContext),
+		maxResults: int(req.MaxResults),
+		maxBytes:   req.MaxBytes,
+		rules:      canonicalRules(d.pathRules()),
+		lastSend:   time.Now(),
+	}
+	if s.maxResults <= 0 {
+		s.maxResults = defaultSearchResults
+	}
+	if s.maxBytes <= 0 {
+		s.maxBytes = defaultSearchBytes
+	}
+	if req.Glob != "" {
+		if s.glob, err = compileGlob(req.Glob); err != nil {
+			fail(codeInvalidArgument, fmt.Sprintf("glob 无效: %v", err))
+			return
+		}
+	}
+	if req.Pattern != "" {
+		expr := req.Pattern
+		if req.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			fail(codeInvalidArgument, fmt.Sprintf("正则无效: %v", err))
+			return
+		}
+	}
+
+	start := time.Now()
+	if info.IsDir() {
+		err = s.walk(ctx, root, !req.NoGitignore)
+	} else {
+		s.visit(root, filepath.Base(root))
+	}
+	if err != nil && !errors.Is(err, errSearchDone) {
+		s.flush()
+		fail(errorCode(err), fmt.Sprintf("搜索失败: %v", err))
+		return
+	}
+	if s.broken {
+		return
+	}
+
+	log.Printf("[文件] 搜索完成: %d 个结果，%d 个文件 (%.1fs)", s.results, s.files, time.Since(start).Seconds())
+	s.flush()
+	d.sendSearchResult(requestID, &v1.SearchResult{
+		Done:          true,
+		Truncated:     s.truncated,
+		FilesSearched: s.files,
+	})
+}
+
+// walk 遍历 root 下的文件，不跟随符号链接，跳过被忽略或无读权限的路径
+func (s *searcher) walk(ctx context.Context, root string, useGitignore bool) error {
+	ignores := map[string]*gitignore{}
+	if useGitignore {
+		ignores[root] = newGitignore(root)
+	}
+	return filepath.WalkDir(root, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // 无权限的子目录等，跳过
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if path == root {
+			return nil
+		}
+
+		isDir := e.IsDir()
+		if useGitignore {
+			ignore := ignores[filepath.Dir(path)]
+			if isDir && e.Name() == ".git" || ignore.ignored(path, isDir) {
+				if isDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isDir {
+				ignores[path] = ignore.enter(path)
+			}
+		}
+		if !s.readable(path) {
+			if isDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !e.Type().IsRegular() {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		if s.visit(path, filepath.ToSlash(rel)) {
+			return errSearchDone
+		}
+		return nil
+	})
+}
+
+// readable 判断路径是否有读权限
+func (s *searcher) readable(path string) bool {
+	if len(s.rules) == 0 {
+		return true
+	}
+	_, access := accessFor(path, s.rules)
+	return access&accessRead != 0
+}
+
+// visit 搜索一个文件，返回 true 表示应停止搜索
+func (s *searcher) visit(path, rel string) bool {
+	if s.glob != nil && !s.glob.MatchString(rel) {
+		return false
+	}
+	if s.pattern == nil {
+		s.files++
+		return s.add(&v1.SearchMatch{Path: path})
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxSearchFileSize {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data[:min(len(data), binarySniffBytes)], 0) >= 0 {
+		return false
+	}
+	s.files++
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1] // 以换行结尾时 SplitAfter 多出的空串
+	}
+	for i, line := range lines {
+		loc := s.pattern.FindIndex(bytes.TrimRight(line, "\r\n"))
+		if loc == nil {
+			continue
+		}
+		m := &v1.SearchMatch{
+			Path:   path,
+			Line:   int32(i + 1),      //nolint:gosec // 文件不超过 maxSearchFileSize，行号不会溢出
+			Column: int32(loc[0] + 1), //nolint:gosec // 同上
+			Text:   searchLine(line),
+		}
+		for _, l := range lines[max(i-s.context, 0):i] {
+			m.Before = append(m.Before, searchLine(l))
+		}
+		for _, l := range lines[i+1 : min(i+1+s.context, len(lines))] {
+			m.After = append(m.After, searchLine(l))
+		}
+		if s.add(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchLine 去掉换行符，截断过长的行，并把非 UTF-8 字节替换为 U+FFFD
+func searchLine(line []byte) string {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) > maxSearchLineBytes {
+		line = trimPartialRune(line[:maxSearchLineBytes])
+	}
+	if !utf8.Valid(line) {
+		line = bytes.ToValidUTF8(line, []byte("\uFFFD"))
+	}
+	return string(line)
+}
+
+// add 加入一个结果，按数量/时间分批发送。返回 true 表示已达上限或连接断开。
+func (s *searcher) add(m *v1.SearchMatch) bool {
+	size := int64(len(m.Path) + len(m.Text))
+	for _, l := range m.Before {
+		size += int64(len(l))
+	}
+	for _, l := range m.After {
+		size += int64(len(l))
+	}
+	if s.results >= s.maxResults || s.bytes+size > s.maxBytes {
+		s.truncated = true
+		return true
+	}
+	s.results++
+	s.bytes += size
+	s.batch = append(s.batch, m)
+	if len(s.batch) >= searchBatchSize || time.Since(s.lastSend) >= searchBatchDelay {
+		s.flush()
+	}
+	return s.broken
+}
+
+// flush 发送已攒的结果
+func (s *searcher) flush() {
+	s.lastSend = time.Now()
+	if len(s.batch) == 0 || s.broken {
+		return
+	}
+	if err := s.d.send(&v1.ConnectRequest{
+		RequestId: s.requestID,
+		Payload:   &v1.ConnectRequest_SearchResult{SearchResult: &v1.SearchResult{Matches: s.batch}},
+	}); err != nil {
+		log.Printf("[文件] 发送搜索结果失败: %v", err)
+		s.broken = true
+	}
+	s.batch = nil
+}
+
+// sendSearchResult 发送一条搜索结果消息
+func (d *Daemon) sendSearchResult(requestID string, result *v1.SearchResult) {
+	if err := d.send(&v1.ConnectRequest{
+		RequestId: requestID,
+		Payload:   &v1.ConnectRequest_SearchResult{SearchResult: result},
+	}); err != nil {
+		log.Printf("[文件] 发送搜索结果失败: %v", err)
+	}
+}
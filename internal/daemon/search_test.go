@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+	"github.com/epiral/cli/internal/config"
+)
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"main.go":           "package main\n\nfunc main() {\n\tTODO(1)\n}\n",
+		"a/b/util.go":       "package b\n// todo: later\n",
+		"a/notes.txt":       "TODO one\nTODO two\nTODO three\n",
+		"blob.bin":          "TODO\x00binary",
+		"gen.go":            "TODO generated\n",
+		".gitignore":        "gen.go\n",
+		"secret/hidden.txt": "TODO hidden\n",
+	})
+	_, hub, _ := startTestDaemon(t, Config{
+		AllowedPaths: []string{dir},
+		PathRules:    []config.PathRule{{Path: filepath.Join(dir, "secret"), Access: []string{config.AccessDeny}}},
+	})
+	var seq int
+	search := func(req *v1.SearchRequest) ([]*v1.SearchMatch, *v1.SearchResult) {
+		t.Helper()
+		seq++
+		msgs := hub.do(t, &v1.ConnectResponse{RequestId: fmt.Sprint("s", seq), Payload: &v1.ConnectResponse_Search{Search: req}},
+			func(m *v1.ConnectRequest) bool { return m.GetSearchResult().GetDone() })
+		var matches []*v1.SearchMatch
+		var last *v1.SearchResult
+		for _, m := range msgs {
+			matches = append(matches, m.GetSearchResult().GetMatches()...)
+			if m.GetSearchResult().GetDone() {
+				last = m.GetSearchResult()
+			}
+		}
+		return matches, last
+	}
+	// relPaths 返回结果相对 dir 的路径和行号，如 "main.go:4"，只按 glob 搜索时不带行号
+	relPaths := func(matches []*v1.SearchMatch) []string {
+		var got []string
+		for _, m := range matches {
+			rel, _ := filepath.Rel(dir, m.Path)
+			if m.Line > 0 {
+				rel = fmt.Sprintf("%s:%d", filepath.ToSlash(rel), m.Line)
+			}
+			got = append(got, filepath.ToSlash(rel))
+		}
+		slices.Sort(got)
+		return got
+	}
+
+	tests := []struct {
+		name          string
+		req           *v1.SearchRequest
+		want          []string
+		wantTruncated bool
+	}{
+		{
+			name: "只按 glob 匹配文件名",
+			req:  &v1.SearchRequest{Path: dir, Glob: "*.go"},
+			want: []string{"a/b/util.go", "main.go"},
+		},
+		{
+			name: "glob 匹配子目录",
+			req:  &v1.SearchRequest{Path: dir, Glob: "a/**/*.go"},
+			want: []string{"a/b/util.go"},
+		},
+		{
+			name: "正则搜索内容，跳过二进制、被忽略和无读权限的文件",
+			req:  &v1.SearchRequest{Path: dir, Pattern: `TODO\b`},
+			want: []string{"a/notes.txt:1", "a/notes.txt:2", "a/notes.txt:3", "main.go:4"},
+		},
+		{
+			name: "忽略大小写并限定 glob",
+			req:  &v1.SearchRequest{Path: dir, Glob: "*.go", Pattern: "todo", IgnoreCase: true},
+			want: []string{"a/b/util.go:2", "main.go:4"},
+		},
+		{
+			name: "不按 .gitignore 过滤",
+			req:  &v1.SearchRequest{Path: dir, Glob: "*.go", Pattern: "TODO", NoGitignore: true},
+			want: []string{"gen.go:1", "main.go:4"},
+		},
+		{
+			name: "搜索单个文件",
+			req:  &v1.SearchRequest{Path: filepath.Join(dir, "a", "notes.txt"), Pattern: "two"},
+			want: []string{"a/notes.txt:2"},
+		},
+		{
+			name:          "达到 max_results 截断",
+			req:           &v1.SearchRequest{Path: filepath.Join(dir, "a", "notes.txt"), Pattern: "TODO", MaxResults: 2},
+			want:          []string{"a/notes.txt:1", "a/notes.txt:2"},
+			wantTruncated: true,
+		},
+		{
+			name:          "达到 max_bytes 截断",
+			req:           &v1.SearchRequest{Path: filepath.Join(dir, "a", "notes.txt"), Pattern: "TODO", MaxBytes: int64(len(filepath.Join(dir, "a", "notes.txt"))) + 10},
+			want:          []string{"a/notes.txt:1"},
+			wantTruncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, last := search(tt.req)
+			if last.Error != "" {
+				t.Fatal(last.Error)
+			}
+			if got := relPaths(matches); !slices.Equal(got, tt.want) || last.Truncated != tt.wantTruncated {
+				t.Fatalf("结果 = %q truncated=%v，期望 %q truncated=%v", got, last.Truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+
+	t.Run("列号和上下文行", func(t *testing.T) {
+		matches, last := search(&v1.SearchRequest{Path: filepath.Join(dir, "main.go"), Pattern: `TODO\(\d\)`, ContextLines: 2})
+		if len(matches) != 1 || last.FilesSearched != 1 {
+			t.Fatalf("%d 个结果，搜索了 %d 个文件", len(matches), last.FilesSearched)
+		}
+		m := matches[0]
+		if m.Line != 4 || m.Column != 2 || m.Text != "\tTODO(1)" {
+			t.Fatalf("line=%d column=%d text=%q", m.Line, m.Column, m.Text)
+		}
+		if !slices.Equal(m.Before, []string{"", "func main() {"}) || !slices.Equal(m.After, []string{"}"}) {
+			t.Fatalf("before=%q after=%q", m.Before, m.After)
+		}
+	})
+
+	t.Run("二进制文件不计入搜索的文件数", func(t *testing.T) {
+		matches, last := search(&v1.SearchRequest{Path: dir, Glob: "*.bin", Pattern: "TODO"})
+		if len(matches) != 0 || last.FilesSearched != 0 {
+			t.Fatalf("%d 个结果，搜索了 %d 个文件", len(matches), last.FilesSearched)
+		}
+	})
+
+	t.Run("过长的行截断", func(t *testing.T) {
+		long := strings.Repeat("x", maxSearchLineBytes) + "TODO"
+		writeTree(t, dir, map[string]string{"long.txt": long + "\n"})
+		matches, _ := search(&v1.SearchRequest{Path: filepath.Join(dir, "long.txt"), Pattern: "TODO"})
+		if len(matches) != 1 {
+			t.Fatalf("%d 个结果，期望 1 个", len(matches))
+		}
+		if n := len(matches[0].Text); n != maxSearchLineBytes {
+			t.Fatalf("text 长 %d，期望截断为 %d", n, maxSearchLineBytes)
+		}
+	})
+
+	errTests := []struct {
+		name string
+		req  *v1.SearchRequest
+		want v1.ErrorCode
+	}{
+		{"glob 和 pattern 都为空", &v1.SearchRequest{Path: dir}, codeInvalidArgument},
+		{"正则无效", &v1.SearchRequest{Path: dir, Pattern: "("}, codeInvalidArgument},
+		{"路径不存在", &v1.SearchRequest{Path: filepath.Join(dir, "missing"), Pattern: "x"}, codeNotFound},
+		{"无读权限", &v1.SearchRequest{Path: filepath.Join(dir, "secret"), Pattern: "x"}, codePermissionDenied},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, last := search(tt.req); last.Code != tt.want {
+				t.Fatalf("code = %v (%s)，期望 %v", last.Code, last.Error, tt.want)
+			}
+		})
+	}
+}
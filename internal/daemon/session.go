@@ -0,0 +1,518 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+const (
+	maxSessions         = 8                // 每个 Daemon 最多同时保持的会话数
+	sessionIdleTimeout  = 30 * time.Minute // 会话空闲超过该时间被回收
+	sessionReapInterval = time.Minute      // 空闲检查周期
+)
+
+var errSessionBusy = errorf(codeUnavailable, "会话数已达上限且全部忙碌")
+
+// shellSession 是一个长期存活的 shell 进程。
+// 命令通过 stdin 逐条写入，执行完成后 shell 打印带随机标记的结束行
+// （退出码 + 当前目录），据此切分每条命令的输出。
+// 因此 cd、export、激活的 virtualenv、shell 函数都会在命令之间保留。
+type shellSession struct {
+	id     string
+	tag    string // 结束标记，每个会话随机生成，避免与命令输出冲突
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	pipes  []io.Closer // stdout/stderr 读端，关闭会话时强制关闭，防止孙进程占着管道
+	stdout chan []byte // 读取 goroutine 投递的原始输出，进程退出后关闭
+	stderr chan []byte
+	exited chan struct{} // shell 进程退出后关闭
+
+	busy chan struct{} // 容量 1 的信号量，同一会话的命令串行执行
+
+	inherit v1.InheritEnv // 创建时的环境继承策略，会话的环境此后不再变化
+
+	mu       sync.Mutex
+	lastUsed time.Time
+	cwd      string
+}
+
+// sessionPool 管理按 session_id 索引的 shell 会话
+type sessionPool struct {
+	shell string
+
+	mu       sync.Mutex
+	sessions map[string]*shellSession
+	closed   bool
+}
+
+func newSessionPool(shell string) *sessionPool {
+	return &sessionPool{
+		shell:    shell,
+		sessions: make(map[string]*shellSession),
+	}
+}
+
+// acquire 获取（必要时创建）会话。workdir 和 env 仅用于新建会话；
+// 已有会话的环境继承策略与 inherit 不同时返回错误。
+func (p *sessionPool) acquire(id, workdir string, inherit v1.InheritEnv, env []string) (*shellSession, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, errorf(codeUnavailable, "会话池已关闭")
+	}
+	if s, ok := p.sessions[id]; ok {
+		select {
+		case <-s.exited:
+			delete(p.sessions, id) // shell 已退出（如执行了 exit），重新创建
+		default:
+			if s.inherit != inherit {
+				return nil, errorf(codeInvalidArgument, "会话 %s 以 inherit_env=%s 创建，不能在会话中改变", id, s.inherit)
+			}
+			return s, nil
+		}
+	}
+
+	if len(p.sessions) >= maxSessions {
+		if !p.evictIdleLocked() {
+			return nil, errSessionBusy
+		}
+	}
+
+	s, err := startShellSession(id, p.shell, workdir, env)
+	if err != nil {
+		return nil, err
+	}
+	s.inherit = inherit
+	p.sessions[id] = s
+	log.Printf("[会话] 创建 %s (共 %d 个)", id, len(p.sessions))
+	return s, nil
+}
+
+// evictIdleLocked 回收最久未使用的空闲会话，调用方需持有 p.mu
+func (p *sessionPool) evictIdleLocked() bool {
+	var victim *shellSession
+	for _, s := range p.sessions {
+		if s.isBusy() {
+			continue
+		}
+		if victim == nil || s.idleSince().Before(victim.idleSince()) {
+			victim = s
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	delete(p.sessions, victim.id)
+	victim.close()
+	log.Printf("[会话] 达到上限，回收 %s", victim.id)
+	return true
+}
+
+// remove 从池中移除并关闭会话
+func (p *sessionPool) remove(s *shellSession) {
+	p.mu.Lock()
+	if cur, ok := p.sessions[s.id]; ok && cur == s {
+		delete(p.sessions, s.id)
+	}
+	p.mu.Unlock()
+	s.close()
+}
+
+// reap 定期回收空闲超时的会话，直到 ctx 结束
+func (p *sessionPool) reap(ctx context.Context) {
+	ticker := time.NewTicker(sessionReapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.reapIdle(now)
+		}
+	}
+}
+
+// reapIdle 回收截至 now 空闲超过 sessionIdleTimeout 的会话
+func (p *sessionPool) reapIdle(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, s := range p.sessions {
+		if !s.isBusy() && now.Sub(s.idleSince()) > sessionIdleTimeout {
+			delete(p.sessions, id)
+			s.close()
+			log.Printf("[会话] 空闲超时，回收 %s", id)
+		}
+	}
+}
+
+// closeAll 关闭所有会话，之后的 acquire 都会失败
+func (p *sessionPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for id, s := range p.sessions {
+		s.close()
+		delete(p.sessions, id)
+	}
+}
+
+// startShellSession 启动 shell 进程并开始读取输出
+func startShellSession(id, shell, workdir string, env []string) (*shellSession, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成会话标记失败: %w", err)
+	}
+
+	cmd := exec.Command(shell)
+	cmd.Dir = workdir
+	cmd.Env = env
+	setProcessGroup(cmd) // 会话内启动的所有进程（含后台任务）随会话一起终止
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdin 管道失败: %w", err)
+	}
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdout 管道失败: %w", err)
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stderr 管道失败: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动 shell 失败: %w", err)
+	}
+
+	s := &shellSession{
+		id:       id,
+		tag:      "__EPIRAL_DONE_" + hex.EncodeToString(nonce) + "__",
+		cmd:      cmd,
+		stdin:    stdin,
+		pipes:    []io.Closer{stdoutPipe, stderrPipe},
+		stdout:   make(chan []byte, 64),
+		stderr:   make(chan []byte, 64),
+		exited:   make(chan struct{}),
+		busy:     make(chan struct{}, 1),
+		lastUsed: time.Now(),
+		cwd:      workdir,
+	}
+
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go pumpOutput(stdoutPipe, s.stdout, &readers)
+	go pumpOutput(stderrPipe, s.stderr, &readers)
+	go func() {
+		// 必须先读完管道再 Wait，否则 Wait 会关闭仍在读取的管道
+		readers.Wait()
+		_ = cmd.Wait()
+		close(s.exited)
+	}()
+	return s, nil
+}
+
+// pumpOutput 把管道中的数据投递到 channel，EOF 后关闭 channel
+func pumpOutput(r io.Reader, ch chan<- []byte, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer close(ch)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			ch <- chunk
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *shellSession) isBusy() bool {
+	return len(s.busy) > 0
+}
+
+func (s *shellSession) currentDir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cwd
+}
+
+func (s *shellSession) idleSince() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastUsed
+}
+
+// close 结束 shell 及其进程组（幂等）
+func (s *shellSession) close() {
+	_ = s.stdin.Close()
+	if s.cmd.Process != nil {
+		_ = signalProcessGroup(s.cmd.Process, syscall.SIGKILL)
+	}
+	for _, p := range s.pipes {
+		_ = p.Close()
+	}
+}
+
+// sessionResult 是会话中一条命令的执行结果
+type sessionResult struct {
+	exitCode int32
+	cwd      string
+	note     string // daemon 自身的说明，附在结束消息的 stderr 中
+	exited   bool   // shell 在命令执行期间退出
+}
+
+// run 在会话中执行一条命令。env 只对这条命令生效：执行前 export，结束后恢复原值，
+// 不影响之后的命令。stdout/stderr 通过 onOutput 随到随回调。
+// ctx 结束（超时）时返回 ctx.Err()，此时会话状态已不可信，调用方应将其移除。
+func (s *shellSession) run(ctx context.Context, command, workdir string, env map[string]string, onOutput func(stream int, data []byte)) (*sessionResult, error) {
+	select {
+	case s.busy <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() {
+		s.mu.Lock()
+		s.lastUsed = time.Now()
+		s.mu.Unlock()
+		<-s.busy
+	}()
+
+	// 命令通过 eval 执行，语法错误只影响本条命令；stdin 重定向避免命令吞掉后续输入
+	var script strings.Builder
+	names := sortedKeys(env)
+	for i, name := range names {
+		fmt.Fprintf(&script, "__epiral_set%d=${%s+x} __epiral_old%d=${%s-}; export %s=%s\n", i, name, i, name, name, shellQuote(env[name]))
+	}
+	if workdir != "" {
+		fmt.Fprintf(&script, "cd -- %s && ", shellQuote(workdir))
+	}
+	fmt.Fprintf(&script, "eval %s </dev/null\n", shellQuote(command))
+	script.WriteString("__epiral_ec=$?\n")
+	for i, name := range names {
+		fmt.Fprintf(&script, "if [ -n \"$__epiral_set%d\" ]; then %s=$__epiral_old%d; else unset %s; fi; unset __epiral_set%d __epiral_old%d\n", i, name, i, name, i, i)
+	}
+	fmt.Fprintf(&script, "printf '\\n%%s %%d %%s\\n' '%s' \"$__epiral_ec\" \"$PWD\"; printf '\\n%%s\\n' '%s' >&2\n", s.tag, s.tag)
+	if _, err := io.WriteString(s.stdin, script.String()); err != nil {
+		return &sessionResult{exitCode: 1, note: fmt.Sprintf("写入会话失败: %v", err), exited: true}, nil
+	}
+
+	stdoutSplit := &markerSplitter{marker: []byte("\n" + s.tag + " ")}
+	stderrSplit := &markerSplitter{marker: []byte("\n" + s.tag + "\n")}
+	emit := func(stream int, data []byte) {
+		if len(data) > 0 {
+			onOutput(stream, data)
+		}
+	}
+	stdoutCh, stderrCh := s.stdout, s.stderr
+	res := &sessionResult{}
+	stdoutDone, stderrDone := false, false
+
+	for !stdoutDone || !stderrDone {
+		select {
+		case <-ctx.Done():
+			// 已收到但暂存的输出仍需交给调用方
+			emit(streamStdout, stdoutSplit.flush())
+			emit(streamStderr, stderrSplit.flush())
+			return nil, ctx.Err()
+		case chunk, ok := <-stdoutCh:
+			if !ok {
+				stdoutCh, stdoutDone, res.exited = nil, true, true
+				emit(streamStdout, stdoutSplit.flush())
+				continue
+			}
+			out, tail, found := stdoutSplit.feed(chunk)
+			emit(streamStdout, out)
+			if found {
+				res.exitCode, res.cwd = parseSessionTrailer(tail)
+				stdoutCh, stdoutDone = nil, true
+			}
+		case chunk, ok := <-stderrCh:
+			if !ok {
+				stderrCh, stderrDone, res.exited = nil, true, true
+				emit(streamStderr, stderrSplit.flush())
+				continue
+			}
+			out, _, found := stderrSplit.feed(chunk)
+			emit(streamStderr, out)
+			if found {
+				stderrCh, stderrDone = nil, true
+			}
+		}
+	}
+	if res.exited {
+		// shell 自己退出了（如执行了 exit），等进程结束拿真实退出码
+		select {
+		case <-s.exited:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if code := s.cmd.ProcessState.ExitCode(); code >= 0 {
+			res.exitCode = int32(code) //nolint:gosec // exit code 不会溢出 int32
+		}
+		return res, nil
+	}
+
+	s.mu.Lock()
+	s.cwd = res.cwd
+	s.mu.Unlock()
+	return res, nil
+}
+
+// markerSplitter 在字节流中查找结束标记。
+// 未确认的尾部（可能是标记的前缀）会暂存，直到下一块数据到达。
+type markerSplitter struct {
+	marker  []byte
+	pending []byte
+	found   bool
+	tail    []byte // 标记之后直到换行的内容
+}
+
+// feed 输入一块数据，返回可以安全输出的部分。
+// 找到标记并读完其所在行后 found=true，tail 为标记后的内容（不含换行）。
+func (m *markerSplitter) feed(chunk []byte) (out, tail []byte, found bool) {
+	if m.found {
+		m.tail = append(m.tail, chunk...)
+		return m.finishTail()
+	}
+	m.pending = append(m.pending, chunk...)
+	if idx := bytes.Index(m.pending, m.marker); idx >= 0 {
+		out = m.pending[:idx]
+		m.tail = append([]byte(nil), m.pending[idx+len(m.marker):]...)
+		m.pending = nil
+		m.found = true
+		_, tail, found = m.finishTail()
+		return out, tail, found
+	}
+	// 只暂存"可能是标记开头"的最长后缀，其余立即输出
+	keep := min(len(m.marker)-1, len(m.pending))
+	for ; keep > 0; keep-- {
+		if bytes.HasPrefix(m.marker, m.pending[len(m.pending)-keep:]) {
+			break
+		}
+	}
+	safe := len(m.pending) - keep
+	out = append([]byte(nil), m.pending[:safe]...)
+	m.pending = append(m.pending[:0], m.pending[safe:]...)
+	return out, nil, false
+}
+
+func (m *markerSplitter) finishTail() (out, tail []byte, found bool) {
+	if len(m.marker) > 0 && m.marker[len(m.marker)-1] == '\n' {
+		return nil, nil, true
+	}
+	if idx := bytes.IndexByte(m.tail, '\n'); idx >= 0 {
+		return nil, m.tail[:idx], true
+	}
+	return nil, nil, false
+}
+
+// flush 返回暂存的数据（流结束时调用）
+func (m *markerSplitter) flush() []byte {
+	out := m.pending
+	m.pending = nil
+	return out
+}
+
+// parseSessionTrailer 解析结束行 "<exit_code> <cwd>"
+func parseSessionTrailer(tail []byte) (int32, string) {
+	codeStr, cwd, _ := strings.Cut(string(tail), " ")
+	code, err := strconv.ParseInt(codeStr, 10, 32)
+	if err != nil {
+		code = 1
+	}
+	return int32(code), cwd
+}
+
+// shellQuote 用单引号包裹字符串，可安全拼接进 POSIX shell 命令
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// handleSessionExec 在持久会话中执行命令，返回退出码
+func (d *Daemon) handleSessionExec(ctx context.Context, req *v1.ExecRequest, timeout time.Duration, run *runningExec, out *execOutput) int32 {
+	// 指定的工作目录每次都切换过去；未指定时沿用会话当前目录，新会话从主目录开始
+	workdir := req.Workdir
+	initialDir := req.Workdir
+	if initialDir == "" {
+		initialDir, _ = os.UserHomeDir()
+	}
+	initialDir, err := d.resolvePath(initialDir, accessExec)
+	if err != nil {
+		log.Printf("[执行] 拒绝: %s", pathErrorMessage(req.Workdir, err))
+		out.done(pathErrorCode(err), pathErrorMessage(req.Workdir, err), 1, req.Workdir)
+		return 1
+	}
+	if workdir != "" {
+		workdir = initialDir
+	}
+
+	if d.sessions == nil {
+		out.done(codeInternal, "会话池未启动", 1, initialDir)
+		return 1
+	}
+	inherit := req.InheritEnv
+	if inherit == v1.InheritEnv_INHERIT_ENV_UNSPECIFIED {
+		inherit = v1.InheritEnv_INHERIT_ENV_FILTERED
+	}
+	sess, err := d.sessions.acquire(req.SessionId, initialDir, inherit, d.buildEnv(inherit, nil))
+	if err != nil {
+		log.Printf("[会话] 获取 %s 失败: %v", req.SessionId, err)
+		out.done(errorCode(err), fmt.Sprintf("获取会话失败: %v", err), 1, initialDir)
+		return 1
+	}
+
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// 会话中的命令与 shell 同属一个进程，取消只能连同会话一起销毁
+	run.setStop(cancel)
+
+	chunker := out.newChunker()
+	writers := [2]io.Writer{chunker.writer(streamStdout), chunker.writer(streamStderr)}
+	res, err := sess.run(execCtx, req.Command, workdir, req.Env, func(stream int, data []byte) {
+		_, _ = writers[stream].Write(data)
+	})
+	_ = chunker.Close()
+	if err != nil {
+		// 超时或断连：命令可能仍在运行，会话状态不可信，直接销毁
+		d.sessions.remove(sess)
+		exitCode, code := int32(1), errorCode(err)
+		msg := fmt.Sprintf("会话 %s 已中止: %v", req.SessionId, err)
+		switch {
+		case run.isCancelled():
+			exitCode, code = exitCodeCancelled, codeCancelled
+			msg = fmt.Sprintf("命令已取消，会话 %s 已重置", req.SessionId)
+		case errors.Is(err, context.DeadlineExceeded):
+			exitCode, code = exitCodeTimeout, codeTimeout
+			msg = fmt.Sprintf("命令超时，会话 %s 已重置", req.SessionId)
+		}
+		log.Printf("[会话] %s", msg)
+		out.done(code, msg, exitCode, sess.currentDir())
+		return exitCode
+	}
+	if res.exited {
+		d.sessions.remove(sess)
+		log.Printf("[会话] %s 的 shell 已退出", req.SessionId)
+		res.cwd = sess.currentDir()
+	}
+	out.done(codeNone, res.note, res.exitCode, res.cwd)
+	return res.exitCode
+}
@@ -0,0 +1,207 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// runInSession 在会话中执行命令，返回 stdout 和结果
+func runInSession(t *testing.T, s *shellSession, command, workdir string, env map[string]string) (string, *sessionResult) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var stdout strings.Builder
+	res, err := s.run(ctx, command, workdir, env, func(stream int, data []byte) {
+		if stream == streamStdout {
+			stdout.Write(data)
+		}
+	})
+	if err != nil {
+		t.Fatalf("执行 %q: %v", command, err)
+	}
+	return stdout.String(), res
+}
+
+func TestShellSession(t *testing.T) {
+	dir := t.TempDir()
+	pool := newSessionPool("/bin/sh")
+	defer pool.closeAll()
+	s, err := pool.acquire("a", dir, v1.InheritEnv_INHERIT_ENV_FILTERED, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cd 跨命令保留，结束行报告 shell 的真实目录
+	sub := filepath.Join(dir, "sub")
+	if _, res := runInSession(t, s, "mkdir sub && cd sub", "", nil); res.exitCode != 0 || res.cwd != sub {
+		t.Fatalf("cd 后 exit=%d cwd=%q，期望 %q", res.exitCode, res.cwd, sub)
+	}
+	if out, _ := runInSession(t, s, "pwd", "", nil); out != sub+"\n" {
+		t.Fatalf("pwd = %q，期望 %q", out, sub)
+	}
+	// 指定 workdir 时先切换过去
+	if out, res := runInSession(t, s, "pwd; (exit 3)", dir, nil); out != dir+"\n" || res.exitCode != 3 || res.exited {
+		t.Fatalf("workdir: pwd = %q exit=%d exited=%v", out, res.exitCode, res.exited)
+	}
+
+	// export 跨命令保留；请求的 env 只对本条命令生效，之后恢复原值
+	runInSession(t, s, "export KEEP=session", "", nil)
+	if out, _ := runInSession(t, s, `echo "$KEEP $ONLY"`, "", map[string]string{"KEEP": "request", "ONLY": "it's"}); out != "request it's\n" {
+		t.Fatalf("请求 env = %q", out)
+	}
+	if out, _ := runInSession(t, s, `echo "$KEEP ${ONLY-unset}"`, "", nil); out != "session unset\n" {
+		t.Fatalf("请求 env 泄漏到之后的命令: %q", out)
+	}
+
+	// 已有会话的环境继承策略不能改变
+	if _, err := pool.acquire("a", dir, v1.InheritEnv_INHERIT_ENV_NONE, nil); errorCode(err) != codeInvalidArgument {
+		t.Fatalf("改变 inherit_env: err = %v", err)
+	}
+
+	// shell 退出后报告真实退出码，下次 acquire 重新创建
+	if _, res := runInSession(t, s, "exit 7", "", nil); !res.exited || res.exitCode != 7 {
+		t.Fatalf("exit 7: exited=%v exit=%d", res.exited, res.exitCode)
+	}
+	s2, err := pool.acquire("a", dir, v1.InheritEnv_INHERIT_ENV_FILTERED, nil)
+	if err != nil || s2 == s {
+		t.Fatalf("shell 退出后应重新创建: %v", err)
+	}
+}
+
+func TestSessionPoolLimits(t *testing.T) {
+	dir := t.TempDir()
+	pool := newSessionPool("/bin/sh")
+	defer pool.closeAll()
+	acquire := func(id string) (*shellSession, error) {
+		return pool.acquire(id, dir, v1.InheritEnv_INHERIT_ENV_FILTERED, nil)
+	}
+
+	// 空闲超时回收，忙碌的会话不回收
+	idle, _ := acquire("idle")
+	busy, _ := acquire("busy")
+	busy.busy <- struct{}{}
+	pool.reapIdle(time.Now())
+	if len(pool.sessions) != 2 {
+		t.Fatalf("未超时不应回收，剩 %d 个", len(pool.sessions))
+	}
+	pool.reapIdle(time.Now().Add(sessionIdleTimeout + time.Minute))
+	if _, ok := pool.sessions["idle"]; ok {
+		t.Fatal("空闲超时的会话应被回收")
+	}
+	if _, ok := pool.sessions["busy"]; !ok {
+		t.Fatal("忙碌的会话不应被回收")
+	}
+	select {
+	case <-idle.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("回收后 shell 未退出")
+	}
+	<-busy.busy
+
+	// 达到上限时回收最久未使用的空闲会话
+	for i := len(pool.sessions); i < maxSessions; i++ {
+		if _, err := acquire(fmt.Sprint(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldest := pool.sessions["busy"]
+	oldest.lastUsed = time.Now().Add(-time.Hour)
+	if _, err := acquire("new"); err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.sessions) != maxSessions || pool.sessions["busy"] != nil {
+		t.Fatalf("应回收最久未用的会话，现有 %d 个", len(pool.sessions))
+	}
+
+	// 全部忙碌时拒绝新会话
+	for _, s := range pool.sessions {
+		s.busy <- struct{}{}
+	}
+	if _, err := acquire("overflow"); err != errSessionBusy {
+		t.Fatalf("全部忙碌时 err = %v", err)
+	}
+	for _, s := range pool.sessions {
+		<-s.busy
+	}
+
+	pool.closeAll()
+	if _, err := acquire("after"); errorCode(err) != codeUnavailable {
+		t.Fatalf("关闭后 acquire err = %v", err)
+	}
+}
+
+func TestMarkerSplitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		marker string
+		chunks []string
+		out    string
+		tail   string
+		found  bool
+	}{
+		{"标记在一块内", "\nTAG ", []string{"hello\nTAG 0 /tmp\n"}, "hello", "0 /tmp", true},
+		{"标记跨块", "\nTAG ", []string{"hello\nTA", "G 1 /", "home\n"}, "hello", "1 /home", true},
+		{"像标记开头但不是", "\nTAG ", []string{"a\nTA", "X\n"}, "a\nTAX\n", "", false},
+		{"以换行结尾的标记", "\nTAG\n", []string{"err\nTAG", "\n"}, "err", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &markerSplitter{marker: []byte(tt.marker)}
+			var out strings.Builder
+			var tail []byte
+			found := false
+			for _, c := range tt.chunks {
+				o, tl, f := m.feed([]byte(c))
+				out.Write(o)
+				if f {
+					tail, found = tl, true
+				}
+			}
+			if !found {
+				out.Write(m.flush())
+			}
+			if out.String() != tt.out || string(tail) != tt.tail || found != tt.found {
+				t.Fatalf("out=%q tail=%q found=%v", out.String(), tail, found)
+			}
+		})
+	}
+}
+
+func TestSessionExec(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir) // 未指定 workdir 时新会话从主目录开始
+	d, hub, stop := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	exec := func(id, command, workdir string) (string, *v1.ExecOutput) {
+		msgs := hub.do(t, &v1.ConnectResponse{RequestId: id, Payload: &v1.ConnectResponse_Exec{Exec: &v1.ExecRequest{
+			Command: command, Workdir: workdir, SessionId: "s",
+		}}}, execFinished)
+		stdout, _, last := execResultOf(msgs)
+		return stdout, last
+	}
+
+	// 结束消息报告命令执行后 shell 的目录
+	sub := filepath.Join(dir, "sub")
+	if _, last := exec("1", "mkdir sub && cd sub", dir); last.Workdir != sub || last.ExitCode != 0 {
+		t.Fatalf("cd 后 workdir=%q exit=%d", last.Workdir, last.ExitCode)
+	}
+	if out, last := exec("2", "pwd", ""); out != sub+"\n" || last.Workdir != sub {
+		t.Fatalf("pwd = %q workdir=%q", out, last.Workdir)
+	}
+
+	// Run 退出时销毁所有会话
+	d.sessions.mu.Lock()
+	sess := d.sessions.sessions["s"]
+	d.sessions.mu.Unlock()
+	stop()
+	select {
+	case <-sess.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run 退出后会话的 shell 仍在运行")
+	}
+}
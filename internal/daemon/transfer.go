@@ -0,0 +1,647 @@
+package daemon
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+const (
+	uploadBacklog = 16 // 每个上传排队等待写入的消息上限，超出的消息被拒绝，不阻塞接收循环
+
+	maxUploadChunk       = 4 * 1024 * 1024 // 单个 UploadChunk 的上限
+	minDownloadChunk     = 16 * 1024
+	defaultTransferChunk = 256 * 1024
+	maxDownloadChunk     = 1024 * 1024
+
+	// 一块的发送耗时低于 chunkFastSend 说明链路有余量，块大小翻倍；高于 chunkSlowSend 则减半
+	chunkFastSend = 100 * time.Millisecond
+	chunkSlowSend = time.Second
+
+	uploadPartTTL      = 24 * time.Hour // 未完成上传的临时文件闲置超过此时长后删除
+	uploadPartSweepGap = time.Hour      // 检查闲置临时文件的间隔
+	uploadPartSuffix   = ".epiral-part"
+)
+
+var transferIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// upload 是一个进行中的上传，只由该传输的 worker 访问
+type upload struct {
+	path     string // 目标路径（已解析）
+	tmp      string // 临时文件
+	file     *os.File
+	size     int64
+	expected string // 期望的整个文件 SHA-256，可为空
+	offset   int64  // 已接收的字节数
+	hash     hash.Hash
+}
+
+// transfers 管理本次连接上的分块传输。
+// 同一传输的上传消息必须按到达顺序处理：每个 transfer_id 有自己的 worker 和队列，
+// 一个传输写盘慢不会影响其他传输和接收循环。下载各自在 goroutine 中发送，登记在 downloads 中供中止。
+type transfers struct {
+	wg sync.WaitGroup // 上传 worker
+
+	mu        sync.Mutex
+	queues    map[string]chan uploadMessage // transfer_id → 上传 worker 的消息队列
+	uploads   map[string]*upload            // transfer_id → 上传
+	downloads map[string]context.CancelFunc // transfer_id → 取消下载
+}
+
+// uploadMessage 是排队等待 worker 处理的上传消息
+type uploadMessage struct {
+	ctx  context.Context // 请求级 ctx，可被 CancelRequest 取消
+	msg  *v1.ConnectResponse
+	done func() // 处理完后移除请求登记
+}
+
+func newTransfers() *transfers {
+	return &transfers{
+		queues:    make(map[string]chan uploadMessage),
+		uploads:   make(map[string]*upload),
+		downloads: make(map[string]context.CancelFunc),
+	}
+}
+
+// upload 返回进行中的上传
+func (t *transfers) upload(id string) (*upload, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.uploads[id]
+	return u, ok
+}
+
+// setUpload 登记进行中的上传
+func (t *transfers) setUpload(id string, u *upload) {
+	t.mu.Lock()
+	t.uploads[id] = u
+	t.mu.Unlock()
+}
+
+// takeUpload 取出并移除进行中的上传
+func (t *transfers) takeUpload(id string) (*upload, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.uploads[id]
+	delete(t.uploads, id)
+	return u, ok
+}
+
+// partFiles 记录断线时留下的未完成上传的临时文件，跨 Run 保留。
+// 重连后没有被续传、闲置超过 uploadPartTTL 的由 sweep 删除。
+type partFiles struct {
+	mu    sync.Mutex
+	files map[string]struct{}
+}
+
+func newPartFiles() *partFiles {
+	return &partFiles{files: make(map[string]struct{})}
+}
+
+// keep 登记断线时未完成的临时文件
+func (p *partFiles) keep(tmp string) {
+	p.mu.Lock()
+	p.files[tmp] = struct{}{}
+	p.mu.Unlock()
+}
+
+// forget 取消登记：上传被续传、提交或中止
+func (p *partFiles) forget(tmp string) {
+	p.mu.Lock()
+	delete(p.files, tmp)
+	p.mu.Unlock()
+}
+
+// sweep 删除截至 now 闲置（最后写入）超过 uploadPartTTL 的临时文件
+func (p *partFiles) sweep(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for tmp := range p.files {
+		info, err := os.Stat(tmp)
+		if err == nil && now.Sub(info.ModTime()) <= uploadPartTTL {
+			continue
+		}
+		if err == nil {
+			log.Printf("[传输] 删除闲置的上传临时文件 %s", tmp)
+			_ = os.Remove(tmp)
+		}
+		delete(p.files, tmp)
+	}
+}
+
+// sweepParts 定期清理闲置的上传临时文件，直到 ctx 结束
+func (d *Daemon) sweepParts(ctx context.Context) {
+	ticker := time.NewTicker(uploadPartSweepGap)
+	defer ticker.Stop()
+	d.parts.sweep(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.parts.sweep(now)
+		}
+	}
+}
+
+// sweepStaleParts 删除 dir 下闲置超过 uploadPartTTL 的上传临时文件，
+// 清理之前的进程留下、不在 partFiles 中的文件
+func sweepStaleParts(dir string, now time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, uploadPartSuffix) {
+			continue
+		}
+		if info, err := e.Info(); err == nil && now.Sub(info.ModTime()) > uploadPartTTL {
+			log.Printf("[传输] 删除闲置的上传临时文件 %s", filepath.Join(dir, name))
+			_ = os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+// queueUpload 登记请求并把上传消息交给该传输的 worker，没有则创建。入队不会阻塞接收循环：
+// 同一传输积压超过 uploadBacklog 条时拒绝这条消息，Agent 按之后应答中的 offset 重发。
+func (d *Daemon) queueUpload(ctx context.Context, msg *v1.ConnectResponse) {
+	id := uploadTransferID(msg)
+	reqCtx, done := d.beginRequest(ctx, msg)
+	t := d.transfers
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	queue, ok := t.queues[id]
+	if !ok {
+		queue = make(chan uploadMessage, uploadBacklog)
+		t.queues[id] = queue
+		t.wg.Go(func() { d.runUpload(id, queue) })
+	}
+	select {
+	case queue <- uploadMessage{ctx: reqCtx, msg: msg, done: done}:
+	default:
+		done()
+		t.wg.Go(func() {
+			d.sendTransferError(msg.RequestId, id, codeUnavailable, fmt.Sprintf("传输 %s 积压的消息过多，请等待应答后从 offset 继续发送", id))
+		})
+	}
+}
+
+// uploadTransferID 返回上传消息的 transfer_id
+func uploadTransferID(msg *v1.ConnectResponse) string {
+	switch p := msg.Payload.(type) {
+	case *v1.ConnectResponse_UploadBegin:
+		return p.UploadBegin.GetTransferId()
+	case *v1.ConnectResponse_UploadChunk:
+		return p.UploadChunk.GetTransferId()
+	case *v1.ConnectResponse_UploadCommit:
+		return p.UploadCommit.GetTransferId()
+	case *v1.ConnectResponse_TransferAbort:
+		return p.TransferAbort.GetTransferId()
+	}
+	return ""
+}
+
+// runUpload 按序处理一个传输的上传消息。上传结束且没有积压时退出；
+// 队列关闭（断线）时关闭未完成上传的文件，临时文件留在磁盘上供重连后续传，闲置过久由 partFiles 清理。
+func (d *Daemon) runUpload(id string, queue chan uploadMessage) {
+	t := d.transfers
+	for m := range queue {
+		d.handleMessage(m.ctx, m.msg)
+		m.done()
+		t.mu.Lock()
+		if _, active := t.uploads[id]; !active && len(queue) == 0 {
+			delete(t.queues, id)
+			t.mu.Unlock()
+			return
+		}
+		t.mu.Unlock()
+	}
+	if u, ok := t.takeUpload(id); ok {
+		_ = u.file.Close()
+		d.parts.keep(u.tmp)
+	}
+}
+
+// stopTransfers 停止所有上传 worker 并取消所有下载
+func (d *Daemon) stopTransfers() {
+	t := d.transfers
+	t.mu.Lock()
+	for id, queue := range t.queues {
+		close(queue)
+		delete(t.queues, id)
+	}
+	for _, cancel := range t.downloads {
+		cancel()
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+}
+
+// sendTransferStatus 发送传输状态
+func (d *Daemon) sendTransferStatus(requestID string, status *v1.TransferStatus) {
+	if err := d.send(&v1.ConnectRequest{
+		RequestId: requestID,
+		Payload:   &v1.ConnectRequest_TransferStatus{TransferStatus: status},
+	}); err != nil {
+		log.Printf("[传输] 发送状态失败: %v", err)
+	}
+}
+
+// sendTransferError 发送失败的传输状态
+func (d *Daemon) sendTransferError(requestID, transferID string, code v1.ErrorCode, msg string) {
+	log.Printf("[传输] %s 失败: %s", transferID, msg)
+	d.sendTransferStatus(requestID, &v1.TransferStatus{TransferId: transferID, Error: msg, Code: code})
+}
+
+// uploadTempPath 返回上传的临时文件路径：与目标同目录，保证 rename 是原子的
+func uploadTempPath(path, transferID string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+transferID+uploadPartSuffix)
+}
+
+// handleUploadBegin 开始或续传一个上传
+func (d *Daemon) handleUploadBegin(ctx context.Context, requestID string, req *v1.UploadBegin) {
+	log.Printf("[传输] 上传 %s (%d 字节)", req.Path, req.Size)
+	if !transferIDPattern.MatchString(req.TransferId) {
+		d.sendTransferError(requestID, req.TransferId, codeInvalidArgument, fmt.Sprintf("非法 transfer_id: %q", req.TransferId))
+		return
+	}
+	if req.Size < 0 {
+		d.sendTransferError(requestID, req.TransferId, codeInvalidArgument, "size 不能为负数")
+		return
+	}
+	path, err := d.resolvePath(req.Path, accessWrite)
+	if err != nil {
+		d.sendTransferError(requestID, req.TransferId, pathErrorCode(err), pathErrorMessage(req.Path, err))
+		return
+	}
+
+	// 同一连接上重复 Begin：以新请求为准重新打开
+	if old, ok := d.transfers.takeUpload(req.TransferId); ok {
+		_ = old.file.Close()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("创建目录失败: %v", err))
+		return
+	}
+	tmp := uploadTempPath(path, req.TransferId)
+	d.parts.forget(tmp)
+	sweepStaleParts(filepath.Dir(path), time.Now())
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("创建临时文件失败: %v", err))
+		return
+	}
+
+	// 已有的临时文件即上次传到的位置；比声明的大小还大说明不是同一个文件，从头开始
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("读取临时文件失败: %v", err))
+		return
+	}
+	offset := info.Size()
+	if offset > req.Size {
+		if err := file.Truncate(0); err != nil {
+			_ = file.Close()
+			d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("重置临时文件失败: %v", err))
+			return
+		}
+		offset = 0
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, ctxReader{ctx, io.NewSectionReader(file, 0, offset)}); err != nil {
+		_ = file.Close()
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("读取临时文件失败: %v", err))
+		return
+	}
+	if offset > 0 {
+		log.Printf("[传输] %s 从 %d 字节处续传", req.TransferId, offset)
+	}
+
+	d.transfers.setUpload(req.TransferId, &upload{
+		path:     path,
+		tmp:      tmp,
+		file:     file,
+		size:     req.Size,
+		expected: req.Sha256,
+		offset:   offset,
+		hash:     h,
+	})
+	d.sendTransferStatus(requestID, &v1.TransferStatus{
+		TransferId: req.TransferId,
+		Offset:     offset,
+		Size:       req.Size,
+		ChunkSize:  defaultTransferChunk,
+	})
+}
+
+// handleUploadChunk 追加一块数据
+func (d *Daemon) handleUploadChunk(ctx context.Context, requestID string, req *v1.UploadChunk) {
+	u, ok := d.transfers.upload(req.TransferId)
+	if !ok {
+		d.sendTransferError(requestID, req.TransferId, codeNotFound, fmt.Sprintf("未知的传输 %s，请先发送 UploadBegin", req.TransferId))
+		return
+	}
+	fail := func(code v1.ErrorCode, msg string) {
+		log.Printf("[传输] %s 失败: %s", req.TransferId, msg)
+		d.sendTransferStatus(requestID, &v1.TransferStatus{TransferId: req.TransferId, Offset: u.offset, Size: u.size, Error: msg, Code: code})
+	}
+
+	switch {
+	case len(req.Data) > maxUploadChunk:
+		fail(codeTooLarge, fmt.Sprintf("数据块过大: %d 字节（上限 %d）", len(req.Data), maxUploadChunk))
+		return
+	case req.Offset != u.offset:
+		fail(codeInvalidArgument, fmt.Sprintf("偏移不连续: 收到 %d，期望 %d", req.Offset, u.offset))
+		return
+	case u.offset+int64(len(req.Data)) > u.size:
+		fail(codeInvalidArgument, fmt.Sprintf("数据超出声明的大小 %d", u.size))
+		return
+	case req.Sha256 != "" && req.Sha256 != hashBytes(req.Data):
+		fail(codeConflict, "数据块校验失败")
+		return
+	}
+	if err := cancelled(ctx); err != nil {
+		fail(errorCode(err), fmt.Sprintf("未写入: %v", err))
+		return
+	}
+
+	if _, err := u.file.WriteAt(req.Data, u.offset); err != nil {
+		fail(errorCode(err), fmt.Sprintf("写入失败: %v", err))
+		return
+	}
+	u.hash.Write(req.Data)
+	u.offset += int64(len(req.Data))
+	d.sendTransferStatus(requestID, &v1.TransferStatus{TransferId: req.TransferId, Offset: u.offset, Size: u.size})
+}
+
+// handleUploadCommit 校验并把临时文件原子地移动到目标路径
+func (d *Daemon) handleUploadCommit(ctx context.Context, requestID string, req *v1.UploadCommit) {
+	u, ok := d.transfers.upload(req.TransferId)
+	if !ok {
+		d.sendTransferError(requestID, req.TransferId, codeNotFound, fmt.Sprintf("未知的传输 %s，请先发送 UploadBegin", req.TransferId))
+		return
+	}
+	if u.offset != u.size {
+		d.sendTransferStatus(requestID, &v1.TransferStatus{
+			TransferId: req.TransferId,
+			Offset:     u.offset,
+			Size:       u.size,
+			Error:      fmt.Sprintf("数据不完整: 已接收 %d / %d 字节", u.offset, u.size),
+			Code:       codeInvalidArgument,
+		})
+		return
+	}
+
+	// 取消时上传保持原样，可以再次提交
+	if err := cancelled(ctx); err != nil {
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("未提交: %v", err))
+		return
+	}
+
+	// 无论成败，这次上传都到此为止
+	d.transfers.takeUpload(req.TransferId)
+	sum := hex.EncodeToString(u.hash.Sum(nil))
+	if u.expected != "" && u.expected != sum {
+		_ = u.file.Close()
+		_ = os.Remove(u.tmp)
+		d.sendTransferError(requestID, req.TransferId, codeConflict, fmt.Sprintf("文件校验失败: 期望 %s，实际 %s，请重新上传", u.expected, sum))
+		return
+	}
+	// 替换目标时持有它的路径锁，不与并发的写入、编辑交错
+	unlock := d.fileLocks.lock(u.path)
+	err := installUpload(u)
+	unlock()
+	if err != nil {
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("移动到目标路径失败: %v", err))
+		return
+	}
+
+	log.Printf("[传输] 上传完成 %s (%d 字节)", u.path, u.size)
+	d.sendTransferStatus(requestID, &v1.TransferStatus{
+		TransferId: req.TransferId,
+		Offset:     u.size,
+		Size:       u.size,
+		Sha256:     sum,
+		Done:       true,
+	})
+}
+
+// installUpload 把校验通过的临时文件移动到目标路径，规则同 writeFileAtomic：
+// 目标已存在时保留其权限位、属主和扩展属性，不存在时使用 defaultFileMode；
+// 无法保留属主或目标有多个硬链接时，把内容原地复制进目标。临时文件总会被删除。
+func installUpload(u *upload) error {
+	committed := false
+	defer func() {
+		if !committed {
+			_ = u.file.Close()
+			_ = os.Remove(u.tmp)
+		}
+	}()
+
+	attrs, err := statTarget(u.path, 0)
+	if err != nil {
+		return err
+	}
+	inPlace := attrs.links > 1
+	if !inPlace {
+		ok, err := attrs.adopt(u.file, u.path)
+		if err != nil {
+			return err
+		}
+		inPlace = !ok
+	}
+	if inPlace {
+		return writeInPlace(u.path, io.NewSectionReader(u.file, 0, u.size), attrs.perm)
+	}
+
+	if err := u.file.Sync(); err != nil {
+		return err
+	}
+	if err := u.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(u.tmp, u.path); err != nil {
+		return err
+	}
+	committed = true
+	syncDir(filepath.Dir(u.path))
+	return nil
+}
+
+// handleTransferAbort 中止上传（删除临时文件）或下载
+func (d *Daemon) handleTransferAbort(requestID string, req *v1.TransferAbort) {
+	log.Printf("[传输] 中止 %s", req.TransferId)
+	status := &v1.TransferStatus{TransferId: req.TransferId}
+
+	if u, ok := d.transfers.takeUpload(req.TransferId); ok {
+		_ = u.file.Close()
+		_ = os.Remove(u.tmp)
+		d.sendTransferStatus(requestID, status)
+		return
+	}
+
+	d.transfers.mu.Lock()
+	cancel, ok := d.transfers.downloads[req.TransferId]
+	d.transfers.mu.Unlock()
+	if ok {
+		cancel()
+		d.sendTransferStatus(requestID, status)
+		return
+	}
+
+	// 重连后尚未重新 Begin 的上传：按路径找到临时文件
+	if req.Path != "" && transferIDPattern.MatchString(req.TransferId) {
+		path, err := d.resolvePath(req.Path, accessWrite)
+		if err != nil {
+			d.sendTransferError(requestID, req.TransferId, pathErrorCode(err), pathErrorMessage(req.Path, err))
+			return
+		}
+		tmp := uploadTempPath(path, req.TransferId)
+		d.parts.forget(tmp)
+		if err := os.Remove(tmp); err == nil || os.IsNotExist(err) {
+			d.sendTransferStatus(requestID, status)
+			return
+		}
+	}
+	d.sendTransferError(requestID, req.TransferId, codeNotFound, fmt.Sprintf("未找到传输: %s", req.TransferId))
+}
+
+// handleDownloadBegin 流式发送文件，块大小随发送速度调整
+func (d *Daemon) handleDownloadBegin(ctx context.Context, requestID string, req *v1.DownloadBegin) {
+	log.Printf("[传输] 下载 %s (offset=%d)", req.Path, req.Offset)
+	if !transferIDPattern.MatchString(req.TransferId) {
+		d.sendTransferError(requestID, req.TransferId, codeInvalidArgument, fmt.Sprintf("非法 transfer_id: %q", req.TransferId))
+		return
+	}
+	path, err := d.resolvePath(req.Path, accessRead)
+	if err != nil {
+		d.sendTransferError(requestID, req.TransferId, pathErrorCode(err), pathErrorMessage(req.Path, err))
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("打开失败: %v", err))
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("读取失败: %v", err))
+		return
+	}
+	if info.IsDir() {
+		d.sendTransferError(requestID, req.TransferId, codeInvalidArgument, fmt.Sprintf("路径是目录: %s", path))
+		return
+	}
+	size := info.Size()
+	if req.Offset < 0 || req.Offset > size {
+		d.sendTransferError(requestID, req.TransferId, codeInvalidArgument, fmt.Sprintf("偏移超出文件大小: %d / %d", req.Offset, size))
+		return
+	}
+
+	// 先算整个文件的哈希：续传时用来确认文件没变，结束时供 Agent 校验
+	sum, err := hashReader(ctxReader{ctx, file})
+	if err != nil {
+		d.sendTransferError(requestID, req.TransferId, errorCode(err), fmt.Sprintf("计算哈希失败: %v", err))
+		return
+	}
+	if req.Sha256 != "" && req.Sha256 != sum {
+		d.sendTransferError(requestID, req.TransferId, codeConflict, "文件已变化，无法续传，请从头下载")
+		return
+	}
+
+	dlCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.transfers.mu.Lock()
+	d.transfers.downloads[req.TransferId] = cancel
+	d.transfers.mu.Unlock()
+	defer func() {
+		d.transfers.mu.Lock()
+		delete(d.transfers.downloads, req.TransferId)
+		d.transfers.mu.Unlock()
+	}()
+
+	chunkSize := defaultTransferChunk
+	if req.ChunkSize > 0 {
+		chunkSize = clampChunkSize(int(req.ChunkSize))
+	}
+	d.sendTransferStatus(requestID, &v1.TransferStatus{
+		TransferId: req.TransferId,
+		Offset:     req.Offset,
+		Size:       size,
+		Sha256:     sum,
+		ChunkSize:  uint32(chunkSize), //nolint:gosec // 已限制在 maxDownloadChunk 内
+	})
+
+	start := time.Now()
+	buf := make([]byte, maxDownloadChunk)
+	offset := req.Offset
+	for offset < size {
+		if dlCtx.Err() != nil {
+			log.Printf("[传输] 下载 %s 已中止 (%d / %d 字节)", req.TransferId, offset, size)
+			return
+		}
+		n, err := file.ReadAt(buf[:min(int64(chunkSize), size-offset)], offset)
+		if n == 0 && err != nil {
+			d.sendTransferStatus(requestID, &v1.TransferStatus{TransferId: req.TransferId, Offset: offset, Size: size, Error: fmt.Sprintf("读取失败: %v", err), Code: errorCode(err)})
+			return
+		}
+		data := buf[:n]
+		sendStart := time.Now()
+		if err := d.send(&v1.ConnectRequest{
+			RequestId: requestID,
+			Payload: &v1.ConnectRequest_TransferChunk{TransferChunk: &v1.TransferChunk{
+				TransferId: req.TransferId,
+				Offset:     offset,
+				Data:       data,
+				Sha256:     hashBytes(data),
+			}},
+		}); err != nil {
+			// 连接已断，Agent 重连后从已收到的 offset 续传
+			log.Printf("[传输] 下载 %s 中断于 %d 字节: %v", req.TransferId, offset, err)
+			return
+		}
+		offset += int64(n)
+		chunkSize = nextChunkSize(chunkSize, time.Since(sendStart))
+	}
+
+	log.Printf("[传输] 下载完成 %s (%d 字节, %.1fs)", path, size-req.Offset, time.Since(start).Seconds())
+	d.sendTransferStatus(requestID, &v1.TransferStatus{
+		TransferId: req.TransferId,
+		Offset:     size,
+		Size:       size,
+		Sha256:     sum,
+		Done:       true,
+	})
+}
+
+// nextChunkSize 根据上一块的发送耗时调整块大小。
+// 流的发送会被 HTTP/2 流控阻塞，耗时能反映链路的实际吞吐。
+func nextChunkSize(cur int, elapsed time.Duration) int {
+	switch {
+	case elapsed < chunkFastSend:
+		cur *= 2
+	case elapsed > chunkSlowSend:
+		cur /= 2
+	}
+	return clampChunkSize(cur)
+}
+
+func clampChunkSize(n int) int {
+	return min(max(n, minDownloadChunk), maxDownloadChunk)
+}
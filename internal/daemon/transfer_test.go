@@ -0,0 +1,243 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/epiral/cli/gen/epiral/v1"
+)
+
+// transferStatus 下发一条传输消息，返回它的 TransferStatus 应答
+func transferStatus(t *testing.T, hub *testHub, msg *v1.ConnectResponse) *v1.TransferStatus {
+	t.Helper()
+	msgs := hub.do(t, msg, func(m *v1.ConnectRequest) bool { return m.GetTransferStatus() != nil })
+	for _, m := range msgs {
+		if s := m.GetTransferStatus(); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+func uploadBegin(id, transferID, path, data, sum string) *v1.ConnectResponse {
+	return &v1.ConnectResponse{RequestId: id, Payload: &v1.ConnectResponse_UploadBegin{UploadBegin: &v1.UploadBegin{
+		TransferId: transferID, Path: path, Size: int64(len(data)), Sha256: sum,
+	}}}
+}
+
+func uploadChunk(id, transferID string, offset int64, data string) *v1.ConnectResponse {
+	return &v1.ConnectResponse{RequestId: id, Payload: &v1.ConnectResponse_UploadChunk{UploadChunk: &v1.UploadChunk{
+		TransferId: transferID, Offset: offset, Data: []byte(data),
+	}}}
+}
+
+func uploadCommit(id, transferID string) *v1.ConnectResponse {
+	return &v1.ConnectResponse{RequestId: id, Payload: &v1.ConnectResponse_UploadCommit{UploadCommit: &v1.UploadCommit{
+		TransferId: transferID,
+	}}}
+}
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	d, hub, stop := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	const content = "hello!"
+	sum := hashBytes([]byte(content))
+
+	t.Run("续传与提交", func(t *testing.T) {
+		path := filepath.Join(dir, "new.bin")
+		if s := transferStatus(t, hub, uploadBegin("b1", "t1", path, content, sum)); s.Error != "" || s.Offset != 0 {
+			t.Fatalf("Begin: %+v", s)
+		}
+		if s := transferStatus(t, hub, uploadChunk("c1", "t1", 0, content[:3])); s.Offset != 3 {
+			t.Fatalf("Chunk: %+v", s)
+		}
+		// 重新 Begin 从已接收的字节数续传
+		if s := transferStatus(t, hub, uploadBegin("b2", "t1", path, content, sum)); s.Error != "" || s.Offset != 3 {
+			t.Fatalf("续传 Begin: %+v", s)
+		}
+		if s := transferStatus(t, hub, uploadChunk("c2", "t1", 0, content)); s.Code != codeInvalidArgument || s.Offset != 3 {
+			t.Fatalf("偏移不连续: %+v", s)
+		}
+		if s := transferStatus(t, hub, uploadCommit("m1", "t1")); s.Code != codeInvalidArgument {
+			t.Fatalf("数据不完整时提交: %+v", s)
+		}
+		if s := transferStatus(t, hub, uploadChunk("c3", "t1", 3, content[3:])); s.Offset != 6 {
+			t.Fatalf("Chunk: %+v", s)
+		}
+		if s := transferStatus(t, hub, uploadCommit("m2", "t1")); !s.Done || s.Sha256 != sum {
+			t.Fatalf("Commit: %+v", s)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Fatalf("内容 = %q, %v", data, err)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != defaultFileMode {
+			t.Fatalf("新文件权限 = %v，期望 %v", info.Mode().Perm(), defaultFileMode)
+		}
+		if _, err := os.Stat(uploadTempPath(path, "t1")); !os.IsNotExist(err) {
+			t.Fatal("提交后临时文件应已删除")
+		}
+	})
+
+	t.Run("覆盖时保留权限", func(t *testing.T) {
+		path := filepath.Join(dir, "run.sh")
+		if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, 0o751); err != nil {
+			t.Fatal(err)
+		}
+		transferStatus(t, hub, uploadBegin("b3", "t2", path, content, ""))
+		transferStatus(t, hub, uploadChunk("c4", "t2", 0, content))
+		if s := transferStatus(t, hub, uploadCommit("m3", "t2")); !s.Done {
+			t.Fatalf("Commit: %+v", s)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0o751 {
+			t.Fatalf("权限 = %v，期望保留 0751", info.Mode().Perm())
+		}
+	})
+
+	t.Run("整体校验失败", func(t *testing.T) {
+		path := filepath.Join(dir, "keep.txt")
+		if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		transferStatus(t, hub, uploadBegin("b4", "t3", path, content, hashBytes([]byte("other!"))))
+		transferStatus(t, hub, uploadChunk("c5", "t3", 0, content))
+		if s := transferStatus(t, hub, uploadCommit("m4", "t3")); s.Code != codeConflict || s.Done {
+			t.Fatalf("Commit: %+v", s)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "old" {
+			t.Fatalf("校验失败不应覆盖目标: %q", data)
+		}
+		if _, err := os.Stat(uploadTempPath(path, "t3")); !os.IsNotExist(err) {
+			t.Fatal("校验失败后临时文件应已删除")
+		}
+	})
+
+	// 断线时未完成的上传留给重连续传，并登记下来供闲置清理
+	path := filepath.Join(dir, "partial.bin")
+	transferStatus(t, hub, uploadBegin("b5", "t4", path, content, ""))
+	transferStatus(t, hub, uploadChunk("c6", "t4", 0, content[:2]))
+	stop()
+	tmp := uploadTempPath(path, "t4")
+	if info, err := os.Stat(tmp); err != nil || info.Size() != 2 {
+		t.Fatalf("断线后临时文件应保留: %v", err)
+	}
+	if _, ok := d.parts.files[tmp]; !ok {
+		t.Fatal("断线时未完成的临时文件应登记到 partFiles")
+	}
+}
+
+func TestPartFilesSweep(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	touch := func(name string, age time.Duration) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	exists := func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}
+
+	parts := newPartFiles()
+	fresh := touch(".a.t1"+uploadPartSuffix, time.Hour)
+	stale := touch(".b.t2"+uploadPartSuffix, uploadPartTTL+time.Hour)
+	resumed := touch(".c.t3"+uploadPartSuffix, uploadPartTTL+time.Hour)
+	for _, p := range []string{fresh, stale, resumed} {
+		parts.keep(p)
+	}
+	parts.forget(resumed)
+	parts.sweep(now)
+	if !exists(fresh) || exists(stale) || !exists(resumed) {
+		t.Fatalf("fresh=%v stale=%v resumed=%v", exists(fresh), exists(stale), exists(resumed))
+	}
+	if len(parts.files) != 1 {
+		t.Fatalf("清理后仍登记 %d 个文件，期望 1", len(parts.files))
+	}
+
+	// 之前的进程留下的临时文件在同目录再次上传时清理，其他文件不动
+	other := touch("data"+uploadPartSuffix, uploadPartTTL+time.Hour)
+	sweepStaleParts(dir, now)
+	if !exists(fresh) || exists(resumed) || !exists(other) {
+		t.Fatalf("fresh=%v resumed=%v other=%v", exists(fresh), exists(resumed), exists(other))
+	}
+}
+
+func TestUploadQueueFull(t *testing.T) {
+	dir := t.TempDir()
+	d, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+
+	// 模拟写盘卡住的传输：队列已满且没有 worker 在消费
+	stuck := make(chan uploadMessage, uploadBacklog)
+	for range uploadBacklog {
+		stuck <- uploadMessage{ctx: context.Background(), msg: uploadChunk("", "slow", 0, "x"), done: func() {}}
+	}
+	d.transfers.mu.Lock()
+	d.transfers.queues["slow"] = stuck
+	d.transfers.mu.Unlock()
+
+	// 积压的传输收到拒绝，接收循环不被阻塞，其他传输照常进行
+	if s := transferStatus(t, hub, uploadChunk("c1", "slow", 0, "x")); s.Code != codeUnavailable {
+		t.Fatalf("队列满时: %+v", s)
+	}
+	path := filepath.Join(dir, "other.txt")
+	transferStatus(t, hub, uploadBegin("b1", "fast", path, "ok", ""))
+	transferStatus(t, hub, uploadChunk("c2", "fast", 0, "ok"))
+	if s := transferStatus(t, hub, uploadCommit("m1", "fast")); !s.Done {
+		t.Fatalf("其他传输: %+v", s)
+	}
+}
+
+func TestUploadCommitHoldsPathLock(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	path := filepath.Join(dir, "f.txt")
+	transferStatus(t, hub, uploadBegin("b1", "t1", path, "upload", ""))
+	transferStatus(t, hub, uploadChunk("c1", "t1", 0, "upload"))
+
+	// 写入或编辑持有目标的锁时，提交等它完成后再替换
+	unlock := d.fileLocks.lock(path)
+	hub.down <- uploadCommit("m1", "t1")
+	time.Sleep(100 * time.Millisecond)
+	if msgs := hub.messages("m1"); len(msgs) != 0 {
+		t.Fatal("持有路径锁时提交不应完成")
+	}
+	unlock()
+	msgs := hub.wait(t, "m1", func(m *v1.ConnectRequest) bool { return m.GetTransferStatus() != nil })
+	if s := msgs[0].GetTransferStatus(); !s.Done {
+		t.Fatalf("Commit: %+v", s)
+	}
+}
+
+func TestDownloadCancelledWhileHashing(t *testing.T) {
+	dir := t.TempDir()
+	d, hub, _ := startTestDaemon(t, Config{AllowedPaths: []string{dir}})
+	path := filepath.Join(dir, "big.bin")
+	if err := os.WriteFile(path, make([]byte, 1<<20), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 计算哈希期间被取消：不再读完文件，直接以取消结束
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errCancelled)
+	d.handleDownloadBegin(ctx, "dl", &v1.DownloadBegin{TransferId: "t1", Path: path})
+	msgs := hub.wait(t, "dl", func(m *v1.ConnectRequest) bool { return m.GetTransferStatus() != nil })
+	if s := msgs[0].GetTransferStatus(); s.Code != codeCancelled || s.Sha256 != "" {
+		t.Fatalf("取消后: %+v", s)
+	}
+}
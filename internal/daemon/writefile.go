@@ -0,0 +1,199 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// defaultFileMode 是新建文件的默认权限：只有属主可读写，与改为原子写入之前一致
+const defaultFileMode fs.FileMode = 0o600
+
+// writeFileAtomic 写入文件：先写入同目录下的临时文件并 fsync，再 rename 覆盖目标，
+// 中途崩溃不会留下写了一半的文件。目标已存在时保留其权限位、属主和扩展属性，
+// 不存在时使用 mode（0 表示 defaultFileMode）。path 应已解析符号链接，
+// 这样写入的是链接目标，链接本身保持不变。
+//
+// 以下情况退化为原地覆盖写入：目录不可写（无法创建临时文件）、
+// 无法保留属主（如非 root 写入他人的文件）、文件有多个硬链接。
+func writeFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	attrs, err := statTarget(path, mode)
+	if err != nil {
+		return err
+	}
+	if attrs.links > 1 {
+		return writeInPlace(path, bytes.NewReader(data), attrs.perm)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.epiral-tmp")
+	if err != nil {
+		if attrs.exists && errors.Is(err, fs.ErrPermission) {
+			return writeInPlace(path, bytes.NewReader(data), attrs.perm)
+		}
+		return err
+	}
+	name := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(name)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if ok, err := attrs.adopt(tmp, path); err != nil {
+		return err
+	} else if !ok {
+		return writeInPlace(path, bytes.NewReader(data), attrs.perm)
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(name, path); err != nil {
+		return err
+	}
+	committed = true
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// targetAttrs 是写入目标已有的属性，替换文件时要保留
+type targetAttrs struct {
+	exists   bool
+	perm     fs.FileMode // 权限位（含 setuid/setgid/sticky）
+	uid, gid int
+	owned    bool // uid/gid 有效
+	links    uint64
+}
+
+// statTarget 读取写入目标的属性；目标不存在时 perm 为 mode（0 表示 defaultFileMode）
+func statTarget(path string, mode fs.FileMode) (targetAttrs, error) {
+	attrs := targetAttrs{perm: mode}
+	if attrs.perm == 0 {
+		attrs.perm = defaultFileMode
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return attrs, nil
+	}
+	if err != nil {
+		return attrs, err
+	}
+	if !info.Mode().IsRegular() {
+		return attrs, errorf(codeInvalidArgument, "不是普通文件: %s", path)
+	}
+	attrs.exists = true
+	attrs.perm = info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	attrs.uid, attrs.gid, attrs.links, attrs.owned = fileOwner(info)
+	return attrs, nil
+}
+
+// adopt 让将要 rename 到 path 的临时文件继承目标的属主、权限位和扩展属性。
+// 返回 false 表示无法保留属主（如非 root 写入他人的文件），应改为原地写入。
+func (a *targetAttrs) adopt(tmp *os.File, path string) (bool, error) {
+	if a.owned {
+		// 先 chown 再 chmod：chown 会清除 setuid/setgid 位
+		if err := tmp.Chown(a.uid, a.gid); err != nil {
+			log.Printf("[文件] 无法保留属主，原地写入 %s: %v", path, err)
+			return false, nil
+		}
+	}
+	if err := tmp.Chmod(a.perm); err != nil {
+		return true, err
+	}
+	if a.exists {
+		copyXattrs(path, tmp.Name())
+	}
+	return true, nil
+}
+
+// writeInPlace 截断后把 r 的内容原地写入已存在的文件，保留 inode（属主、硬链接、扩展属性）
+func writeInPlace(path string, r io.Reader, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pathLocks 是按路径的互斥锁。同一文件的读取、expected_hash 校验和写入整体串行执行，
+// 并发的写入不会都通过校验，编辑也不会基于过期的内容写回。不再使用的锁即被移除。
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+type pathLock struct {
+	sync.Mutex
+	refs int // 持有或等待此锁的数量
+}
+
+// lock 锁住 path（应已解析符号链接），返回解锁函数
+func (p *pathLocks) lock(path string) (unlock func()) {
+	p.mu.Lock()
+	if p.locks == nil {
+		p.locks = make(map[string]*pathLock)
+	}
+	l := p.locks[path]
+	if l == nil {
+		l = &pathLock{}
+		p.locks[path] = l
+	}
+	l.refs++
+	p.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		p.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(p.locks, path)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// lockAll 按字典序锁住 paths 中的每个路径（重复的只锁一次），返回全部解锁的函数。
+// 所有需要同时持有多个路径锁的地方都按同一顺序加锁，不会互相死锁。
+func (p *pathLocks) lockAll(paths []string) (unlock func()) {
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+	unlocks := make([]func(), 0, len(paths))
+	for _, path := range paths {
+		unlocks = append(unlocks, p.lock(path))
+	}
+	return func() {
+		for _, u := range slices.Backward(unlocks) {
+			u()
+		}
+	}
+}
+
+// syncDir 把目录项的变化（rename）落盘，失败时忽略：部分平台不支持对目录 fsync
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+}
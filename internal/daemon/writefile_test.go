@@ -0,0 +1,159 @@
+package daemon
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	read := func(p string) string {
+		t.Helper()
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	perm := func(p string) fs.FileMode {
+		t.Helper()
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	t.Run("新文件默认权限", func(t *testing.T) {
+		p := filepath.Join(dir, "new.txt")
+		if err := writeFileAtomic(p, []byte("a"), 0); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(p); got != "a" {
+			t.Fatalf("内容 = %q", got)
+		}
+		if got := perm(p); got != defaultFileMode {
+			t.Fatalf("权限 = %v，期望 %v", got, defaultFileMode)
+		}
+	})
+
+	t.Run("新文件指定权限", func(t *testing.T) {
+		p := filepath.Join(dir, "script.sh")
+		if err := writeFileAtomic(p, []byte("#!/bin/sh"), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if got := perm(p); got != 0o750 {
+			t.Fatalf("权限 = %v，期望 0750", got)
+		}
+	})
+
+	t.Run("保留已有文件的权限", func(t *testing.T) {
+		p := filepath.Join(dir, "exec.sh")
+		if err := os.WriteFile(p, []byte("old"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFileAtomic(p, []byte("new"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(p); got != "new" {
+			t.Fatalf("内容 = %q", got)
+		}
+		if got := perm(p); got != 0o755 {
+			t.Fatalf("权限 = %v，期望保留 0755", got)
+		}
+	})
+
+	t.Run("不留下临时文件", func(t *testing.T) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if filepath.Ext(e.Name()) == ".epiral-tmp" {
+				t.Fatalf("残留临时文件 %s", e.Name())
+			}
+		}
+	})
+
+	t.Run("硬链接原地写入", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Windows 不支持")
+		}
+		p := filepath.Join(dir, "linked.txt")
+		other := filepath.Join(dir, "other.txt")
+		if err := os.WriteFile(p, []byte("old"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Link(p, other); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFileAtomic(p, []byte("new"), 0); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(other); got != "new" {
+			t.Fatalf("另一个硬链接的内容 = %q，期望同步更新", got)
+		}
+	})
+
+	t.Run("目标是目录", func(t *testing.T) {
+		if err := writeFileAtomic(dir, []byte("x"), 0); err == nil {
+			t.Fatal("写入目录应失败")
+		}
+	})
+}
+
+func TestPathLocks(t *testing.T) {
+	var p pathLocks
+	unlock := p.lock("/a")
+	locked := make(chan struct{})
+	go func() {
+		defer p.lock("/a")()
+		close(locked)
+	}()
+	p.lock("/b")() // 不同路径互不影响
+	select {
+	case <-locked:
+		t.Fatal("同一路径的锁应互斥")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+
+	// lockAll 去重后按顺序加锁，解锁后所有路径都可再次加锁
+	unlockAll := p.lockAll([]string{"/b", "/a", "/b"})
+	locked = make(chan struct{})
+	go func() {
+		defer p.lock("/b")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lockAll 应锁住每个路径")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlockAll()
+	<-locked
+	p.lock("/a")()
+
+	// 最后一个持有者解锁后移除
+	deadline := time.Now().Add(time.Second)
+	for {
+		p.mu.Lock()
+		n := len(p.locks)
+		p.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("用完的路径锁未移除: %d 个", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,35 @@
+package daemon
+
+import (
+	"bytes"
+	"syscall"
+)
+
+// copyXattrs 把 src 的扩展属性（SELinux 标签、ACL 等）复制到 dst。
+// 尽力而为：文件系统不支持或无权限设置的属性直接跳过。
+func copyXattrs(src, dst string) {
+	size, err := syscall.Listxattr(src, nil)
+	if err != nil || size == 0 {
+		return
+	}
+	names := make([]byte, size)
+	size, err = syscall.Listxattr(src, names)
+	if err != nil {
+		return
+	}
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		attr := string(name)
+		n, err := syscall.Getxattr(src, attr, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, n)
+		if n, err = syscall.Getxattr(src, attr, value); err != nil {
+			continue
+		}
+		_ = syscall.Setxattr(dst, attr, value[:n], 0)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package daemon
+
+// copyXattrs 只在 Linux 上实现，其它平台不复制扩展属性
+func copyXattrs(src, dst string) {}
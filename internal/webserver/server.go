@@ -113,6 +113,11 @@ func (s *Server) handlePutConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := cfg.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// 确保默认值
 	if cfg.Web.Port == 0 {
 		cfg.Web.Port = s.port
@@ -2,6 +2,7 @@ import { Routes, Route } from "react-router-dom";
 import Layout from "./components/Layout";
 import Dashboard from "./pages/Dashboard";
 import Config from "./pages/Config";
+import Browser from "./pages/Browser";
 import Logs from "./pages/Logs";
 
 export default function App() {
@@ -10,6 +11,7 @@ export default function App() {
       <Route element={<Layout />}>
         <Route path="/" element={<Dashboard />} />
         <Route path="/config" element={<Config />} />
+        <Route path="/browser" element={<Browser />} />
         <Route path="/logs" element={<Logs />} />
       </Route>
     </Routes>
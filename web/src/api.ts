@@ -7,6 +7,33 @@ export interface DaemonStatus {
   reconnects: number;
   lastError?: string;
   computer?: string;
+  features?: string[]; // Agent 确认启用的功能
+  browser?: BrowserStatus;
+}
+
+// 浏览器插件端点状态
+export interface BrowserStatus {
+  id: string;
+  listen: string;
+  online: boolean;
+  lastSeen?: string;
+  plugins: BrowserPlugin[] | null;
+  recent: BrowserCommand[] | null;
+}
+
+export interface BrowserPlugin {
+  connectedAt: string;
+  lastSeen: string;
+  origin?: string;
+  userAgent?: string;
+}
+
+export interface BrowserCommand {
+  requestId: string;
+  action?: string;
+  startedAt: string;
+  durationMs: number;
+  error?: string;
 }
 
 export interface StatusResponse {
@@ -15,9 +42,25 @@ export interface StatusResponse {
   configPath: string;
 }
 
+export type PathAccess = "read" | "write" | "exec" | "deny";
+
+// 路径权限规则：最长前缀匹配，deny 不能与其它权限组合
+export interface PathRule {
+  path: string;
+  access: PathAccess[];
+}
+
 export interface Config {
   agent: { address: string; token: string };
-  computer: { id: string; description: string; allowedPaths: string[] };
+  computer: {
+    id: string;
+    description: string;
+    allowedPaths: string[];
+    paths: PathRule[];
+    envAllow: string[];
+    envDeny: string[];
+  };
+  browser: { id: string; description: string; listen: string };
   web: { port: number };
 }
 
@@ -41,11 +84,15 @@ export async function getConfig(): Promise<Config> {
 }
 
 export async function putConfig(cfg: Config): Promise<void> {
-  await fetch(`${BASE}/api/config`, {
+  const res = await fetch(`${BASE}/api/config`, {
     method: "PUT",
     headers: { "Content-Type": "application/json" },
     body: JSON.stringify(cfg),
   });
+  if (!res.ok) {
+    const body = await res.json().catch(() => ({}));
+    throw new Error(body.error || `HTTP ${res.status}`);
+  }
 }
 
 export async function getLogs(): Promise<{ entries: LogEntry[] }> {
@@ -5,6 +5,7 @@ import { getStatus, type StatusResponse } from "../api";
 const navItems = [
   { to: "/", label: "Dashboard" },
   { to: "/config", label: "Config" },
+  { to: "/browser", label: "Browser" },
   { to: "/logs", label: "Logs" },
 ];
 
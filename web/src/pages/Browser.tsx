@@ -0,0 +1,171 @@
+import { useEffect, useState } from "react";
+import { Link } from "react-router-dom";
+import { getStatus, type BrowserStatus } from "../api";
+
+export default function Browser() {
+  const [browser, setBrowser] = useState<BrowserStatus | null | undefined>(undefined);
+
+  useEffect(() => {
+    const load = () =>
+      getStatus()
+        .then((s) => setBrowser(s.daemon.browser ?? null))
+        .catch(() => {});
+    load();
+    const id = setInterval(load, 2000);
+    return () => clearInterval(id);
+  }, []);
+
+  if (browser === undefined) {
+    return <div className="text-zinc-500">loading...</div>;
+  }
+
+  // 未配置浏览器时显示引导
+  if (browser === null) {
+    return (
+      <div className="space-y-6">
+        <h2 className="text-xl font-semibold">Browser</h2>
+        <div className="rounded-lg border border-zinc-800 bg-zinc-900 p-6 space-y-4">
+          <p className="text-zinc-300">
+            未启用浏览器。设置 Browser ID 后，浏览器插件可连接到本机端点，接收 Agent 的浏览器命令。
+          </p>
+          <Link
+            to="/config"
+            className="inline-block px-4 py-2 rounded-md bg-blue-600 hover:bg-blue-500 text-white text-sm font-medium transition-colors"
+          >
+            Go to Config
+          </Link>
+        </div>
+      </div>
+    );
+  }
+
+  const plugins = browser.plugins ?? [];
+  const recent = browser.recent ?? [];
+
+  return (
+    <div className="space-y-6">
+      <h2 className="text-xl font-semibold">Browser</h2>
+
+      <div className="grid grid-cols-1 sm:grid-cols-3 gap-4">
+        <Card title="Browser">
+          <p className="text-zinc-200 font-mono">{browser.id}</p>
+          <p
+            className={`mt-1 text-sm ${browser.online ? "text-emerald-400" : "text-zinc-500"}`}
+          >
+            {browser.online ? "online" : "offline"}
+          </p>
+        </Card>
+        <Card title="Plugin Endpoint">
+          <p className="text-sm text-zinc-400 font-mono truncate" title={browser.listen}>
+            http://{browser.listen}/sse
+          </p>
+        </Card>
+        <Card title="Last Seen">
+          <p className="text-sm text-zinc-200">
+            {browser.lastSeen ? formatTime(browser.lastSeen) : "never"}
+          </p>
+        </Card>
+      </div>
+
+      {/* 已连接的插件 */}
+      <Section title={`Plugins (${plugins.length})`}>
+        {plugins.length === 0 ? (
+          <p className="text-sm text-zinc-500">no plugin attached</p>
+        ) : (
+          <table className="w-full text-sm">
+            <thead>
+              <tr className="text-left text-xs text-zinc-500">
+                <th className="pb-2 font-medium">Origin</th>
+                <th className="pb-2 font-medium">Connected</th>
+                <th className="pb-2 font-medium">Last Seen</th>
+              </tr>
+            </thead>
+            <tbody>
+              {plugins.map((p, i) => (
+                <tr key={i} className="border-t border-zinc-800">
+                  <td className="py-2 font-mono text-zinc-300 truncate max-w-xs" title={p.userAgent}>
+                    {p.origin || "-"}
+                  </td>
+                  <td className="py-2 text-zinc-400">{formatTime(p.connectedAt)}</td>
+                  <td className="py-2 text-zinc-400">{formatTime(p.lastSeen)}</td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+      </Section>
+
+      {/* 最近的命令 */}
+      <Section title="Recent Commands">
+        {recent.length === 0 ? (
+          <p className="text-sm text-zinc-500">no commands yet</p>
+        ) : (
+          <table className="w-full text-sm">
+            <thead>
+              <tr className="text-left text-xs text-zinc-500">
+                <th className="pb-2 font-medium">Time</th>
+                <th className="pb-2 font-medium">Action</th>
+                <th className="pb-2 font-medium">Duration</th>
+                <th className="pb-2 font-medium">Result</th>
+              </tr>
+            </thead>
+            <tbody>
+              {recent.map((c) => (
+                <tr key={c.requestId} className="border-t border-zinc-800">
+                  <td className="py-2 text-zinc-400">{formatTime(c.startedAt)}</td>
+                  <td className="py-2 font-mono text-zinc-300" title={c.requestId}>
+                    {c.action || "-"}
+                  </td>
+                  <td className="py-2 text-zinc-400 font-mono">{c.durationMs}ms</td>
+                  <td
+                    className={`py-2 truncate max-w-xs ${c.error ? "text-red-400" : "text-emerald-400"}`}
+                    title={c.error}
+                  >
+                    {c.error || "ok"}
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+      </Section>
+    </div>
+  );
+}
+
+function formatTime(iso: string): string {
+  const d = new Date(iso);
+  return d.toLocaleTimeString("zh-CN", { hour12: false });
+}
+
+function Card({
+  title,
+  children,
+}: {
+  title: string;
+  children: React.ReactNode;
+}) {
+  return (
+    <div className="rounded-lg border border-zinc-800 bg-zinc-900 p-4">
+      <h3 className="text-xs font-medium text-zinc-500 uppercase tracking-wider mb-2">
+        {title}
+      </h3>
+      {children}
+    </div>
+  );
+}
+
+function Section({
+  title,
+  children,
+}: {
+  title: string;
+  children: React.ReactNode;
+}) {
+  return (
+    <div className="rounded-lg border border-zinc-800 bg-zinc-900 p-5 space-y-4">
+      <h3 className="text-sm font-medium text-zinc-300">{title}</h3>
+      {children}
+    </div>
+  );
+}
@@ -1,5 +1,11 @@
 import { useEffect, useState } from "react";
-import { getConfig, putConfig, type Config as ConfigType } from "../api";
+import {
+  getConfig,
+  putConfig,
+  type Config as ConfigType,
+  type PathAccess,
+  type PathRule,
+} from "../api";
 
 export default function Config() {
   const [config, setConfig] = useState<ConfigType | null>(null);
@@ -20,21 +26,27 @@ export default function Config() {
     try {
       // 保存时清理空行和空格
       const cleaned = structuredClone(config);
-      cleaned.computer.allowedPaths = (cleaned.computer.allowedPaths ?? [])
-        .map((s) => s.trim())
-        .filter(Boolean);
+      cleaned.computer.allowedPaths = cleanList(cleaned.computer.allowedPaths);
+      cleaned.computer.paths = (cleaned.computer.paths ?? [])
+        .map((r) => ({ ...r, path: r.path.trim() }))
+        .filter((r) => r.path);
+      cleaned.computer.envAllow = cleanList(cleaned.computer.envAllow);
+      cleaned.computer.envDeny = cleanList(cleaned.computer.envDeny);
+      cleaned.browser.id = cleaned.browser.id.trim();
+      cleaned.browser.listen = cleaned.browser.listen.trim();
       await putConfig(cleaned);
       setConfig(cleaned);
       setMessage({ type: "ok", text: "saved! daemon restarting..." });
       setTimeout(() => setMessage(null), 3000);
-    } catch {
-      setMessage({ type: "error", text: "save failed" });
+    } catch (e) {
+      const reason = e instanceof Error ? e.message : "";
+      setMessage({ type: "error", text: reason ? `save failed: ${reason}` : "save failed" });
     } finally {
       setSaving(false);
     }
   };
 
-  const update = (path: string, value: string | number | string[]) => {
+  const update = (path: string, value: string | number | string[] | PathRule[]) => {
     setConfig((prev) => {
       if (!prev) return prev;
       const next = structuredClone(prev);
@@ -102,24 +114,56 @@ export default function Config() {
           value={config.computer.description}
           onChange={(v) => update("computer.description", v)}
         />
-        <div>
-          <label className="block text-sm text-zinc-400 mb-1">
-            Allowed Paths
-          </label>
-          <textarea
-            className="w-full bg-zinc-800 border border-zinc-700 rounded-md px-3 py-2 text-sm text-zinc-200 font-mono focus:outline-none focus:border-zinc-500 resize-none"
-            rows={3}
-            placeholder="/home/user&#10;/tmp"
-            value={(config.computer.allowedPaths ?? []).join("\n")}
-            onChange={(e) =>
-              update(
-                "computer.allowedPaths",
-                e.target.value.split("\n")
-              )
-            }
-          />
-          <p className="text-xs text-zinc-500 mt-1">one path per line</p>
-        </div>
+        <ListField
+          label="Allowed Paths"
+          placeholder={"/home/user\n/tmp"}
+          value={config.computer.allowedPaths}
+          onChange={(v) => update("computer.allowedPaths", v)}
+          hint="one path per line, full access (read + write + exec)"
+        />
+        <PathRulesField
+          value={config.computer.paths}
+          onChange={(v) => update("computer.paths", v)}
+        />
+        <ListField
+          label="Env Allowlist"
+          placeholder={"PATH\nHOME\nLANG"}
+          value={config.computer.envAllow}
+          onChange={(v) => update("computer.envAllow", v)}
+          hint="daemon env vars passed to commands, * wildcard supported (empty = all)"
+        />
+        <ListField
+          label="Env Denylist"
+          placeholder={"AWS_*\n*_TOKEN"}
+          value={config.computer.envDeny}
+          onChange={(v) => update("computer.envDeny", v)}
+          hint="never passed to commands, overrides the allowlist"
+        />
+      </Section>
+
+      {/* Browser */}
+      <Section title="Browser">
+        <Field
+          label="ID"
+          placeholder="my-chrome (empty = disabled)"
+          value={config.browser.id}
+          onChange={(v) => update("browser.id", v)}
+        />
+        <Field
+          label="Description"
+          placeholder="optional"
+          value={config.browser.description}
+          onChange={(v) => update("browser.description", v)}
+        />
+        <Field
+          label="Plugin Listen Address"
+          placeholder="127.0.0.1:19824"
+          value={config.browser.listen}
+          onChange={(v) => update("browser.listen", v)}
+        />
+        <p className="text-xs text-zinc-500">
+          the browser extension connects to http://&lt;listen&gt;/sse; keep it on localhost
+        </p>
       </Section>
 
       {/* Web */}
@@ -154,6 +198,109 @@ function Section({
   );
 }
 
+function cleanList(items: string[] | null | undefined): string[] {
+  return (items ?? []).map((s) => s.trim()).filter(Boolean);
+}
+
+function ListField({
+  label,
+  value,
+  onChange,
+  placeholder,
+  hint,
+}: {
+  label: string;
+  value: string[] | null | undefined;
+  onChange: (v: string[]) => void;
+  placeholder?: string;
+  hint?: string;
+}) {
+  return (
+    <div>
+      <label className="block text-sm text-zinc-400 mb-1">{label}</label>
+      <textarea
+        className="w-full bg-zinc-800 border border-zinc-700 rounded-md px-3 py-2 text-sm text-zinc-200 font-mono focus:outline-none focus:border-zinc-500 resize-none"
+        rows={3}
+        placeholder={placeholder}
+        value={(value ?? []).join("\n")}
+        onChange={(e) => onChange(e.target.value.split("\n"))}
+      />
+      {hint && <p className="text-xs text-zinc-500 mt-1">{hint}</p>}
+    </div>
+  );
+}
+
+const ACCESS_OPTIONS: PathAccess[] = ["read", "write", "exec"];
+
+function PathRulesField({
+  value,
+  onChange,
+}: {
+  value: PathRule[] | null | undefined;
+  onChange: (v: PathRule[]) => void;
+}) {
+  const rules = value ?? [];
+  const setRule = (i: number, rule: PathRule) =>
+    onChange(rules.map((r, j) => (j === i ? rule : r)));
+
+  // 勾选具体权限时去掉 deny，勾选 deny 时清空其它权限
+  const toggle = (i: number, access: PathAccess) => {
+    const rule = rules[i];
+    let next: PathAccess[];
+    if (access === "deny") {
+      next = rule.access.includes("deny") ? ["read"] : ["deny"];
+    } else if (rule.access.includes(access)) {
+      next = rule.access.filter((a) => a !== access);
+    } else {
+      next = [...rule.access.filter((a) => a !== "deny"), access];
+    }
+    setRule(i, { ...rule, access: next.length ? next : ["deny"] });
+  };
+
+  return (
+    <div>
+      <label className="block text-sm text-zinc-400 mb-1">Path Permissions</label>
+      <div className="space-y-2">
+        {rules.map((rule, i) => (
+          <div key={i} className="flex items-center gap-3">
+            <input
+              className="flex-1 bg-zinc-800 border border-zinc-700 rounded-md px-3 py-1.5 text-sm text-zinc-200 font-mono focus:outline-none focus:border-zinc-500"
+              placeholder="/home/user/reference-repo"
+              value={rule.path}
+              onChange={(e) => setRule(i, { ...rule, path: e.target.value })}
+            />
+            {[...ACCESS_OPTIONS, "deny" as const].map((access) => (
+              <label key={access} className="flex items-center gap-1 text-xs text-zinc-400">
+                <input
+                  type="checkbox"
+                  checked={rule.access.includes(access)}
+                  onChange={() => toggle(i, access)}
+                />
+                {access}
+              </label>
+            ))}
+            <button
+              onClick={() => onChange(rules.filter((_, j) => j !== i))}
+              className="text-xs text-zinc-500 hover:text-red-400"
+            >
+              remove
+            </button>
+          </div>
+        ))}
+      </div>
+      <button
+        onClick={() => onChange([...rules, { path: "", access: ["read"] }])}
+        className="mt-2 text-xs text-blue-400 hover:text-blue-300"
+      >
+        + add path
+      </button>
+      <p className="text-xs text-zinc-500 mt-1">
+        longest matching path wins; e.g. a read-only repo with a writable scratch dir inside
+      </p>
+    </div>
+  );
+}
+
 function Field({
   label,
   value,
@@ -87,6 +87,27 @@ export default function Dashboard() {
           ) : (
             <p className="text-zinc-500">-</p>
           )}
+          {daemon.features && daemon.features.length > 0 && (
+            <p className="mt-1 text-xs text-zinc-500 truncate" title={daemon.features.join(", ")}>
+              features: {daemon.features.join(", ")}
+            </p>
+          )}
+        </Card>
+
+        {/* Browser */}
+        <Card title="Browser">
+          {daemon.browser ? (
+            <>
+              <p className="text-zinc-200 font-mono">{daemon.browser.id}</p>
+              <p className={`mt-1 text-sm ${daemon.browser.online ? "text-emerald-400" : "text-zinc-500"}`}>
+                {daemon.browser.online
+                  ? `plugin online (${daemon.browser.plugins?.length ?? 0})`
+                  : "plugin offline"}
+              </p>
+            </>
+          ) : (
+            <p className="text-zinc-500">-</p>
+          )}
         </Card>
 
         {/* 重连次数 */}